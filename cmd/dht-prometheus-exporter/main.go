@@ -10,17 +10,27 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	logrus "github.com/sirupsen/logrus"
 
+	"github.com/guivin/dht-prometheus-exporter/internal/alert"
 	"github.com/guivin/dht-prometheus-exporter/internal/collector"
 	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/host"
+	"github.com/guivin/dht-prometheus-exporter/internal/ingest"
 	"github.com/guivin/dht-prometheus-exporter/internal/logger"
+	"github.com/guivin/dht-prometheus-exporter/internal/poller"
 	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+	"github.com/guivin/dht-prometheus-exporter/internal/startup"
+	"github.com/guivin/dht-prometheus-exporter/internal/stats"
+	"github.com/guivin/dht-prometheus-exporter/internal/version"
 )
 
 // loggingMiddleware logs incoming HTTP requests with client IP
@@ -64,6 +74,46 @@ func getClientIP(r *http.Request) string {
 	return ip
 }
 
+// landingPage is served at the root path and used as the alert generator URL.
+const landingPage = `<html>
+<head><title>DHT Prometheus Exporter</title></head>
+<body>
+<h1>DHT Prometheus Exporter</h1>
+<ul>
+<li><a href="/metrics">Metrics</a></li>
+<li><a href="/health">Health</a></li>
+<li><a href="/ready">Readiness</a></li>
+</ul>
+</body>
+</html>
+`
+
+// opener returns the function opening the sensor of cfg, wrapped with its
+// health tracking and power switch. The power switch is kept across calls,
+// so that a failing sensor can still be power cycled once it is opened.
+func opener(cfg *config.SensorConfig, lg *logrus.Logger) startup.OpenFunc {
+	var power sensor.PowerSwitch
+	return func() (sensor.Reader, error) {
+		if cfg.Power.GPIO >= 0 && power == nil {
+			p, err := sensor.OpenPowerSwitch(cfg)
+			if err != nil {
+				return nil, err
+			}
+			power = p
+		}
+		r, err := sensor.Open(cfg, lg)
+		if err != nil {
+			return nil, err
+		}
+		// Failing sensors back off instead of being retried on every scrape and poll
+		health := sensor.NewHealth(r, cfg.Health, lg)
+		if power != nil {
+			health.SetPowerSwitch(power, cfg.Power)
+		}
+		return health, nil
+	}
+}
+
 func main() {
 	if err := run(); err != nil {
 		log.Fatalf("Application error: %v", err)
@@ -71,6 +121,8 @@ func main() {
 }
 
 func run() error {
+	start := time.Now()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -87,50 +139,198 @@ func run() error {
 			"error":           err,
 		}).Warn("Failed to create logger, using info level")
 	}
+	lg.WithFields(logrus.Fields{
+		"version":  version.Version,
+		"revision": version.Revision,
+	}).Info("Starting DHT Prometheus exporter")
 
-	// Initialize DHT host (required before creating sensors)
-	lg.Info("Initializing DHT22/AM2302 host")
-	if err := sensor.HostInit(); err != nil {
-		return fmt.Errorf("failed to initialize DHT host: %w", err)
+	// Expand entries discovering their devices, such as all DS18B20 probes
+	cfg.Sensors, err = sensor.Discover(cfg.Sensors, lg)
+	if err != nil {
+		return err
 	}
 
-	// Initialize sensors and collectors
+	sensor.SetExecConcurrency(cfg.ExecMaxConcurrent)
+
+	// Only the metrics registered here are exposed, the Go runtime and process
+	// metrics included
+	registry := prometheus.NewRegistry()
+	if cfg.Collectors.Go {
+		if err := registry.Register(collectors.NewGoCollector()); err != nil {
+			return fmt.Errorf("failed to register Go collector: %w", err)
+		}
+	}
+	if cfg.Collectors.Process {
+		if err := registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+			return fmt.Errorf("failed to register process collector: %w", err)
+		}
+	}
+	if cfg.Collectors.Host {
+		if err := registry.Register(host.New(cfg.MetricsNamespace, lg)); err != nil {
+			return fmt.Errorf("failed to register host collector: %w", err)
+		}
+	}
+
+	// Initialize sensors. Sensors failing to initialize are retried in the
+	// background, unless fail_fast is set, so that the others are served meanwhile
+	// Sensors read in Celsius; their temperature_unit is applied when exposing and polling them
+	collectorOpts := collector.Options{
+		Namespace:          cfg.MetricsNamespace,
+		ConstLabels:        cfg.ConstLabels,
+		DropLabels:         cfg.DropLabels,
+		TemperatureMetrics: cfg.TemperatureMetrics,
+	}
+	sensors := startup.New(collectorOpts, lg)
 	for i := range cfg.Sensors {
 		sensorCfg := &cfg.Sensors[i]
-		sensorReader, err := sensor.New(sensorCfg, lg)
+		_, err := sensors.Add(sensorCfg, opener(sensorCfg, lg))
+		if err != nil && (cfg.FailFast || !errors.Is(err, startup.ErrInit)) {
+			return err
+		}
+	}
+	readers := sensors.Readers()
+	if err := registry.Register(sensors); err != nil {
+		return fmt.Errorf("failed to register sensor initialization collector: %w", err)
+	}
+
+	retryCtx, stopRetries := context.WithCancel(context.Background())
+	defer func() {
+		stopRetries()
+		sensors.Wait()
+	}()
+	sensors.Start(retryCtx)
+
+	lg.WithFields(logrus.Fields{
+		"count":   len(cfg.Sensors),
+		"pending": len(sensors.Pending()),
+	}).Info("Sensors initialized")
+
+	if err := registry.Register(version.NewCollector(cfg.MetricsNamespace, start)); err != nil {
+		return fmt.Errorf("failed to register build info collector: %w", err)
+	}
+
+	// GPIO reads are serialized host-wide; expose how long they queue
+	sensor.DefaultScheduler.SetNamespace(cfg.MetricsNamespace)
+	if err := registry.Register(sensor.DefaultScheduler); err != nil {
+		return fmt.Errorf("failed to register read scheduler collector: %w", err)
+	}
+
+	// Remote devices post their readings to the ingest endpoint
+	var remotes *ingest.Store
+	if len(cfg.Ingest.Devices) > 0 {
+		remotes = ingest.New(&cfg.Ingest, collectorOpts, lg)
+		if err := registry.Register(remotes); err != nil {
+			return fmt.Errorf("failed to register remote sensor collector: %w", err)
+		}
+		lg.WithField("devices", len(cfg.Ingest.Devices)).Info("Remote sensor ingestion enabled")
+	}
+
+	// Initialize output sinks behind a fan-out with per-sink queues
+	fanout := sink.NewFanout(cfg.MetricsNamespace, lg)
+	for i := range cfg.Sinks {
+		sinkCfg := &cfg.Sinks[i]
+		s, err := sink.New(sinkCfg, lg)
 		if err != nil {
-			return fmt.Errorf("failed to initialize sensor '%s': %w", sensorCfg.Name, err)
+			return fmt.Errorf("failed to initialize sink '%s': %w", sinkCfg.Name, err)
+		}
+		fanout.Add(sinkCfg.Name, s, sinkCfg.QueueSize, sinkCfg.Policy)
+	}
+
+	// Threshold alerts are evaluated on polled readings like any other sink
+	alerts, err := alert.New(cfg, lg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize alerting: %w", err)
+	}
+	if alerts.RuleCount() > 0 {
+		fanout.Add("alerts", alerts, config.DefaultSinkQueueSize, config.SinkPolicyDrop)
+		if err := registry.Register(alerts); err != nil {
+			return fmt.Errorf("failed to register alert collector: %w", err)
+		}
+	}
+
+	// Window statistics are computed over the polled readings
+	if len(cfg.StatsWindows) > 0 {
+		windows := stats.NewWindows(cfg.Sensors, cfg.StatsWindows, collectorOpts, lg)
+		fanout.Add("stats", windows, config.DefaultSinkQueueSize, config.SinkPolicyDrop)
+		if err := registry.Register(windows); err != nil {
+			return fmt.Errorf("failed to register window statistics collector: %w", err)
 		}
+	}
 
-		// Create and register collector
-		coll := collector.New(sensorReader, lg)
-		lg.WithField("sensor", sensorCfg.Name).Debug("Registering Prometheus collector")
-		if err := prometheus.Register(coll); err != nil {
-			return fmt.Errorf("failed to register collector for sensor '%s': %w", sensorCfg.Name, err)
+	// Trends are fitted to the polled readings, with the alert rules as thresholds
+	if cfg.TrendWindow > 0 {
+		trends := stats.NewTrends(cfg.Sensors, cfg.TrendWindow, collectorOpts, lg)
+		fanout.Add("trends", trends, config.DefaultSinkQueueSize, config.SinkPolicyDrop)
+		if err := registry.Register(trends); err != nil {
+			return fmt.Errorf("failed to register trend collector: %w", err)
 		}
 	}
 
-	lg.WithField("count", len(cfg.Sensors)).Info("Sensors initialized")
+	if err := registry.Register(fanout); err != nil {
+		return fmt.Errorf("failed to register sink collector: %w", err)
+	}
+
+	// Poll sensors for the sinks in the background
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	pollDone := make(chan struct{})
+	defer func() {
+		stopPolling()
+		<-pollDone
+		if err := fanout.Close(); err != nil {
+			lg.WithError(err).Warn("Failed to close sinks")
+		}
+	}()
+	if fanout.Len() > 0 {
+		if err := fanout.Start(); err != nil {
+			close(pollDone)
+			return err
+		}
+		p := poller.New(readers, fanout, cfg.PollInterval, lg)
+		go func() {
+			p.Run(pollCtx)
+			close(pollDone)
+		}()
+	} else {
+		close(pollDone)
+	}
 
 	// Set up HTTP server
 	w := lg.Writer()
 	defer func() { _ = w.Close() }()
 
+	// Sensors are read with the scrape's context, bounded by the Prometheus scrape timeout
+	metrics := collector.Handler(registry, sensors.Collectors, promhttp.HandlerOpts{
+		ErrorLog: stdlibLog.New(w, "", 0),
+	})
+
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(
-		prometheus.DefaultGatherer,
-		promhttp.HandlerOpts{
-			ErrorLog: stdlibLog.New(w, "", 0),
-		},
-	))
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(landingPage))
+	})
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		// Not ready until every sensor is initialized
+		if pending := sensors.Pending(); len(pending) > 0 {
+			http.Error(w, "sensors not initialized: "+strings.Join(pending, ", "), http.StatusServiceUnavailable)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	endpoints := []string{"/", "/metrics", "/health", "/ready"}
+	if remotes != nil {
+		mux.Handle(ingest.Path, remotes)
+		endpoints = append(endpoints, ingest.Path)
+	}
 
 	addr := fmt.Sprintf(":%d", cfg.ListenPort)
 	server := &http.Server{
@@ -162,7 +362,7 @@ func run() error {
 
 	lg.WithFields(logrus.Fields{
 		"address":   addr,
-		"endpoints": []string{"/metrics", "/health", "/ready"},
+		"endpoints": endpoints,
 	}).Info("Starting HTTP server")
 	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("HTTP server error: %w", err)
@@ -0,0 +1,213 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	// defaultResendInterval is how often active alerts are re-sent when resend_interval is not set.
+	defaultResendInterval = time.Minute
+
+	// alertsPath is the Alertmanager v2 endpoint alerts are posted to.
+	alertsPath = "/api/v2/alerts"
+)
+
+// postableAlert is an alert in the Alertmanager v2 API format.
+type postableAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+}
+
+// Alertmanager posts alerts to an Alertmanager v2 API.
+// Like Prometheus, it re-sends active alerts on an interval with an endsAt in
+// the near future, so Alertmanager resolves them on its own if the exporter
+// stops running.
+type Alertmanager struct {
+	url            string
+	generatorURL   string
+	resendInterval time.Duration
+	client         *http.Client
+	logger         *log.Logger
+	timeNow        func() time.Time
+
+	mu     sync.Mutex
+	active map[string]Notification
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAlertmanager creates an Alertmanager notifier.
+// generatorURL is the link back to the exporter included in every alert.
+func NewAlertmanager(cfg *config.AlertmanagerConfig, generatorURL string, logger *log.Logger) (*Alertmanager, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("alertmanager requires a url")
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	resendInterval := cfg.ResendInterval
+	if resendInterval == 0 {
+		resendInterval = defaultResendInterval
+	}
+
+	return &Alertmanager{
+		url:            strings.TrimSuffix(cfg.URL, "/") + alertsPath,
+		generatorURL:   generatorURL,
+		resendInterval: resendInterval,
+		client:         &http.Client{Timeout: timeout},
+		logger:         logger,
+		timeNow:        time.Now,
+		active:         make(map[string]Notification),
+	}, nil
+}
+
+// Name returns the Alertmanager alerts endpoint.
+func (a *Alertmanager) Name() string {
+	return a.url
+}
+
+// Start begins re-sending active alerts every resend interval.
+func (a *Alertmanager) Start() error {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.resendLoop()
+	return nil
+}
+
+// Close stops re-sending alerts.
+func (a *Alertmanager) Close() error {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+		a.stop = nil
+	}
+	return nil
+}
+
+func (a *Alertmanager) resendLoop() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.resendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			if err := a.Resend(context.Background()); err != nil {
+				a.logger.WithFields(log.Fields{
+					"alertmanager": a.url,
+					"error":        err,
+				}).Warn("Failed to re-send active alerts")
+			}
+		}
+	}
+}
+
+// Notify posts n and tracks it so it is re-sent while it is firing.
+func (a *Alertmanager) Notify(ctx context.Context, n Notification) error {
+	a.mu.Lock()
+	if n.Status == StatusResolved {
+		delete(a.active, n.Rule)
+	} else {
+		a.active[n.Rule] = n
+	}
+	a.mu.Unlock()
+
+	return a.post(ctx, []postableAlert{a.alert(n)})
+}
+
+// Resend posts every currently firing alert.
+func (a *Alertmanager) Resend(ctx context.Context) error {
+	a.mu.Lock()
+	alerts := make([]postableAlert, 0, len(a.active))
+	for _, n := range a.active {
+		alerts = append(alerts, a.alert(n))
+	}
+	a.mu.Unlock()
+
+	if len(alerts) == 0 {
+		return nil
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Labels["alertname"] < alerts[j].Labels["alertname"]
+	})
+	return a.post(ctx, alerts)
+}
+
+// alert converts a notification to the Alertmanager format.
+func (a *Alertmanager) alert(n Notification) postableAlert {
+	labels := map[string]string{
+		"alertname": n.Rule,
+		"dht_name":  n.Sensor,
+		"hostname":  n.Hostname,
+		"gpio":      n.GPIO,
+		"metric":    n.Metric,
+	}
+	for k, v := range n.Labels {
+		labels[k] = v
+	}
+
+	endsAt := a.timeNow().Add(4 * a.resendInterval)
+	if n.EndsAt != nil {
+		endsAt = *n.EndsAt
+	}
+
+	return postableAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":   n.Summary,
+			"value":     strconv.FormatFloat(n.Value, 'f', -1, 64),
+			"threshold": n.Comparison + " " + strconv.FormatFloat(n.Threshold, 'f', -1, 64),
+		},
+		StartsAt:     n.StartsAt,
+		EndsAt:       endsAt,
+		GeneratorURL: a.generatorURL,
+	}
+}
+
+// post sends alerts to the Alertmanager API.
+func (a *Alertmanager) post(ctx context.Context, alerts []postableAlert) error {
+	payload, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("failed to encode alerts: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create alertmanager request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alertmanager request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alertmanager returned status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
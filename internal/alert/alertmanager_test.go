@@ -0,0 +1,186 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// fakeAlertmanager is a stand-in for the Alertmanager v2 alerts endpoint
+type fakeAlertmanager struct {
+	mu       sync.Mutex
+	requests [][]postableAlert
+	paths    []string
+	status   int
+}
+
+func (f *fakeAlertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var alerts []postableAlert
+	if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, alerts)
+	f.paths = append(f.paths, r.URL.Path)
+	status := f.status
+	f.mu.Unlock()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+}
+
+func (f *fakeAlertmanager) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func newTestAlertmanager(t *testing.T, fake *fakeAlertmanager, resend time.Duration) *Alertmanager {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	am, err := NewAlertmanager(&config.AlertmanagerConfig{
+		URL:            server.URL + "/",
+		ResendInterval: resend,
+	}, "http://pi:8080/", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewAlertmanager() returned unexpected error: %v", err)
+	}
+	return am
+}
+
+func TestAlertmanager_Firing(t *testing.T) {
+	fake := &fakeAlertmanager{}
+	am := newTestAlertmanager(t, fake, time.Minute)
+	now := time.Unix(1700000600, 0).UTC()
+	am.timeNow = func() time.Time { return now }
+
+	n := testNotification()
+	n.Labels = map[string]string{"severity": "critical"}
+	if err := am.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() returned unexpected error: %v", err)
+	}
+
+	if fake.count() != 1 || len(fake.requests[0]) != 1 {
+		t.Fatalf("unexpected requests: %+v", fake.requests)
+	}
+	if fake.paths[0] != "/api/v2/alerts" {
+		t.Errorf("path = %q, want /api/v2/alerts", fake.paths[0])
+	}
+
+	alert := fake.requests[0][0]
+	wantLabels := map[string]string{
+		"alertname": "freezer-too-warm",
+		"dht_name":  "freezer",
+		"hostname":  "pi",
+		"gpio":      "GPIO4",
+		"metric":    "temperature",
+		"severity":  "critical",
+	}
+	for k, v := range wantLabels {
+		if alert.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, alert.Labels[k], v)
+		}
+	}
+	if alert.Annotations["summary"] != n.Summary || alert.Annotations["value"] != "-8.5" {
+		t.Errorf("unexpected annotations: %v", alert.Annotations)
+	}
+	if alert.GeneratorURL != "http://pi:8080/" {
+		t.Errorf("generatorURL = %q, want %q", alert.GeneratorURL, "http://pi:8080/")
+	}
+	if !alert.StartsAt.Equal(n.StartsAt) {
+		t.Errorf("startsAt = %v, want %v", alert.StartsAt, n.StartsAt)
+	}
+	// Firing alerts expire on their own unless re-sent
+	if want := now.Add(4 * time.Minute); !alert.EndsAt.Equal(want) {
+		t.Errorf("endsAt = %v, want %v", alert.EndsAt, want)
+	}
+}
+
+func TestAlertmanager_ResendUntilResolved(t *testing.T) {
+	fake := &fakeAlertmanager{}
+	am := newTestAlertmanager(t, fake, time.Minute)
+
+	am.Notify(context.Background(), testNotification())
+
+	if err := am.Resend(context.Background()); err != nil {
+		t.Fatalf("Resend() returned unexpected error: %v", err)
+	}
+	if fake.count() != 2 {
+		t.Fatalf("Alertmanager received %d requests, want 2", fake.count())
+	}
+
+	resolved := testNotification()
+	resolved.Status = StatusResolved
+	endsAt := time.Unix(1700000900, 0).UTC()
+	resolved.EndsAt = &endsAt
+	am.Notify(context.Background(), resolved)
+
+	if got := fake.requests[2][0].EndsAt; !got.Equal(endsAt) {
+		t.Errorf("resolved endsAt = %v, want %v", got, endsAt)
+	}
+
+	// Nothing left to re-send
+	am.Resend(context.Background())
+	if fake.count() != 3 {
+		t.Errorf("Alertmanager received %d requests, want 3", fake.count())
+	}
+}
+
+func TestAlertmanager_ResendLoop(t *testing.T) {
+	fake := &fakeAlertmanager{}
+	am := newTestAlertmanager(t, fake, 10*time.Millisecond)
+	if err := am.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	defer am.Close()
+
+	am.Notify(context.Background(), testNotification())
+
+	deadline := time.After(2 * time.Second)
+	for fake.count() < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("alert was re-sent %d times, want at least 2", fake.count()-1)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestAlertmanager_ErrorStatus(t *testing.T) {
+	fake := &fakeAlertmanager{status: http.StatusBadRequest}
+	am := newTestAlertmanager(t, fake, time.Minute)
+
+	if err := am.Notify(context.Background(), testNotification()); err == nil {
+		t.Error("Notify() expected error for 400 response, got nil")
+	}
+}
+
+// Manager must start and stop notifiers with background work
+func TestManager_StartsAlertmanager(t *testing.T) {
+	fake := &fakeAlertmanager{}
+	am := newTestAlertmanager(t, fake, time.Minute)
+	m := NewManager(testSensors(), 0, []Notifier{am}, getSilentLogger())
+
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	if am.stop == nil {
+		t.Error("Manager.Start() did not start the Alertmanager resend loop")
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if am.stop != nil {
+		t.Error("Manager.Close() did not stop the Alertmanager resend loop")
+	}
+}
@@ -0,0 +1,271 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// metricReadFailures is the metric of the built-in sensor failure rules; its
+// value is the number of consecutive failed reads.
+const metricReadFailures = "read_failures"
+
+// Manager evaluates threshold alert rules on every polled reading and sends
+// firing and resolved notifications. With a sensor failure threshold it also
+// alerts on sensors that keep failing to read.
+// It implements sink.Sink so it can be fed by the poller like any other output,
+// and prometheus.Collector to expose the current alert states.
+type Manager struct {
+	logger    *log.Logger
+	hostname  string
+	notifiers []Notifier
+
+	mu           sync.Mutex
+	rules        []*rule
+	bySensor     map[string][]*rule
+	failureRules map[string]*rule
+	failures     map[string]int
+
+	activeMetric *prometheus.Desc
+}
+
+// New creates a Manager for the alert rules of all sensors in cfg, notifying
+// the configured webhooks and Alertmanagers.
+// Returns an error if a notifier cannot be created.
+func New(cfg *config.Config, logger *log.Logger) (*Manager, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get hostname, using empty string")
+		hostname = ""
+	}
+
+	generatorURL := cfg.Alerting.ExternalURL
+	if generatorURL == "" {
+		generatorURL = fmt.Sprintf("http://%s:%d/", hostname, cfg.ListenPort)
+	}
+
+	notifiers := make([]Notifier, 0, len(cfg.Alerting.Webhooks)+len(cfg.Alerting.Alertmanagers))
+	for i := range cfg.Alerting.Webhooks {
+		w, err := NewWebhook(&cfg.Alerting.Webhooks[i])
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, w)
+	}
+	for i := range cfg.Alerting.Alertmanagers {
+		am, err := NewAlertmanager(&cfg.Alerting.Alertmanagers[i], generatorURL, logger)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, am)
+	}
+
+	m := NewManager(cfg.Sensors, cfg.Alerting.SensorFailureAfter, notifiers, logger)
+	m.hostname = hostname
+	m.activeMetric = newActiveMetric(cfg.MetricsNamespace)
+	return m, nil
+}
+
+// newActiveMetric returns the descriptor of the alert state gauge, named
+// after namespace.
+func newActiveMetric(namespace string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		config.MetricName(namespace, "alert_active"),
+		"Whether the alert rule is currently firing (1) or not (0)",
+		[]string{"rule", "dht_name"}, nil,
+	)
+}
+
+// NewManager creates a Manager for the alert rules of the given sensors.
+// dht_alert_active keeps the default namespace; use New for a fully
+// configured Manager.
+// When sensorFailureAfter is positive, every sensor also gets a rule firing
+// after that many consecutive failed reads.
+func NewManager(sensors []config.SensorConfig, sensorFailureAfter int, notifiers []Notifier, logger *log.Logger) *Manager {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get hostname, using empty string")
+		hostname = ""
+	}
+
+	m := &Manager{
+		logger:       logger,
+		hostname:     hostname,
+		notifiers:    notifiers,
+		bySensor:     make(map[string][]*rule),
+		failureRules: make(map[string]*rule),
+		failures:     make(map[string]int),
+		activeMetric: newActiveMetric(""),
+	}
+	for _, s := range sensors {
+		for _, rc := range s.Alerts {
+			r := &rule{AlertRuleConfig: rc, sensor: s.Name}
+			m.rules = append(m.rules, r)
+			m.bySensor[s.Name] = append(m.bySensor[s.Name], r)
+		}
+		if sensorFailureAfter > 0 {
+			r := &rule{
+				AlertRuleConfig: config.AlertRuleConfig{
+					Name:       config.ReadFailureRuleName(s.Name),
+					Metric:     metricReadFailures,
+					Comparison: ">=",
+					Threshold:  float64(sensorFailureAfter),
+				},
+				sensor: s.Name,
+			}
+			m.rules = append(m.rules, r)
+			m.failureRules[s.Name] = r
+		}
+	}
+	return m
+}
+
+// RuleCount returns the number of configured rules.
+func (m *Manager) RuleCount() int {
+	return len(m.rules)
+}
+
+// Start implements sink.Sink and starts notifiers with background work.
+func (m *Manager) Start() error {
+	m.logger.WithFields(log.Fields{
+		"rules":     len(m.rules),
+		"notifiers": len(m.notifiers),
+	}).Info("Starting alert manager")
+
+	for _, n := range m.notifiers {
+		if l, ok := n.(lifecycle); ok {
+			if err := l.Start(); err != nil {
+				return fmt.Errorf("failed to start notifier %s: %w", n.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Write evaluates the rules of the reading's sensor and sends notifications for
+// alerts that fired or resolved. Failed readings only advance the sensor
+// failure rule and leave threshold alert states unchanged.
+func (m *Manager) Write(r sink.Reading) error {
+	m.mu.Lock()
+	var notifications []Notification
+	evaluate := func(rl *rule, value float64, unit string) {
+		switch rl.evaluate(value, r.Time) {
+		case transitionFired:
+			notifications = append(notifications, m.notification(rl, r, unit, StatusFiring))
+		case transitionResolved:
+			notifications = append(notifications, m.notification(rl, r, unit, StatusResolved))
+		}
+	}
+
+	if rl := m.failureRules[r.Sensor]; rl != nil {
+		if r.Err != nil {
+			m.failures[r.Sensor]++
+		} else {
+			m.failures[r.Sensor] = 0
+		}
+		evaluate(rl, float64(m.failures[r.Sensor]), "")
+	}
+
+	if r.Err == nil {
+		for _, rl := range m.bySensor[r.Sensor] {
+			if rl.Metric == "humidity" {
+				if math.IsNaN(r.Humidity) {
+					continue
+				}
+				evaluate(rl, r.Humidity, "%")
+			} else {
+				evaluate(rl, r.Temperature, r.Unit)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, n := range notifications {
+		m.logger.WithFields(log.Fields{
+			"rule":   n.Rule,
+			"sensor": n.Sensor,
+			"status": n.Status,
+			"value":  n.Value,
+		}).Warn("Alert " + n.Status)
+		if err := m.notify(n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notification builds the notification for a rule transition.
+func (m *Manager) notification(rl *rule, r sink.Reading, unit, status string) Notification {
+	n := Notification{
+		Status:     status,
+		Rule:       rl.Name,
+		Sensor:     r.Sensor,
+		GPIO:       r.GPIO,
+		Hostname:   m.hostname,
+		Metric:     rl.Metric,
+		Comparison: rl.Comparison,
+		Threshold:  rl.Threshold,
+		Value:      rl.lastValue,
+		Unit:       unit,
+		StartsAt:   rl.activeSince,
+		Labels:     rl.Labels,
+	}
+	if status == StatusResolved {
+		endsAt := r.Time
+		n.EndsAt = &endsAt
+	}
+	n.Summary = summary(n)
+	return n
+}
+
+// notify sends n to every notifier, continuing past failures.
+func (m *Manager) notify(n Notification) error {
+	var errs []error
+	for _, notifier := range m.notifiers {
+		if err := notifier.Notify(context.Background(), n); err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify %s of alert '%s': %w", notifier.Name(), n.Rule, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements sink.Sink and stops notifiers with background work.
+func (m *Manager) Close() error {
+	var errs []error
+	for _, n := range m.notifiers {
+		if l, ok := n.(lifecycle); ok {
+			if err := l.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close notifier %s: %w", n.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Describe sends the descriptor of the alert state metric to the provided channel.
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.activeMetric
+}
+
+// Collect sends the current state of every alert rule.
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rl := range m.rules {
+		active := 0.0
+		if rl.state == StateFiring {
+			active = 1
+		}
+		ch <- prometheus.MustNewConstMetric(m.activeMetric, prometheus.GaugeValue, active, rl.Name, rl.sensor)
+	}
+}
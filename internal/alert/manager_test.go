@@ -0,0 +1,231 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// fakeNotifier records every notification it receives
+type fakeNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+	err           error
+}
+
+func (f *fakeNotifier) Name() string {
+	return "fake"
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, n Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifications = append(f.notifications, n)
+	return f.err
+}
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func testSensors() []config.SensorConfig {
+	return []config.SensorConfig{
+		{
+			Name: "freezer",
+			GPIO: "GPIO4",
+			Alerts: []config.AlertRuleConfig{
+				{Name: "freezer-too-warm", Metric: "temperature", Comparison: ">", Threshold: -10, Hysteresis: 1},
+				{Name: "freezer-humid", Metric: "humidity", Comparison: ">=", Threshold: 80, For: time.Minute},
+			},
+		},
+		{Name: "office", GPIO: "GPIO17"},
+	}
+}
+
+func reading(sensor string, temperature, humidity float64, at time.Time) sink.Reading {
+	return sink.Reading{
+		Time:        at,
+		Sensor:      sensor,
+		GPIO:        "GPIO4",
+		Temperature: temperature,
+		Humidity:    humidity,
+		Unit:        "C",
+	}
+}
+
+func TestManager_FireAndResolve(t *testing.T) {
+	notifier := &fakeNotifier{}
+	m := NewManager(testSensors(), 0, []Notifier{notifier}, getSilentLogger())
+	start := time.Unix(1700000000, 0)
+
+	if m.RuleCount() != 2 {
+		t.Fatalf("RuleCount() = %d, want 2", m.RuleCount())
+	}
+
+	m.Write(reading("freezer", -12, 50, start))
+	m.Write(reading("freezer", -8, 50, start.Add(time.Minute)))
+	m.Write(reading("freezer", -10.5, 50, start.Add(2*time.Minute))) // within hysteresis
+	m.Write(reading("freezer", -11, 50, start.Add(3*time.Minute)))
+
+	if len(notifier.notifications) != 2 {
+		t.Fatalf("received %d notifications, want 2", len(notifier.notifications))
+	}
+
+	fired := notifier.notifications[0]
+	if fired.Status != StatusFiring || fired.Rule != "freezer-too-warm" || fired.Value != -8 || fired.Unit != "C" {
+		t.Errorf("unexpected firing notification: %+v", fired)
+	}
+	if !fired.StartsAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("StartsAt = %v, want %v", fired.StartsAt, start.Add(time.Minute))
+	}
+
+	resolved := notifier.notifications[1]
+	if resolved.Status != StatusResolved || resolved.Value != -11 {
+		t.Errorf("unexpected resolved notification: %+v", resolved)
+	}
+	if resolved.EndsAt == nil || !resolved.EndsAt.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("EndsAt = %v, want %v", resolved.EndsAt, start.Add(3*time.Minute))
+	}
+}
+
+func TestManager_HumidityRuleUsesPercent(t *testing.T) {
+	notifier := &fakeNotifier{}
+	m := NewManager(testSensors(), 0, []Notifier{notifier}, getSilentLogger())
+	start := time.Unix(1700000000, 0)
+
+	m.Write(reading("freezer", -20, 85, start))
+	m.Write(reading("freezer", -20, 85, start.Add(time.Minute)))
+
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("received %d notifications, want 1", len(notifier.notifications))
+	}
+	if n := notifier.notifications[0]; n.Rule != "freezer-humid" || n.Unit != "%" || n.Value != 85 {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+}
+
+// Failed readings and readings from other sensors must not affect alert state
+func TestManager_IgnoresFailedAndUnrelatedReadings(t *testing.T) {
+	notifier := &fakeNotifier{}
+	m := NewManager(testSensors(), 0, []Notifier{notifier}, getSilentLogger())
+	now := time.Unix(1700000000, 0)
+
+	failed := reading("freezer", 100, 100, now)
+	failed.Err = errors.New("checksum error")
+	m.Write(failed)
+	m.Write(reading("office", 100, 100, now))
+
+	if len(notifier.notifications) != 0 {
+		t.Errorf("received %d notifications, want 0", len(notifier.notifications))
+	}
+}
+
+func TestManager_NotifierError(t *testing.T) {
+	notifier := &fakeNotifier{err: errors.New("unreachable")}
+	m := NewManager(testSensors(), 0, []Notifier{notifier}, getSilentLogger())
+
+	if err := m.Write(reading("freezer", 0, 50, time.Unix(1700000000, 0))); err == nil {
+		t.Error("Write() expected error when the notifier fails, got nil")
+	}
+}
+
+func TestManager_Collect(t *testing.T) {
+	m := NewManager(testSensors(), 0, nil, getSilentLogger())
+	m.Write(reading("freezer", 0, 50, time.Unix(1700000000, 0)))
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+
+	if count := testutil.CollectAndCount(m); count != 2 {
+		t.Errorf("Collect() emitted %d metrics, want 2", count)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	active := make(map[string]float64)
+	for _, mf := range families {
+		for _, metric := range mf.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "rule" {
+					active[label.GetValue()] = metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	if active["freezer-too-warm"] != 1 {
+		t.Errorf("dht_alert_active{rule=freezer-too-warm} = %v, want 1", active["freezer-too-warm"])
+	}
+	if active["freezer-humid"] != 0 {
+		t.Errorf("dht_alert_active{rule=freezer-humid} = %v, want 0", active["freezer-humid"])
+	}
+}
+
+func TestManager_SensorFailureAlert(t *testing.T) {
+	notifier := &fakeNotifier{}
+	m := NewManager(testSensors(), 3, []Notifier{notifier}, getSilentLogger())
+	now := time.Unix(1700000000, 0)
+
+	// Two threshold rules plus one failure rule per sensor
+	if m.RuleCount() != 4 {
+		t.Fatalf("RuleCount() = %d, want 4", m.RuleCount())
+	}
+
+	failed := reading("office", 0, 0, now)
+	failed.Err = errors.New("timeout")
+	m.Write(failed)
+	m.Write(failed)
+	m.Write(reading("office", 21, 40, now)) // success resets the count
+	m.Write(failed)
+	m.Write(failed)
+	if len(notifier.notifications) != 0 {
+		t.Fatalf("received %d notifications before threshold, want 0", len(notifier.notifications))
+	}
+
+	m.Write(failed)
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("received %d notifications, want 1", len(notifier.notifications))
+	}
+	fired := notifier.notifications[0]
+	if fired.Rule != "office-read-failure" || fired.Status != StatusFiring || fired.Value != 3 {
+		t.Errorf("unexpected failure notification: %+v", fired)
+	}
+
+	m.Write(reading("office", 21, 40, now.Add(time.Minute)))
+	if len(notifier.notifications) != 2 || notifier.notifications[1].Status != StatusResolved {
+		t.Fatalf("expected resolved notification, got %+v", notifier.notifications)
+	}
+	if notifier.notifications[1].Summary != "sensor office is reading again" {
+		t.Errorf("Summary = %q", notifier.notifications[1].Summary)
+	}
+}
+
+func TestManager_RuleLabels(t *testing.T) {
+	sensors := testSensors()
+	sensors[0].Alerts[0].Labels = map[string]string{"severity": "critical"}
+	notifier := &fakeNotifier{}
+	m := NewManager(sensors, 0, []Notifier{notifier}, getSilentLogger())
+
+	m.Write(reading("freezer", 0, 50, time.Unix(1700000000, 0)))
+
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("received %d notifications, want 1", len(notifier.notifications))
+	}
+	if got := notifier.notifications[0].Labels["severity"]; got != "critical" {
+		t.Errorf("Labels[severity] = %q, want %q", got, "critical")
+	}
+}
@@ -0,0 +1,65 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// StatusFiring is the notification status sent when an alert starts firing.
+	StatusFiring = "firing"
+	// StatusResolved is the notification status sent when a firing alert clears.
+	StatusResolved = "resolved"
+)
+
+// Notification describes an alert firing or resolving.
+type Notification struct {
+	Status     string     `json:"status"`
+	Rule       string     `json:"rule"`
+	Sensor     string     `json:"sensor"`
+	GPIO       string     `json:"gpio"`
+	Hostname   string     `json:"hostname"`
+	Metric     string     `json:"metric"`
+	Comparison string     `json:"comparison"`
+	Threshold  float64    `json:"threshold"`
+	Value      float64    `json:"value"`
+	Unit       string     `json:"unit"`
+	StartsAt   time.Time  `json:"startsAt"`
+	EndsAt     *time.Time `json:"endsAt,omitempty"`
+	Summary    string     `json:"summary"`
+	// Labels are the extra labels configured on the rule.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Notifier delivers alert notifications to an external system.
+type Notifier interface {
+	// Name identifies the notifier in logs.
+	Name() string
+
+	// Notify sends a single notification.
+	Notify(ctx context.Context, n Notification) error
+}
+
+// lifecycle is implemented by notifiers that run background work, such as
+// periodically re-sending active alerts.
+type lifecycle interface {
+	Start() error
+	Close() error
+}
+
+// summary returns a human-readable description of the notification.
+func summary(n Notification) string {
+	if n.Metric == metricReadFailures {
+		if n.Status == StatusResolved {
+			return fmt.Sprintf("sensor %s is reading again", n.Sensor)
+		}
+		return fmt.Sprintf("sensor %s failed %g consecutive reads", n.Sensor, n.Value)
+	}
+	if n.Status == StatusResolved {
+		return fmt.Sprintf("%s of %s is back to %g%s (threshold %s %g%s)",
+			n.Metric, n.Sensor, n.Value, n.Unit, n.Comparison, n.Threshold, n.Unit)
+	}
+	return fmt.Sprintf("%s of %s is %g%s (threshold %s %g%s)",
+		n.Metric, n.Sensor, n.Value, n.Unit, n.Comparison, n.Threshold, n.Unit)
+}
@@ -0,0 +1,118 @@
+package alert
+
+import (
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// State is the evaluation state of an alert rule.
+type State int
+
+const (
+	// StateInactive means the condition does not hold.
+	StateInactive State = iota
+	// StatePending means the condition holds but not yet for the rule's For duration.
+	StatePending
+	// StateFiring means the condition has held for at least the For duration.
+	StateFiring
+)
+
+// String returns the lowercase name of the state.
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateFiring:
+		return "firing"
+	default:
+		return "inactive"
+	}
+}
+
+// transition is the notification-worthy outcome of evaluating a rule.
+type transition int
+
+const (
+	transitionNone transition = iota
+	transitionFired
+	transitionResolved
+)
+
+// rule tracks the state of a single alert rule for one sensor.
+type rule struct {
+	config.AlertRuleConfig
+	sensor string
+
+	state       State
+	activeSince time.Time
+	firedAt     time.Time
+	lastValue   float64
+}
+
+// breached reports whether value satisfies the alert condition.
+func (r *rule) breached(value float64) bool {
+	switch r.Comparison {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	}
+	return false
+}
+
+// cleared reports whether value is far enough past the threshold, by the
+// hysteresis margin, to resolve a firing alert.
+func (r *rule) cleared(value float64) bool {
+	switch r.Comparison {
+	case ">":
+		return value <= r.Threshold-r.Hysteresis
+	case ">=":
+		return value < r.Threshold-r.Hysteresis
+	case "<":
+		return value >= r.Threshold+r.Hysteresis
+	case "<=":
+		return value > r.Threshold+r.Hysteresis
+	}
+	return true
+}
+
+// evaluate advances the rule state with a new value observed at now.
+func (r *rule) evaluate(value float64, now time.Time) transition {
+	r.lastValue = value
+
+	switch r.state {
+	case StateInactive:
+		if !r.breached(value) {
+			return transitionNone
+		}
+		r.activeSince = now
+		r.state = StatePending
+		if r.For > 0 {
+			return transitionNone
+		}
+		fallthrough
+	case StatePending:
+		if !r.breached(value) {
+			r.state = StateInactive
+			return transitionNone
+		}
+		if now.Sub(r.activeSince) < r.For {
+			return transitionNone
+		}
+		r.state = StateFiring
+		r.firedAt = now
+		return transitionFired
+	case StateFiring:
+		if !r.cleared(value) {
+			return transitionNone
+		}
+		r.state = StateInactive
+		return transitionResolved
+	}
+	return transitionNone
+}
@@ -0,0 +1,142 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+func newRule(comparison string, threshold, hysteresis float64, forDuration time.Duration) *rule {
+	return &rule{
+		AlertRuleConfig: config.AlertRuleConfig{
+			Name:       "test",
+			Metric:     "temperature",
+			Comparison: comparison,
+			Threshold:  threshold,
+			Hysteresis: hysteresis,
+			For:        forDuration,
+		},
+		sensor: "freezer",
+	}
+}
+
+func TestRule_Comparisons(t *testing.T) {
+	tests := []struct {
+		comparison string
+		value      float64
+		breached   bool
+	}{
+		{">", 10.1, true},
+		{">", 10, false},
+		{">=", 10, true},
+		{">=", 9.9, false},
+		{"<", 9.9, true},
+		{"<", 10, false},
+		{"<=", 10, true},
+		{"<=", 10.1, false},
+	}
+
+	for _, tt := range tests {
+		r := newRule(tt.comparison, 10, 0, 0)
+		if got := r.breached(tt.value); got != tt.breached {
+			t.Errorf("breached(%v %s 10) = %v, want %v", tt.value, tt.comparison, got, tt.breached)
+		}
+	}
+}
+
+func TestRule_FiresImmediatelyWithoutFor(t *testing.T) {
+	r := newRule(">", -10, 0, 0)
+	now := time.Unix(1700000000, 0)
+
+	if tr := r.evaluate(-12, now); tr != transitionNone || r.state != StateInactive {
+		t.Errorf("evaluate(-12) = %v, state %v; want none, inactive", tr, r.state)
+	}
+	if tr := r.evaluate(-8, now); tr != transitionFired || r.state != StateFiring {
+		t.Errorf("evaluate(-8) = %v, state %v; want fired, firing", tr, r.state)
+	}
+	if tr := r.evaluate(-7, now); tr != transitionNone {
+		t.Errorf("evaluate(-7) while firing = %v, want none", tr)
+	}
+}
+
+func TestRule_ForDuration(t *testing.T) {
+	r := newRule(">", -10, 0, 5*time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	if tr := r.evaluate(-8, start); tr != transitionNone || r.state != StatePending {
+		t.Fatalf("evaluate() = %v, state %v; want none, pending", tr, r.state)
+	}
+	if tr := r.evaluate(-8, start.Add(4*time.Minute)); tr != transitionNone || r.state != StatePending {
+		t.Fatalf("evaluate() before For = %v, state %v; want none, pending", tr, r.state)
+	}
+	if tr := r.evaluate(-8, start.Add(5*time.Minute)); tr != transitionFired {
+		t.Fatalf("evaluate() after For = %v, want fired", tr)
+	}
+	if !r.activeSince.Equal(start) {
+		t.Errorf("activeSince = %v, want %v", r.activeSince, start)
+	}
+}
+
+// A pending alert whose condition clears must restart the For timer
+func TestRule_PendingResets(t *testing.T) {
+	r := newRule(">", -10, 0, 5*time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	r.evaluate(-8, start)
+	r.evaluate(-12, start.Add(3*time.Minute))
+	if r.state != StateInactive {
+		t.Fatalf("state = %v after condition cleared, want inactive", r.state)
+	}
+
+	r.evaluate(-8, start.Add(4*time.Minute))
+	if tr := r.evaluate(-8, start.Add(6*time.Minute)); tr != transitionNone {
+		t.Errorf("evaluate() = %v, want none since the For timer restarted", tr)
+	}
+}
+
+func TestRule_Hysteresis(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparison string
+		fire       float64
+		hold       float64
+		clear      float64
+	}{
+		{"above", ">", 31, 29.5, 29},
+		{"above or equal", ">=", 30, 29.5, 28.9},
+		{"below", "<", 29, 30.5, 31},
+		{"below or equal", "<=", 30, 30.5, 31.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRule(tt.comparison, 30, 1, 0)
+			now := time.Unix(1700000000, 0)
+
+			if tr := r.evaluate(tt.fire, now); tr != transitionFired {
+				t.Fatalf("evaluate(%v) = %v, want fired", tt.fire, tr)
+			}
+			// Back within the hysteresis band: still firing
+			if tr := r.evaluate(tt.hold, now); tr != transitionNone || r.state != StateFiring {
+				t.Errorf("evaluate(%v) = %v, state %v; want none, firing", tt.hold, tr, r.state)
+			}
+			if tr := r.evaluate(tt.clear, now); tr != transitionResolved || r.state != StateInactive {
+				t.Errorf("evaluate(%v) = %v, state %v; want resolved, inactive", tt.clear, tr, r.state)
+			}
+		})
+	}
+}
+
+func TestState_String(t *testing.T) {
+	tests := map[State]string{
+		StateInactive: "inactive",
+		StatePending:  "pending",
+		StateFiring:   "firing",
+	}
+	for state, want := range tests {
+		if got := state.String(); got != want {
+			t.Errorf("State(%d).String() = %q, want %q", state, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// defaultWebhookTimeout bounds a webhook request when no timeout is configured.
+const defaultWebhookTimeout = 10 * time.Second
+
+// Webhook posts notifications as JSON to a generic HTTP endpoint.
+// The body is either the notification itself or rendered from a template.
+type Webhook struct {
+	url     string
+	headers map[string]string
+	body    *template.Template
+	client  *http.Client
+}
+
+// templateFuncs are available in webhook body templates.
+// "json" encodes a value as a JSON literal so strings are safely quoted.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+// NewWebhook creates a webhook notifier.
+// Returns an error if the body template cannot be parsed.
+func NewWebhook(cfg *config.WebhookConfig) (*Webhook, error) {
+	var body *template.Template
+	if cfg.BodyTemplate != "" {
+		var err error
+		body, err = template.New("body").Funcs(templateFuncs).Option("missingkey=error").Parse(cfg.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook body template: %w", err)
+		}
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &Webhook{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		body:    body,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name returns the webhook URL.
+func (w *Webhook) Name() string {
+	return w.url
+}
+
+// Notify posts n to the webhook.
+// Returns an error if the request fails or the response status is not 2xx.
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	var payload []byte
+	if w.body != nil {
+		var buf bytes.Buffer
+		if err := w.body.Execute(&buf, n); err != nil {
+			return fmt.Errorf("failed to render webhook body: %w", err)
+		}
+		payload = buf.Bytes()
+	} else {
+		var err error
+		if payload, err = json.Marshal(n); err != nil {
+			return fmt.Errorf("failed to encode notification: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
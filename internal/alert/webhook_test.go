@@ -0,0 +1,125 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+func testNotification() Notification {
+	n := Notification{
+		Status:     StatusFiring,
+		Rule:       "freezer-too-warm",
+		Sensor:     "freezer",
+		GPIO:       "GPIO4",
+		Hostname:   "pi",
+		Metric:     "temperature",
+		Comparison: ">",
+		Threshold:  -10,
+		Value:      -8.5,
+		Unit:       "C",
+		StartsAt:   time.Unix(1700000000, 0).UTC(),
+	}
+	n.Summary = summary(n)
+	return n
+}
+
+// captureServer returns a server recording the last request body and headers
+func captureServer(t *testing.T, status int) (*httptest.Server, <-chan *http.Request, <-chan []byte) {
+	t.Helper()
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests, bodies
+}
+
+func TestWebhook_DefaultBody(t *testing.T) {
+	server, requests, bodies := captureServer(t, http.StatusOK)
+
+	w, err := NewWebhook(&config.WebhookConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook() returned unexpected error: %v", err)
+	}
+
+	if err := w.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify() returned unexpected error: %v", err)
+	}
+
+	req := <-requests
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization header = %q, want %q", got, "Bearer secret")
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type header = %q, want application/json", got)
+	}
+
+	var decoded Notification
+	if err := json.Unmarshal(<-bodies, &decoded); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if decoded.Rule != "freezer-too-warm" || decoded.Status != StatusFiring || decoded.Value != -8.5 {
+		t.Errorf("unexpected notification body: %+v", decoded)
+	}
+	if decoded.EndsAt != nil {
+		t.Errorf("EndsAt = %v for firing alert, want nil", decoded.EndsAt)
+	}
+}
+
+func TestWebhook_BodyTemplate(t *testing.T) {
+	server, _, bodies := captureServer(t, http.StatusNoContent)
+
+	w, err := NewWebhook(&config.WebhookConfig{
+		URL:          server.URL,
+		BodyTemplate: `{"text": {{ json .Summary }}, "state": "{{ .Status }}"}`,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook() returned unexpected error: %v", err)
+	}
+
+	if err := w.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify() returned unexpected error: %v", err)
+	}
+
+	want := `{"text": "temperature of freezer is -8.5C (threshold > -10C)", "state": "firing"}`
+	if got := string(<-bodies); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestWebhook_InvalidTemplate(t *testing.T) {
+	_, err := NewWebhook(&config.WebhookConfig{URL: "http://localhost", BodyTemplate: "{{ .Summary"})
+	if err == nil {
+		t.Error("NewWebhook() expected error for invalid template, got nil")
+	}
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	server, _, _ := captureServer(t, http.StatusInternalServerError)
+
+	w, err := NewWebhook(&config.WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewWebhook() returned unexpected error: %v", err)
+	}
+
+	if err := w.Notify(context.Background(), testNotification()); err == nil {
+		t.Error("Notify() expected error for 500 response, got nil")
+	}
+}
@@ -1,26 +1,132 @@
 package collector
 
 import (
+	"context"
+	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
 	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
 )
 
+// unitSuffixes maps unit symbols to the metric name suffix of quantities
+// other than temperature and humidity, following Prometheus naming conventions.
+var unitSuffixes = map[string]string{
+	sensor.UnitPascal:  "_pascals",
+	sensor.UnitPercent: "_percent",
+	"ppm":              "_ppm",
+	"lx":               "_lux",
+}
+
+// Options holds how a Collector names and labels the metrics of its sensor,
+// and exposes its temperature.
+type Options struct {
+	// Namespace prefixes the metric names, config.DefaultMetricsNamespace when empty.
+	Namespace string
+	// ConstLabels are added to the metrics, besides dht_name, hostname and gpio.
+	ConstLabels map[string]string
+	// DropLabels lists the labels among config.LabelHostname and
+	// config.LabelGPIO left out of the metrics.
+	DropLabels []string
+	// TemperatureUnit is the unit symbol of dht_temperature_degree, e.g.
+	// sensor.KelvinSymbol. The unit of the sensor when empty.
+	TemperatureUnit string
+	// TemperatureMetrics is one of config.TemperatureMetricsLegacy (the
+	// default when empty), config.TemperatureMetricsBase or
+	// config.TemperatureMetricsBoth.
+	TemperatureMetrics string
+	// StalePolicy is what is exposed when a read fails, one of
+	// config.StalePolicyDrop (the default when empty), config.StalePolicyServe
+	// or config.StalePolicyTimestamp.
+	StalePolicy string
+	// StaleAfter is how long the last successful reading is exposed, unless
+	// StalePolicy is config.StalePolicyDrop.
+	StaleAfter time.Duration
+}
+
+// MetricName returns name prefixed with the namespace of opts, e.g.
+// dht_humidity_percent for humidity_percent.
+func (o Options) MetricName(name string) string {
+	return config.MetricName(o.Namespace, name)
+}
+
+// SensorLabels returns the constant labels of the metrics of a sensor.
+func (o Options) SensorLabels(name, hostname, gpio string) prometheus.Labels {
+	labels := prometheus.Labels{"dht_name": name}
+	if !slices.Contains(o.DropLabels, config.LabelHostname) {
+		labels[config.LabelHostname] = hostname
+	}
+	if !slices.Contains(o.DropLabels, config.LabelGPIO) {
+		labels[config.LabelGPIO] = gpio
+	}
+	maps.Copy(labels, o.ConstLabels)
+	return labels
+}
+
+// WithLabels returns opts with labels added to its constant labels,
+// replacing those of the same name. Empty labels, which Prometheus treats as
+// absent, do not replace a constant label.
+func (o Options) WithLabels(labels map[string]string) Options {
+	constLabels := make(map[string]string, len(o.ConstLabels)+len(labels))
+	maps.Copy(constLabels, o.ConstLabels)
+	for name, value := range labels {
+		if value != "" || constLabels[name] == "" {
+			constLabels[name] = value
+		}
+	}
+	o.ConstLabels = constLabels
+	return o
+}
+
 // Collector implements the prometheus.Collector interface for DHT sensor metrics.
+// Temperature and humidity keep their historical metric names; every other
+// quantity measured by the sensor gets a dht_<quantity>_<unit> gauge, dht
+// being the namespace of the Options.
+// The sensor labels are constant labels of the descriptors, so that one
+// Collector per sensor can be registered in the same registry.
 type Collector struct {
-	sensor            sensor.Reader
+	sensor            sensor.MultiReader
 	logger            *log.Logger
 	hostname          string
+	temperatureUnit   string
 	temperatureMetric *prometheus.Desc
-	humidityMetric    *prometheus.Desc
+	// celsiusMetric is set when the temperature is exposed in the base unit.
+	celsiusMetric   *prometheus.Desc
+	humidityMetric  *prometheus.Desc
+	quantityMetrics map[string]*prometheus.Desc
+	// state and stateMetric are set for sensors reporting their health state.
+	state       sensor.StateReporter
+	stateMetric *prometheus.Desc
+	// power and powerCyclesMetric are set for sensors with a power switch.
+	power             sensor.PowerCycleReporter
+	powerCyclesMetric *prometheus.Desc
+	// stalePolicy and staleAfter select what is exposed when a read fails;
+	// ageMetric is set unless stalePolicy is config.StalePolicyDrop.
+	stalePolicy string
+	staleAfter  time.Duration
+	ageMetric   *prometheus.Desc
+
+	mu sync.Mutex
+	// last is the last successful reading, kept unless the stale policy is
+	// config.StalePolicyDrop.
+	last sensor.Reading
+
+	// timeNow is replaced in tests.
+	timeNow func() time.Time
 }
 
-// New creates a new Collector for the given sensor.
-// The hostname is retrieved once during initialization to avoid repeated lookups.
-func New(s sensor.Reader, logger *log.Logger) *Collector {
+// New creates a new Collector for the given sensor, exposing its temperature
+// as set by opts. The hostname is retrieved once during initialization to
+// avoid repeated lookups.
+func New(s sensor.Reader, opts Options, logger *log.Logger) *Collector {
 	logger.WithField("sensor", s.Name()).Debug("Creating Prometheus collector")
 
 	hostname, err := os.Hostname()
@@ -29,61 +135,217 @@ func New(s sensor.Reader, logger *log.Logger) *Collector {
 		hostname = ""
 	}
 
-	return &Collector{
-		sensor:   s,
-		logger:   logger,
-		hostname: hostname,
-		temperatureMetric: prometheus.NewDesc(
-			"dht_temperature_degree",
-			"Temperature degree measured by the sensor",
-			[]string{"dht_name", "hostname", "gpio", "unit"}, nil,
-		),
+	labels := opts.SensorLabels(s.Name(), hostname, s.GPIO())
+	c := &Collector{
+		sensor:          sensor.Extend(s),
+		logger:          logger,
+		hostname:        hostname,
+		temperatureUnit: opts.TemperatureUnit,
+		quantityMetrics: make(map[string]*prometheus.Desc),
+		stalePolicy:     opts.StalePolicy,
+		staleAfter:      opts.StaleAfter,
+		timeNow:         time.Now,
 		humidityMetric: prometheus.NewDesc(
-			"dht_humidity_percent",
+			opts.MetricName("humidity_percent"),
 			"Humidity percent measured by the sensor",
-			[]string{"dht_name", "hostname", "gpio"}, nil,
+			nil, labels,
 		),
 	}
+
+	if opts.TemperatureMetrics != config.TemperatureMetricsBase {
+		c.temperatureMetric = prometheus.NewDesc(
+			opts.MetricName("temperature_degree"),
+			"Temperature degree measured by the sensor",
+			[]string{"unit"}, labels,
+		)
+	}
+	if opts.TemperatureMetrics == config.TemperatureMetricsBase || opts.TemperatureMetrics == config.TemperatureMetricsBoth {
+		c.celsiusMetric = prometheus.NewDesc(
+			opts.MetricName("temperature_celsius"),
+			"Temperature measured by the sensor in degrees Celsius",
+			nil, labels,
+		)
+	}
+
+	if state, ok := s.(sensor.StateReporter); ok {
+		c.state = state
+		c.stateMetric = prometheus.NewDesc(
+			opts.MetricName("sensor_state"),
+			"Health state of the sensor, 1 for the current state",
+			[]string{"state"}, labels,
+		)
+	}
+
+	if power, ok := s.(sensor.PowerCycleReporter); ok {
+		if _, ok := power.PowerCycles(); ok {
+			c.power = power
+			c.powerCyclesMetric = prometheus.NewDesc(
+				opts.MetricName("sensor_power_cycles_total"),
+				"Number of times the sensor was power cycled after failed reads",
+				nil, labels,
+			)
+		}
+	}
+
+	switch opts.StalePolicy {
+	case config.StalePolicyServe, config.StalePolicyTimestamp:
+		c.ageMetric = prometheus.NewDesc(
+			opts.MetricName("reading_age_seconds"),
+			"Age of the exposed reading, the last successful one while reads fail",
+			nil, labels,
+		)
+	default:
+		c.stalePolicy = config.StalePolicyDrop
+	}
+
+	for _, q := range c.sensor.Quantities() {
+		switch q.Name {
+		case "", sensor.QuantityTemperature, sensor.QuantityHumidity:
+			continue
+		}
+		c.quantityMetrics[q.Name] = prometheus.NewDesc(
+			quantityMetricName(opts, q),
+			fmt.Sprintf("%s measured by the sensor", strings.ToUpper(q.Name[:1])+q.Name[1:]),
+			nil, labels,
+		)
+	}
+	return c
+}
+
+// quantityMetricName returns the metric name of a quantity other than
+// temperature and humidity, e.g. dht_pressure_pascals.
+func quantityMetricName(opts Options, q sensor.Quantity) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, q.Name)
+	return opts.MetricName(name + unitSuffixes[q.Unit])
 }
 
 // Describe sends the descriptors of the metrics to the provided channel.
 // This is required by the prometheus.Collector interface.
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.temperatureMetric
+	if c.temperatureMetric != nil {
+		ch <- c.temperatureMetric
+	}
+	if c.celsiusMetric != nil {
+		ch <- c.celsiusMetric
+	}
 	ch <- c.humidityMetric
+	for _, desc := range c.quantityMetrics {
+		ch <- desc
+	}
+	if c.stateMetric != nil {
+		ch <- c.stateMetric
+	}
+	if c.powerCyclesMetric != nil {
+		ch <- c.powerCyclesMetric
+	}
+	if c.ageMetric != nil {
+		ch <- c.ageMetric
+	}
 }
 
-// Collect reads sensor data and sends metrics to the provided channel.
-// If sensor reading fails, no metrics are emitted.
-// CRITICAL FIX: Uses GaugeValue instead of CounterValue (temperature/humidity are gauges, not counters)
-// CRITICAL FIX: Checks and handles sensor read errors instead of ignoring them
+// Collect reads sensor data and sends a gauge per measured quantity to the
+// provided channel. If sensor reading fails, no metrics are emitted, unless
+// the stale policy exposes the last successful reading.
+// The read has no deadline; Handler reads with the context of the scrape.
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	humidity, temperature, err := c.sensor.ReadData()
+	c.CollectContext(context.Background(), ch)
+}
+
+// CollectContext is Collect reading the sensor with ctx, so that the read
+// and its retries are abandoned once ctx is done.
+// The health state and power cycles of the sensor are sent whether the read
+// succeeds or not.
+func (c *Collector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	reading, err := c.sensor.Read(ctx)
+	if c.stateMetric != nil {
+		current := c.state.State()
+		for _, state := range sensor.States {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.stateMetric, prometheus.GaugeValue, value, state)
+		}
+	}
+	if c.powerCyclesMetric != nil {
+		cycles, _ := c.power.PowerCycles()
+		ch <- prometheus.MustNewConstMetric(c.powerCyclesMetric, prometheus.CounterValue, float64(cycles))
+	}
+	now := c.timeNow()
 	if err != nil {
-		// Error already logged by sensor.ReadData(), just skip metric collection
-		return
-	}
-
-	temperatureUnit := c.sensor.TemperatureUnit()
-
-	// CRITICAL FIX: Use GaugeValue instead of CounterValue
-	// Temperature and humidity are gauge metrics (can go up or down), not counters
-	ch <- prometheus.MustNewConstMetric(
-		c.temperatureMetric,
-		prometheus.GaugeValue, // Changed from CounterValue
-		temperature,
-		c.sensor.Name(),
-		c.hostname,
-		c.sensor.GPIO(),
-		temperatureUnit,
-	)
-
-	ch <- prometheus.MustNewConstMetric(
-		c.humidityMetric,
-		prometheus.GaugeValue, // Changed from CounterValue
-		humidity,
-		c.sensor.Name(),
-		c.hostname,
-		c.sensor.GPIO(),
-	)
+		var ok bool
+		if reading, ok = c.staleReading(now); !ok {
+			// Error already logged by the sensor, just skip metric collection
+			return
+		}
+	} else if c.stalePolicy != config.StalePolicyDrop {
+		if reading.Time.IsZero() {
+			reading.Time = now
+		}
+		c.mu.Lock()
+		c.last = reading
+		c.mu.Unlock()
+	}
+	if c.ageMetric != nil {
+		ch <- prometheus.MustNewConstMetric(c.ageMetric, prometheus.GaugeValue, now.Sub(reading.Time).Seconds())
+	}
+
+	for _, m := range reading.Measurements {
+		// Temperature and humidity are gauge metrics (can go up or down), not counters
+		switch m.Name {
+		case sensor.QuantityTemperature:
+			if c.temperatureMetric != nil {
+				unit := m.Unit
+				if c.temperatureUnit != "" {
+					unit = c.temperatureUnit
+				}
+				ch <- c.newMetric(c.temperatureMetric, reading.Time, sensor.ConvertTemperature(m.Value, m.Unit, unit), unit)
+			}
+			if c.celsiusMetric != nil {
+				ch <- c.newMetric(c.celsiusMetric, reading.Time, sensor.ConvertTemperature(m.Value, m.Unit, sensor.CelsiusSymbol))
+			}
+		case sensor.QuantityHumidity:
+			ch <- c.newMetric(c.humidityMetric, reading.Time, m.Value)
+		default:
+			desc, ok := c.quantityMetrics[m.Name]
+			if !ok {
+				c.logger.WithFields(log.Fields{
+					"sensor":   c.sensor.Name(),
+					"quantity": m.Name,
+				}).Debug("Skipping quantity not declared by the sensor")
+				continue
+			}
+			ch <- c.newMetric(desc, reading.Time, m.Value)
+		}
+	}
+}
+
+// staleReading returns the last successful reading, unless the stale policy
+// is config.StalePolicyDrop or the reading is older than the stale_after of
+// the sensor at now.
+func (c *Collector) staleReading(now time.Time) (sensor.Reading, bool) {
+	if c.stalePolicy == config.StalePolicyDrop {
+		return sensor.Reading{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last.Time.IsZero() || now.Sub(c.last.Time) > c.staleAfter {
+		return sensor.Reading{}, false
+	}
+	return c.last, true
+}
+
+// newMetric returns a gauge of a measurement of a reading taken at t, with t
+// as its timestamp under config.StalePolicyTimestamp.
+func (c *Collector) newMetric(desc *prometheus.Desc, t time.Time, value float64, labelValues ...string) prometheus.Metric {
+	m := prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	if c.stalePolicy == config.StalePolicyTimestamp {
+		return prometheus.NewMetricWithTimestamp(t, m)
+	}
+	return m
 }
@@ -1,13 +1,21 @@
 package collector
 
 import (
+	"context"
 	"errors"
 	"io"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
 )
 
 // mockSensor is a mock implementation of sensor.Reader for testing
@@ -20,7 +28,11 @@ type mockSensor struct {
 	unit        string
 }
 
-func (m *mockSensor) ReadData() (float64, float64, error) {
+// ReadData returns the mock values, or the context's error once ctx is done.
+func (m *mockSensor) ReadData(ctx context.Context) (float64, float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
 	return m.humidity, m.temperature, m.err
 }
 
@@ -36,6 +48,43 @@ func (m *mockSensor) GPIO() string {
 	return m.gpio
 }
 
+// temperatureOnlySensor is a mock sensor without a humidity sensor
+type temperatureOnlySensor struct {
+	mockSensor
+}
+
+func (m *temperatureOnlySensor) HasHumidity() bool {
+	return false
+}
+
+// pressureSensor is a mock sensor also measuring pressure
+type pressureSensor struct {
+	mockSensor
+	pressure float64
+}
+
+func (m *pressureSensor) Quantities() []sensor.Quantity {
+	return []sensor.Quantity{
+		{Name: sensor.QuantityTemperature, Unit: m.unit},
+		{Name: sensor.QuantityHumidity, Unit: sensor.UnitPercent},
+		{Name: sensor.QuantityPressure, Unit: sensor.UnitPascal},
+		{Name: "co2", Unit: "ppm"},
+	}
+}
+
+// Read returns no co2 value and an undeclared radiation value
+func (m *pressureSensor) Read(ctx context.Context) (sensor.Reading, error) {
+	if err := ctx.Err(); err != nil {
+		return sensor.Reading{}, err
+	}
+	return sensor.Reading{Measurements: []sensor.Measurement{
+		{Quantity: sensor.Quantity{Name: sensor.QuantityTemperature, Unit: m.unit}, Value: m.temperature},
+		{Quantity: sensor.Quantity{Name: sensor.QuantityHumidity, Unit: sensor.UnitPercent}, Value: m.humidity},
+		{Quantity: sensor.Quantity{Name: sensor.QuantityPressure, Unit: sensor.UnitPascal}, Value: m.pressure},
+		{Quantity: sensor.Quantity{Name: "radiation", Unit: "Sv"}, Value: 1},
+	}}, m.err
+}
+
 // getSilentLogger returns a logger that doesn't output anything
 func getSilentLogger() *log.Logger {
 	logger := log.New()
@@ -47,7 +96,7 @@ func TestNew(t *testing.T) {
 	logger := getSilentLogger()
 	mock := &mockSensor{name: "test-sensor", unit: "C"}
 
-	collector := New(mock, logger)
+	collector := New(mock, Options{}, logger)
 
 	if collector == nil {
 		t.Fatal("New() returned nil collector")
@@ -65,7 +114,7 @@ func TestNew(t *testing.T) {
 func TestDescribe(t *testing.T) {
 	logger := getSilentLogger()
 	mock := &mockSensor{name: "test-sensor", unit: "C"}
-	collector := New(mock, logger)
+	collector := New(mock, Options{}, logger)
 
 	ch := make(chan *prometheus.Desc, 10)
 	collector.Describe(ch)
@@ -92,7 +141,7 @@ func TestCollect_Success(t *testing.T) {
 		unit:        "C",
 	}
 
-	collector := New(mock, logger)
+	collector := New(mock, Options{}, logger)
 
 	ch := make(chan prometheus.Metric, 10)
 	collector.Collect(ch)
@@ -117,7 +166,7 @@ func TestCollect_SensorError(t *testing.T) {
 		err:  errors.New("sensor read failed"),
 	}
 
-	collector := New(mock, logger)
+	collector := New(mock, Options{}, logger)
 
 	ch := make(chan prometheus.Metric, 10)
 	collector.Collect(ch)
@@ -134,6 +183,22 @@ func TestCollect_SensorError(t *testing.T) {
 	}
 }
 
+func TestCollectContext_Cancelled(t *testing.T) {
+	mock := &mockSensor{name: "test-sensor", humidity: 60.0, temperature: 25.0, unit: "C"}
+	collector := New(mock, Options{}, getSilentLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.CollectContext(ctx, ch)
+	close(ch)
+
+	if count := len(ch); count != 0 {
+		t.Errorf("CollectContext() emitted %d metrics with a cancelled context, want 0", count)
+	}
+}
+
 // CRITICAL TEST: Verify metrics use GaugeValue, not CounterValue
 func TestCollect_MetricType(t *testing.T) {
 	logger := getSilentLogger()
@@ -144,7 +209,7 @@ func TestCollect_MetricType(t *testing.T) {
 		unit:        "C",
 	}
 
-	collector := New(mock, logger)
+	collector := New(mock, Options{}, logger)
 
 	ch := make(chan prometheus.Metric, 10)
 	collector.Collect(ch)
@@ -193,7 +258,7 @@ func TestCollect_MetricValues(t *testing.T) {
 				unit:        tt.unit,
 			}
 
-			collector := New(mock, logger)
+			collector := New(mock, Options{}, logger)
 
 			ch := make(chan prometheus.Metric, 10)
 			collector.Collect(ch)
@@ -239,7 +304,7 @@ func TestCollect_MetricLabels(t *testing.T) {
 		unit:        "C",
 	}
 
-	collector := New(mock, logger)
+	collector := New(mock, Options{}, logger)
 
 	ch := make(chan prometheus.Metric, 10)
 	collector.Collect(ch)
@@ -283,3 +348,419 @@ func TestCollect_MetricLabels(t *testing.T) {
 		}
 	}
 }
+
+// Sensors without humidity, such as DS18B20 probes, only expose temperature
+func TestCollect_TemperatureOnly(t *testing.T) {
+	mock := &temperatureOnlySensor{mockSensor{name: "tank", temperature: 12.5, unit: "C"}}
+	collector := New(mock, Options{}, getSilentLogger())
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.Collect(ch)
+	close(ch)
+
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Collect() emitted %d metrics, want 1", len(metrics))
+	}
+	if metrics[0].Desc() != collector.temperatureMetric {
+		t.Errorf("Collect() emitted %v, want the temperature metric", metrics[0].Desc())
+	}
+}
+
+// Quantities such as pressure get their own gauge; undeclared ones are ignored
+func TestCollect_Quantities(t *testing.T) {
+	mock := &pressureSensor{mockSensor{name: "attic", humidity: 55, temperature: 25.08, unit: "C"}, 100653.27}
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(New(mock, Options{}, getSilentLogger())); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, f := range families {
+		values[f.GetName()] = f.GetMetric()[0].GetGauge().GetValue()
+	}
+	if len(values) != 3 {
+		t.Errorf("Gather() returned %d families, want 3: %v", len(values), values)
+	}
+	if values["dht_pressure_pascals"] != 100653.27 {
+		t.Errorf("dht_pressure_pascals = %v, want 100653.27", values["dht_pressure_pascals"])
+	}
+}
+
+func TestCollect_TemperatureUnit(t *testing.T) {
+	mock := &mockSensor{name: "attic", humidity: 40, temperature: 20, unit: sensor.CelsiusSymbol}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		wantDegree  float64
+		wantUnit    string
+		wantCelsius bool
+	}{
+		{"legacy sensor unit", Options{}, 20, "C", false},
+		{"legacy fahrenheit", Options{TemperatureUnit: sensor.FahrenheitSymbol}, 68, "F", false},
+		{"both kelvin", Options{TemperatureUnit: sensor.KelvinSymbol, TemperatureMetrics: config.TemperatureMetricsBoth}, 293.15, "K", true},
+		{"base only", Options{TemperatureUnit: sensor.FahrenheitSymbol, TemperatureMetrics: config.TemperatureMetricsBase}, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			if err := reg.Register(New(mock, tt.opts, getSilentLogger())); err != nil {
+				t.Fatalf("Register() returned unexpected error: %v", err)
+			}
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Gather() returned unexpected error: %v", err)
+			}
+
+			metrics := make(map[string]*dto.Metric)
+			for _, f := range families {
+				metrics[f.GetName()] = f.GetMetric()[0]
+			}
+
+			degree, ok := metrics["dht_temperature_degree"]
+			if ok != (tt.wantUnit != "") {
+				t.Fatalf("dht_temperature_degree exposed = %v, want %v", ok, tt.wantUnit != "")
+			}
+			if ok {
+				if v := degree.GetGauge().GetValue(); math.Abs(v-tt.wantDegree) > 1e-9 {
+					t.Errorf("dht_temperature_degree = %v, want %v", v, tt.wantDegree)
+				}
+				for _, l := range degree.GetLabel() {
+					if l.GetName() == "unit" && l.GetValue() != tt.wantUnit {
+						t.Errorf("unit label = %q, want %q", l.GetValue(), tt.wantUnit)
+					}
+				}
+			}
+
+			celsius, ok := metrics["dht_temperature_celsius"]
+			if ok != tt.wantCelsius {
+				t.Fatalf("dht_temperature_celsius exposed = %v, want %v", ok, tt.wantCelsius)
+			}
+			if ok && celsius.GetGauge().GetValue() != 20 {
+				t.Errorf("dht_temperature_celsius = %v, want 20", celsius.GetGauge().GetValue())
+			}
+		})
+	}
+}
+
+func TestCollect_SensorLabelOverridingConstLabel(t *testing.T) {
+	opts := Options{
+		ConstLabels: map[string]string{"building": "hq"},
+		DropLabels:  []string{config.LabelHostname, config.LabelGPIO},
+	}
+	reg := prometheus.NewPedanticRegistry()
+	sensors := []struct {
+		sensor *mockSensor
+		labels map[string]string
+	}{
+		{&mockSensor{name: "annex", humidity: 50, temperature: 20, unit: "C"}, map[string]string{"building": "annex"}},
+		// An empty value, as given to sensors without the label, keeps the const label
+		{&mockSensor{name: "office", humidity: 50, temperature: 20, unit: "C"}, map[string]string{"building": ""}},
+	}
+	for _, s := range sensors {
+		if err := reg.Register(New(s.sensor, opts.WithLabels(s.labels), getSilentLogger())); err != nil {
+			t.Fatalf("Register() returned unexpected error: %v", err)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	want := map[string]string{"annex": "annex", "office": "hq"}
+	for _, f := range families {
+		if f.GetName() != "dht_temperature_degree" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["building"] != want[labels["dht_name"]] {
+				t.Errorf("%s building = %q, want %q", labels["dht_name"], labels["building"], want[labels["dht_name"]])
+			}
+		}
+	}
+}
+
+func TestCollect_NamespaceAndLabels(t *testing.T) {
+	mock := &pressureSensor{mockSensor{name: "attic", gpio: "GPIO4", humidity: 55, temperature: 25, unit: "C"}, 100000}
+	opts := Options{
+		Namespace:   "home",
+		ConstLabels: map[string]string{"building": "north", "room": "hall"},
+		DropLabels:  []string{config.LabelHostname},
+	}.WithLabels(map[string]string{"room": "attic"})
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(New(mock, opts, getSilentLogger())); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+
+	wantNames := []string{"home_humidity_percent", "home_pressure_pascals", "home_temperature_degree"}
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+		labels := make(map[string]string)
+		for _, l := range f.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		delete(labels, "unit")
+		want := map[string]string{"dht_name": "attic", "gpio": "GPIO4", "building": "north", "room": "attic"}
+		if !reflect.DeepEqual(labels, want) {
+			t.Errorf("%s labels = %v, want %v", f.GetName(), labels, want)
+		}
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("metric names = %v, want %v", names, wantNames)
+	}
+}
+
+func TestQuantityMetricName(t *testing.T) {
+	tests := []struct {
+		quantity sensor.Quantity
+		want     string
+	}{
+		{sensor.Quantity{Name: sensor.QuantityPressure, Unit: sensor.UnitPascal}, "dht_pressure_pascals"},
+		{sensor.Quantity{Name: "co2", Unit: "ppm"}, "dht_co2_ppm"},
+		{sensor.Quantity{Name: "soil moisture", Unit: "%"}, "dht_soil_moisture_percent"},
+		{sensor.Quantity{Name: "wind-speed", Unit: "m/s"}, "dht_wind_speed"},
+	}
+
+	for _, tt := range tests {
+		if got := quantityMetricName(Options{}, tt.quantity); got != tt.want {
+			t.Errorf("quantityMetricName(%v) = %q, want %q", tt.quantity, got, tt.want)
+		}
+	}
+}
+
+// Several sensors must be able to register their collectors side by side
+func TestRegister_MultipleSensors(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	for _, name := range []string{"living-room", "bedroom"} {
+		mock := &mockSensor{name: name, gpio: "GPIO4", humidity: 40, temperature: 20, unit: "C"}
+		if err := reg.Register(New(mock, Options{}, getSilentLogger())); err != nil {
+			t.Fatalf("Register(%s) returned unexpected error: %v", name, err)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	for _, f := range families {
+		if len(f.GetMetric()) != 2 {
+			t.Errorf("%s has %d series, want 2", f.GetName(), len(f.GetMetric()))
+		}
+		if f.GetName() != "dht_temperature_degree" {
+			continue
+		}
+		labels := make(map[string]string)
+		for _, l := range f.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["dht_name"] != "bedroom" || labels["gpio"] != "GPIO4" || labels["unit"] != "C" {
+			t.Errorf("labels = %v, want dht_name=bedroom, gpio=GPIO4 and unit=C", labels)
+		}
+	}
+}
+
+// The health state is exposed even when the read fails
+func TestCollect_State(t *testing.T) {
+	mock := &mockSensor{name: "attic", gpio: "GPIO4", unit: "C", err: errors.New("no response")}
+	health := sensor.NewHealth(mock, config.HealthConfig{DegradedAfter: 1, FailedAfter: 2, Backoff: time.Minute}, getSilentLogger())
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(New(health, Options{}, getSilentLogger())); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+
+	for _, want := range []string{sensor.StateDegraded, sensor.StateFailed} {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Gather() returned unexpected error: %v", err)
+		}
+		if len(families) != 1 || families[0].GetName() != "dht_sensor_state" {
+			t.Fatalf("families = %v, want only dht_sensor_state", families)
+		}
+
+		states := make(map[string]float64)
+		for _, m := range families[0].GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "state" {
+					states[l.GetValue()] = m.GetGauge().GetValue()
+				}
+			}
+		}
+		if len(states) != len(sensor.States) {
+			t.Errorf("states = %v, want one series per state", states)
+		}
+		for state, value := range states {
+			if (state == want) != (value == 1) {
+				t.Errorf("dht_sensor_state{state=%q} = %v, want %q as the current state", state, value, want)
+			}
+		}
+	}
+}
+
+// fakePower is a sensor.PowerSwitch doing nothing.
+type fakePower struct{}
+
+func (fakePower) SetPower(bool) error { return nil }
+
+func TestCollect_PowerCycles(t *testing.T) {
+	mock := &mockSensor{name: "attic", gpio: "GPIO4", unit: "C"}
+
+	without := New(sensor.NewHealth(mock, config.HealthConfig{}, getSilentLogger()), Options{}, getSilentLogger())
+	if without.powerCyclesMetric != nil {
+		t.Error("power cycles metric set for a sensor without power switch")
+	}
+
+	health := sensor.NewHealth(mock, config.HealthConfig{}, getSilentLogger())
+	health.SetPowerSwitch(fakePower{}, config.PowerConfig{GPIO: 22, CycleAfter: 3, OffTime: time.Second})
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(New(health, Options{}, getSilentLogger())); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() == "dht_sensor_power_cycles_total" {
+			if v := f.GetMetric()[0].GetCounter().GetValue(); v != 0 {
+				t.Errorf("dht_sensor_power_cycles_total = %v, want 0", v)
+			}
+			return
+		}
+	}
+	t.Error("Gather() returned no dht_sensor_power_cycles_total")
+}
+
+// timedSensor is a mock sensor whose readings are taken at a fixed time
+type timedSensor struct {
+	mockSensor
+	time time.Time
+}
+
+func (m *timedSensor) Quantities() []sensor.Quantity {
+	return []sensor.Quantity{
+		{Name: sensor.QuantityTemperature, Unit: m.unit},
+		{Name: sensor.QuantityHumidity, Unit: sensor.UnitPercent},
+	}
+}
+
+func (m *timedSensor) Read(ctx context.Context) (sensor.Reading, error) {
+	if m.err != nil {
+		return sensor.Reading{}, m.err
+	}
+	return sensor.Reading{Time: m.time, Measurements: []sensor.Measurement{
+		{Quantity: sensor.Quantity{Name: sensor.QuantityTemperature, Unit: m.unit}, Value: m.temperature},
+		{Quantity: sensor.Quantity{Name: sensor.QuantityHumidity, Unit: sensor.UnitPercent}, Value: m.humidity},
+	}}, nil
+}
+
+// collectMetrics collects c at now, by metric name
+func collectMetrics(t *testing.T, c *Collector, now time.Time) map[string]*dto.Metric {
+	t.Helper()
+	c.timeNow = func() time.Time { return now }
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+
+	metrics := make(map[string]*dto.Metric)
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatalf("Write() returned unexpected error: %v", err)
+		}
+		name := m.Desc().String()
+		switch {
+		case strings.Contains(name, `"dht_temperature_degree"`):
+			metrics["temperature"] = &metric
+		case strings.Contains(name, `"dht_humidity_percent"`):
+			metrics["humidity"] = &metric
+		case strings.Contains(name, `"dht_reading_age_seconds"`):
+			metrics["age"] = &metric
+		}
+	}
+	return metrics
+}
+
+func TestCollect_StalePolicy(t *testing.T) {
+	readAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy    string
+		stale     bool
+		timestamp bool
+	}{
+		{config.StalePolicyDrop, false, false},
+		{config.StalePolicyServe, true, false},
+		{config.StalePolicyTimestamp, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			mock := &timedSensor{mockSensor{name: "attic", gpio: "GPIO4", humidity: 55, temperature: 25, unit: "C"}, readAt}
+			c := New(mock, Options{StalePolicy: tt.policy, StaleAfter: 5 * time.Minute}, getSilentLogger())
+
+			metrics := collectMetrics(t, c, readAt.Add(time.Second))
+			if metrics["temperature"] == nil || metrics["humidity"] == nil {
+				t.Fatalf("fresh reading metrics = %v, want temperature and humidity", metrics)
+			}
+			if got := metrics["temperature"].TimestampMs != nil; got != tt.timestamp {
+				t.Errorf("fresh reading has timestamp = %v, want %v", got, tt.timestamp)
+			}
+
+			// Within stale_after, the last reading is served with its age
+			mock.err = errors.New("sensor read failed")
+			metrics = collectMetrics(t, c, readAt.Add(time.Minute))
+			if !tt.stale {
+				if len(metrics) != 0 {
+					t.Errorf("failed read metrics = %v, want none", metrics)
+				}
+				return
+			}
+			if got := metrics["temperature"].GetGauge().GetValue(); got != 25 {
+				t.Errorf("stale temperature = %v, want 25", got)
+			}
+			if got := metrics["humidity"].GetGauge().GetValue(); got != 55 {
+				t.Errorf("stale humidity = %v, want 55", got)
+			}
+			if got := metrics["age"].GetGauge().GetValue(); got != 60 {
+				t.Errorf("dht_reading_age_seconds = %v, want 60", got)
+			}
+			if tt.timestamp {
+				if got := metrics["humidity"].GetTimestampMs(); got != readAt.UnixMilli() {
+					t.Errorf("stale humidity timestamp = %d, want %d", got, readAt.UnixMilli())
+				}
+			} else if metrics["humidity"].TimestampMs != nil {
+				t.Error("stale humidity has a timestamp, want none")
+			}
+			if metrics["age"].TimestampMs != nil {
+				t.Error("dht_reading_age_seconds has a timestamp, want none")
+			}
+
+			// Beyond stale_after, nothing is served
+			if metrics = collectMetrics(t, c, readAt.Add(6*time.Minute)); len(metrics) != 0 {
+				t.Errorf("expired reading metrics = %v, want none", metrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// ScrapeTimeoutHeader is the header in which Prometheus sends the scrape timeout in seconds.
+	ScrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+	// DefaultScrapeTimeout bounds the sensor reads of scrapes not sending ScrapeTimeoutHeader.
+	DefaultScrapeTimeout = 10 * time.Second
+
+	// scrapeTimeoutMargin is kept from the scrape timeout to encode and send
+	// the response before Prometheus gives up.
+	scrapeTimeoutMargin = 500 * time.Millisecond
+)
+
+// scrapeContext returns the context of the scrape request r, with a deadline
+// shortly before the timeout sent by Prometheus, or DefaultScrapeTimeout.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := DefaultScrapeTimeout
+	if v := r.Header.Get(ScrapeTimeoutHeader); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds > 0 {
+			timeout = time.Duration(seconds * float64(time.Second))
+			// Keep the margin only when it leaves most of the timeout for reads
+			if timeout > 2*scrapeTimeoutMargin {
+				timeout -= scrapeTimeoutMargin
+			}
+		}
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// boundCollector collects a Collector with the context of a scrape.
+type boundCollector struct {
+	*Collector
+	ctx context.Context
+}
+
+func (b *boundCollector) Collect(ch chan<- prometheus.Metric) {
+	b.CollectContext(b.ctx, ch)
+}
+
+// Handler serves the metrics of gatherer and of the sensor collectors returned
+// by collectors, which is called on every scrape so that sensors initialized
+// late are included. Sensors are read with the context of the scrape request,
+// so reads and their retries stop when Prometheus disconnects or its scrape
+// timeout is near. Collectors that cannot be registered together with the
+// previous ones are skipped and reported to opts.ErrorLog.
+func Handler(gatherer prometheus.Gatherer, collectors func() []*Collector, opts promhttp.HandlerOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		sensors := prometheus.NewRegistry()
+		for _, c := range collectors() {
+			if err := sensors.Register(&boundCollector{Collector: c, ctx: ctx}); err != nil && opts.ErrorLog != nil {
+				opts.ErrorLog.Println("failed to register collector for sensor", c.sensor.Name(), err)
+			}
+		}
+		promhttp.HandlerFor(prometheus.Gatherers{gatherer, sensors}, opts).ServeHTTP(w, r)
+	})
+}
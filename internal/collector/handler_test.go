@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// slowSensor is a mock sensor whose reads last until ctx is done or
+// the delay elapses, and which records the deadline of the last read.
+type slowSensor struct {
+	mockSensor
+	delay    time.Duration
+	deadline chan time.Time
+}
+
+func (m *slowSensor) ReadData(ctx context.Context) (float64, float64, error) {
+	deadline, _ := ctx.Deadline()
+	m.deadline <- deadline
+	select {
+	case <-time.After(m.delay):
+		return m.humidity, m.temperature, m.err
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+}
+
+func newSlowSensor(delay time.Duration) *slowSensor {
+	return &slowSensor{
+		mockSensor: mockSensor{name: "slow", gpio: "GPIO4", humidity: 50, temperature: 20, unit: "C"},
+		delay:      delay,
+		deadline:   make(chan time.Time, 1),
+	}
+}
+
+// collectorsOf returns a collector source always returning collectors.
+func collectorsOf(collectors ...*Collector) func() []*Collector {
+	return func() []*Collector { return collectors }
+}
+
+// scrape requests the metrics of h with the given scrape timeout header.
+func scrape(t *testing.T, h http.Handler, timeout string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if timeout != "" {
+		req.Header.Set(ScrapeTimeoutHeader, timeout)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	return rec.Body.String()
+}
+
+func TestHandler_ScrapeTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		timeout time.Duration
+	}{
+		{"header", "5", 5*time.Second - scrapeTimeoutMargin},
+		{"short header keeps no margin", "0.8", 800 * time.Millisecond},
+		{"no header", "", DefaultScrapeTimeout},
+		{"invalid header", "soon", DefaultScrapeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSlowSensor(0)
+			h := Handler(prometheus.NewRegistry(), collectorsOf(New(s, Options{}, getSilentLogger())), promhttp.HandlerOpts{})
+
+			start := time.Now()
+			body := scrape(t, h, tt.header)
+			deadline := <-s.deadline
+			if got := deadline.Sub(start); got < tt.timeout-time.Second/10 || got > tt.timeout+time.Second/10 {
+				t.Errorf("read deadline in %v, want %v", got, tt.timeout)
+			}
+			if !strings.Contains(body, "dht_temperature_degree") {
+				t.Errorf("body = %q, want dht_temperature_degree", body)
+			}
+		})
+	}
+}
+
+func TestHandler_AbortsAtDeadline(t *testing.T) {
+	s := newSlowSensor(time.Minute)
+	fast := &mockSensor{name: "fast", gpio: "GPIO17", humidity: 40, temperature: 21, unit: "C"}
+	h := Handler(prometheus.NewRegistry(), collectorsOf(New(s, Options{}, getSilentLogger()), New(fast, Options{}, getSilentLogger())), promhttp.HandlerOpts{})
+
+	start := time.Now()
+	body := scrape(t, h, "0.2")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("scrape took %v, want the read abandoned after the 0.2s timeout", elapsed)
+	}
+	if strings.Contains(body, `dht_name="slow"`) {
+		t.Error("body has metrics of the sensor read past the deadline")
+	}
+	if !strings.Contains(body, `dht_name="fast"`) {
+		t.Error("body lacks metrics of the sensor read in time")
+	}
+}
+
+func TestHandler_Gatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "other_total", Help: "Other metric"}))
+	fast := &mockSensor{name: "fast", gpio: "GPIO17", unit: "C"}
+	h := Handler(reg, collectorsOf(New(fast, Options{}, getSilentLogger())), promhttp.HandlerOpts{})
+
+	body := scrape(t, h, "")
+	if !strings.Contains(body, "other_total") || !strings.Contains(body, "dht_temperature_degree") {
+		t.Errorf("body = %q, want metrics of the gatherer and the sensors", body)
+	}
+}
+
+// A sensor clashing with another is skipped without failing the scrape
+func TestHandler_DuplicateSensors(t *testing.T) {
+	a := &mockSensor{name: "same", gpio: "GPIO4", temperature: 20, unit: "C"}
+	b := &mockSensor{name: "same", gpio: "GPIO4", temperature: 30, unit: "C"}
+	var errors strings.Builder
+	h := Handler(prometheus.NewRegistry(), collectorsOf(New(a, Options{}, getSilentLogger()), New(b, Options{}, getSilentLogger())), promhttp.HandlerOpts{
+		ErrorLog: log.New(&errors, "", 0),
+	})
+
+	body := scrape(t, h, "")
+	if !strings.Contains(body, `dht_temperature_degree{dht_name="same"`) || strings.Contains(body, " 30\n") {
+		t.Errorf("body = %q, want the first sensor only", body)
+	}
+	if !strings.Contains(errors.String(), "same") {
+		t.Errorf("error log = %q, want the skipped sensor", errors.String())
+	}
+}
+
+// Collectors are listed on every scrape
+func TestHandler_CollectorsChange(t *testing.T) {
+	var collectors []*Collector
+	h := Handler(prometheus.NewRegistry(), func() []*Collector { return collectors }, promhttp.HandlerOpts{})
+
+	if body := scrape(t, h, ""); strings.Contains(body, "dht_temperature_degree") {
+		t.Errorf("body = %q, want no sensor metrics", body)
+	}
+	collectors = append(collectors, New(&mockSensor{name: "late", gpio: "GPIO4", unit: "C"}, Options{}, getSilentLogger()))
+	if body := scrape(t, h, ""); !strings.Contains(body, `dht_name="late"`) {
+		t.Errorf("body = %q, want metrics of the added sensor", body)
+	}
+}
@@ -2,23 +2,391 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// DefaultPollInterval is how often sensors are read for sinks when
+// poll_interval is not set.
+const DefaultPollInterval = 30 * time.Second
+
+// DefaultExecTimeout is how long an exec sensor command may run when exec_timeout is not set.
+const DefaultExecTimeout = 10 * time.Second
+
+// Defaults of the modbus backend settings.
+const (
+	DefaultModbusBaudRate = 9600
+	DefaultModbusSlaveID  = 1
+	DefaultModbusFunction = 3
+	DefaultModbusTimeout  = time.Second
+)
+
+// Defaults of the sensor health settings.
+const (
+	DefaultHealthDegradedAfter = 1
+	DefaultHealthFailedAfter   = 5
+	DefaultHealthBackoff       = 30 * time.Second
+	DefaultHealthBackoffMax    = 10 * time.Minute
+)
+
+// Defaults of the sensor power cycling settings.
+const (
+	DefaultPowerCycleAfter = 3
+	DefaultPowerOffTime    = 5 * time.Second
+	DefaultPowerWarmUp     = 2 * time.Second
+)
+
+// Stale policies of sensors, selected by stale_policy: what is exposed when a
+// read fails.
+const (
+	// StalePolicyDrop exposes no reading until a read succeeds again.
+	StalePolicyDrop = "drop"
+	// StalePolicyServe exposes the last successful reading for up to stale_after.
+	StalePolicyServe = "serve"
+	// StalePolicyTimestamp is StalePolicyServe with the time of the reading as
+	// the sample timestamp, for fresh readings too.
+	StalePolicyTimestamp = "timestamp"
+)
+
+// DefaultStaleAfter is how long the last successful reading of a sensor is
+// exposed when its reads fail and stale_after is not set.
+const DefaultStaleAfter = 5 * time.Minute
+
+// DefaultExecMaxConcurrent is how many exec sensor commands may run at once
+// when exec_max_concurrent is not set.
+const DefaultExecMaxConcurrent = 4
+
+// DefaultSinkQueueSize is the number of readings buffered per sink when
+// queue_size is not set.
+const DefaultSinkQueueSize = 100
+
+const (
+	// SinkPolicyDrop discards new readings while a sink's queue is full.
+	SinkPolicyDrop = "drop"
+	// SinkPolicyBlock makes the poller wait until a sink's queue has room.
+	SinkPolicyBlock = "block"
+)
+
+// DefaultMetricsNamespace is the prefix of the metric names when
+// metrics_namespace is not set.
+const DefaultMetricsNamespace = "dht"
+
+// MetricName returns name prefixed with namespace, or with
+// DefaultMetricsNamespace when namespace is empty, e.g. dht_sink_writes_total
+// for sink_writes_total.
+func MetricName(namespace, name string) string {
+	if namespace == "" {
+		namespace = DefaultMetricsNamespace
+	}
+	return namespace + "_" + name
+}
+
+// Labels of the sensor metrics that can be dropped with drop_labels.
+const (
+	LabelHostname = "hostname"
+	LabelGPIO     = "gpio"
+)
+
+// reservedLabels are the label names set by the exporter on sensor metrics,
+// which const_labels and sensor labels may not use.
+var reservedLabels = []string{
+	"dht_name", LabelHostname, LabelGPIO, "unit", "state", "error", "model", "backend",
+	"temperature_scale", "humidity_scale", "window", "rule",
+}
+
+// reservedAlertLabels are the labels identifying the alerts of a rule in
+// notifications, on which Alertmanager groups and deduplicates them, which
+// the labels of alert rules may not replace.
+var reservedAlertLabels = []string{"alertname", "dht_name", LabelHostname, LabelGPIO, "metric"}
+
+// metricNameRE matches valid metric namespaces and label names.
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Temperature metrics exposed by sensor collectors, selected by temperature_metrics.
+const (
+	// TemperatureMetricsLegacy exposes dht_temperature_degree in the sensor's
+	// temperature_unit, with a unit label, as before temperature_metrics existed.
+	TemperatureMetricsLegacy = "legacy"
+	// TemperatureMetricsBase exposes dht_temperature_celsius, in the Prometheus base unit.
+	TemperatureMetricsBase = "base"
+	// TemperatureMetricsBoth exposes both dht_temperature_degree and dht_temperature_celsius.
+	TemperatureMetricsBoth = "both"
+)
+
 // SensorConfig holds the configuration for a single DHT sensor.
 type SensorConfig struct {
-	Name            string
-	GPIO            string
-	MaxRetries      int
+	Name       string
+	GPIO       string
+	Pin        int
+	MaxRetries int
+	// TemperatureUnit is the unit of the exposed and polled temperatures
+	// ("celsius", "fahrenheit" or "kelvin"); Fahrenheit when empty. Sensors
+	// are read in Celsius and converted by the exporter.
+	TemperatureUnit string
+	// Model is the sensor model exposed by dht_sensor_info, e.g. "AM2302".
+	// Defaults to the model read by the backend.
+	Model string
+	// Backend selects the driver used to talk to the sensor ("periph", "gpiocdev",
+	// "iio", "ds18b20", "bme280", "sht3x", "aht20", "exec" or "modbus").
+	Backend string
+	// GPIOChip is the GPIO character device used by the gpiocdev backend.
+	GPIOChip string
+	// IIODevice selects the kernel IIO device used by the iio backend, either by
+	// its name (e.g. "dht11@4") or by its sysfs path.
+	IIODevice string
+	// W1Device selects the DS18B20 probe used by the ds18b20 backend, either by
+	// its ID (e.g. "28-0316a2794fff") or by its sysfs path. When empty, every
+	// probe on the bus is discovered.
+	W1Device string
+	// I2CBus is the i2c-dev bus of the I2C backends, e.g. "/dev/i2c-1".
+	I2CBus string
+	// I2CAddress is the sensor address on the bus. Zero selects the sensor's default address.
+	I2CAddress int
+	// ExecCommand is the program and arguments run by the exec backend.
+	// It is run directly, without a shell.
+	ExecCommand []string
+	// ExecTimeout bounds the run time of ExecCommand.
+	ExecTimeout time.Duration
+	// ExecEnv is the environment of ExecCommand as KEY=value entries.
+	// The exporter's own environment is not inherited.
+	ExecEnv []string
+	// ExecDir is the working directory of ExecCommand.
+	ExecDir string
+	// Modbus holds the settings of the modbus backend.
+	Modbus ModbusConfig
+	// Health holds the thresholds of the sensor health state.
+	Health HealthConfig
+	// Power holds the power cycling settings of the sensor.
+	Power PowerConfig
+	// StalePolicy is what is exposed when a read fails, one of
+	// StalePolicyDrop (the default), StalePolicyServe or StalePolicyTimestamp.
+	StalePolicy string
+	// StaleAfter is how long the last successful reading is exposed, unless
+	// StalePolicy is StalePolicyDrop.
+	StaleAfter time.Duration
+	// Labels are constant labels added to the metrics of the sensor, such as
+	// its room. Every sensor has the label names of all the sensors, as
+	// Prometheus requires for metrics of the same name, with the value of the
+	// const label of the same name or empty when not set.
+	Labels map[string]string
+	Alerts []AlertRuleConfig
+}
+
+// HealthConfig holds when a sensor is considered degraded or failed, and how
+// often a failed sensor is probed for recovery.
+type HealthConfig struct {
+	// DegradedAfter is the number of consecutive failed reads making the sensor degraded.
+	DegradedAfter int
+	// FailedAfter is the number of consecutive failed reads making the sensor failed.
+	// A failed sensor is only read by probes.
+	FailedAfter int
+	// Backoff is the wait before the first probe of a failed sensor. It doubles
+	// after every failed probe, up to BackoffMax.
+	Backoff    time.Duration
+	BackoffMax time.Duration
+}
+
+// ModbusConfig holds the settings of a Modbus RTU or TCP transmitter.
+type ModbusConfig struct {
+	// Address is the "host:port" of a Modbus TCP server or gateway. Exactly one
+	// of Address and Device is set.
+	Address string
+	// Device is the serial device of a Modbus RTU bus, e.g. "/dev/ttyUSB0".
+	Device   string
+	BaudRate int
+	// Parity is "none", "even" or "odd".
+	Parity  string
+	SlaveID int
+	// Function is 3 (read holding registers) or 4 (read input registers).
+	Function int
+	// TemperatureRegister is the address of the temperature value, in degrees Celsius
+	// once scaled.
+	TemperatureRegister int
+	// HumidityRegister is the address of the humidity value, or -1 when the
+	// transmitter has none.
+	HumidityRegister int
+	TemperatureScale float64
+	HumidityScale    float64
+	// DataType is the register value type: "int16", "uint16", "int32", "uint32" or "float32".
+	DataType string
+	// ByteOrder is the byte order of values, with A the most significant byte:
+	// "ABCD" (big endian), "DCBA", "BADC" or "CDAB".
+	ByteOrder string
+	Timeout   time.Duration
+}
+
+// PowerConfig holds the power cycling of a sensor powered through a GPIO pin,
+// for sensors such as the DHT22 that latch up until their power is removed.
+type PowerConfig struct {
+	// GPIO is the pin driven high to power the sensor, on the sensor's gpio_chip,
+	// or -1 when the sensor cannot be power cycled.
+	GPIO int
+	// CycleAfter is the number of consecutive failed reads triggering a power cycle.
+	CycleAfter int
+	// OffTime is how long the pin is driven low.
+	OffTime time.Duration
+	// WarmUp is how long the sensor is left to start before being read again.
+	WarmUp time.Duration
+}
+
+// AlertRuleConfig holds a threshold alert rule evaluated on every polled reading of a sensor.
+type AlertRuleConfig struct {
+	// Name identifies the rule in notifications and the rule metric label.
+	// Defaults to "<sensor>-<metric>".
+	Name string
+	// Metric is the measured quantity ("temperature" or "humidity").
+	Metric string
+	// Comparison is one of ">", ">=", "<" or "<=".
+	Comparison string
+	// Threshold is compared against the reading, in the sensor's temperature unit.
+	Threshold float64
+	// For is how long the condition must hold before the alert fires.
+	For time.Duration
+	// Hysteresis is how far back past the threshold the value must move to resolve the alert.
+	Hysteresis float64
+	// Labels are extra labels attached to notifications, e.g. severity for Alertmanager routing.
+	Labels map[string]string
+}
+
+// WebhookConfig holds a generic webhook receiving alert notifications.
+type WebhookConfig struct {
+	URL string
+	// BodyTemplate is a Go text/template rendering the JSON request body.
+	// The notification is sent as JSON when empty.
+	BodyTemplate string
+	Headers      map[string]string
+	Timeout      time.Duration
+}
+
+// AlertmanagerConfig holds an Alertmanager instance receiving alerts through its v2 API.
+type AlertmanagerConfig struct {
+	// URL is the Alertmanager base URL, e.g. "http://alertmanager:9093".
+	URL     string
+	Timeout time.Duration
+	// ResendInterval is how often active alerts are re-sent.
+	ResendInterval time.Duration
+}
+
+// AlertingConfig holds the notification targets for alert rules.
+type AlertingConfig struct {
+	Webhooks      []WebhookConfig
+	Alertmanagers []AlertmanagerConfig
+	// SensorFailureAfter fires a read-failure alert for a sensor after this many
+	// consecutive failed polls. Zero disables sensor failure alerts.
+	SensorFailureAfter int
+	// ExternalURL is the exporter URL used as the alert generator URL.
+	// Defaults to "http://<hostname>:<listen_port>/".
+	ExternalURL string
+}
+
+// DefaultIngestStaleAfter is how long remote sensors are exposed after their
+// last reading when stale_after is not set.
+const DefaultIngestStaleAfter = 5 * time.Minute
+
+// IngestDeviceConfig holds a remote device allowed to post readings to the ingest endpoint.
+type IngestDeviceConfig struct {
+	// Name identifies the device and is used as the gpio label of its sensors.
+	Name string
+	// Token authenticates the device with an "Authorization: Bearer <token>" header.
+	Token string
+	// TemperatureUnit is the unit of posted temperatures ("celsius", "fahrenheit" or "kelvin").
 	TemperatureUnit string
+	// StaleAfter removes a sensor of this device from /metrics when it has not
+	// been updated for this long. Defaults to the ingest stale_after.
+	StaleAfter time.Duration
+}
+
+// IngestConfig holds the remote sensor ingestion endpoint configuration.
+// The endpoint is enabled when at least one device is configured.
+type IngestConfig struct {
+	Devices    []IngestDeviceConfig
+	StaleAfter time.Duration
+	// Labels are the labels of the remote sensors: the label names of the
+	// local sensors, with the values of a local sensor not setting them.
+	Labels map[string]string
+}
+
+// CollectorsConfig selects the optional collectors of the exporter.
+type CollectorsConfig struct {
+	// Go exposes the Go runtime metrics (go_*) of the exporter.
+	Go bool
+	// Process exposes the process metrics (process_*) of the exporter.
+	Process bool
+	// Host exposes the temperature of the host thermal zones, such as the SoC,
+	// and the throttling state of Raspberry Pi hosts.
+	Host bool
+}
+
+// SinkConfig holds the configuration for a single output sink.
+// Sinks receive every polled reading independently of Prometheus scrapes.
+type SinkConfig struct {
+	// Name identifies the sink in logs and metrics. Defaults to Type.
+	Name string
+	// Type selects the sink implementation ("graphite", "statsd" or "file").
+	Type string
+	// QueueSize bounds the number of readings buffered for the sink.
+	QueueSize int
+	// Policy is applied when the queue is full (SinkPolicyDrop or SinkPolicyBlock).
+	Policy string
+	// Address is the host:port of the remote endpoint.
+	Address string
+	// Prefix is substituted for {prefix} in the metric path template.
+	Prefix string
+	// Template is the metric path template, e.g. "{prefix}.{hostname}.{dht_name}.{metric}".
+	Template string
+
+	// Path is the file written by the file sink.
+	Path string
+	// Format is the file sink line format ("csv" or "jsonl").
+	Format string
+	// MaxSizeMB rotates the file once it exceeds this size. Zero disables size rotation.
+	MaxSizeMB int
+	// RotateInterval rotates the file after this period. Zero disables time rotation.
+	RotateInterval time.Duration
+	// Compress gzips rotated files.
+	Compress bool
+	// MaxFiles is the number of rotated files kept. Zero keeps all of them.
+	MaxFiles int
+	// SyncInterval is how often buffered lines are flushed and fsynced.
+	SyncInterval time.Duration
 }
 
 // Config holds the application configuration loaded from YAML file.
 type Config struct {
-	Sensors    []SensorConfig
-	ListenPort int
-	LogLevel   string
+	Sensors      []SensorConfig
+	Sinks        []SinkConfig
+	Alerting     AlertingConfig
+	Ingest       IngestConfig
+	PollInterval time.Duration
+	// ExecMaxConcurrent bounds the number of exec sensor commands running at once.
+	ExecMaxConcurrent int
+	// FailFast makes the exporter exit when a sensor fails to initialize,
+	// instead of retrying it in the background.
+	FailFast bool
+	// TemperatureMetrics selects the temperature metrics of sensors, one of
+	// TemperatureMetricsLegacy, TemperatureMetricsBase or TemperatureMetricsBoth.
+	TemperatureMetrics string
+	// MetricsNamespace prefixes the names of the sensor metrics.
+	MetricsNamespace string
+	// ConstLabels are constant labels added to the metrics of every sensor.
+	ConstLabels map[string]string
+	// DropLabels lists the labels removed from sensor metrics, among
+	// LabelHostname and LabelGPIO.
+	DropLabels []string
+	Collectors CollectorsConfig
+	// StatsWindows are the durations of the sliding windows of the polled
+	// readings whose minimum, maximum, mean and standard deviation are exposed.
+	StatsWindows []time.Duration
+	// TrendWindow is the duration of the sliding window of the polled readings
+	// whose rate of change is fitted. Zero disables trends.
+	TrendWindow time.Duration
+	ListenPort  int
+	LogLevel    string
 }
 
 // Load reads and validates the configuration from the default locations.
@@ -50,26 +418,855 @@ func Load() (*Config, error) {
 			sensor := SensorConfig{
 				Name:            getString(sensorMap, "name"),
 				GPIO:            fmt.Sprintf("GPIO%d", getInt(sensorMap, "gpio_pin")),
+				Pin:             getInt(sensorMap, "gpio_pin"),
 				MaxRetries:      getInt(sensorMap, "max_retries"),
 				TemperatureUnit: getString(sensorMap, "temperature_unit"),
+				Model:           getString(sensorMap, "model"),
+				Backend:         getString(sensorMap, "backend"),
+				GPIOChip:        getString(sensorMap, "gpio_chip"),
+				IIODevice:       getString(sensorMap, "iio_device"),
+				W1Device:        getString(sensorMap, "w1_device"),
+				I2CBus:          getString(sensorMap, "i2c_bus"),
+				I2CAddress:      getInt(sensorMap, "i2c_address"),
+				ExecCommand:     getStringSlice(sensorMap, "exec_command"),
+				ExecEnv:         getStringSlice(sensorMap, "exec_env"),
+				ExecDir:         getString(sensorMap, "exec_dir"),
+				Labels:          getStringMap(sensorMap, "labels"),
+			}
+			if err := loadExec(&sensor, sensorMap); err != nil {
+				return nil, err
+			}
+			if err := loadModbus(&sensor, sensorMap); err != nil {
+				return nil, err
+			}
+			if err := loadHealth(&sensor, sensorMap); err != nil {
+				return nil, err
+			}
+			if err := loadPower(&sensor, sensorMap); err != nil {
+				return nil, err
+			}
+			if err := loadStale(&sensor, sensorMap); err != nil {
+				return nil, err
+			}
+			alerts, err := loadAlertRules(sensorMap, sensor.Name)
+			if err != nil {
+				return nil, err
+			}
+			sensor.Alerts = alerts
+			if err := checkTemperatureOnlyAlerts(&sensor); err != nil {
+				return nil, err
+			}
+			if err := checkI2CAddress(&sensor); err != nil {
+				return nil, err
+			}
+			if err := checkTemperatureUnit(&sensor); err != nil {
+				return nil, err
+			}
+			if err := checkLabels(sensor.Labels, reservedLabels, fmt.Sprintf("labels of sensor '%s'", sensor.Name)); err != nil {
+				return nil, err
 			}
 			sensors = append(sensors, sensor)
 		}
 	}
 
-	if len(sensors) == 0 {
+	sinks, err := loadSinks()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := loadWebhooks()
+	if err != nil {
+		return nil, err
+	}
+
+	alertmanagers, err := loadAlertmanagers()
+	if err != nil {
+		return nil, err
+	}
+
+	ingest, err := loadIngest()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIngestDeviceNames(sensors, ingest.Devices); err != nil {
+		return nil, err
+	}
+
+	// A gateway may only expose remote sensors
+	if len(sensors) == 0 && len(ingest.Devices) == 0 {
 		return nil, fmt.Errorf("no sensors configured")
 	}
 
+	sensorFailureAfter := viper.GetInt("alerting.sensor_failure_after")
+	if sensorFailureAfter < 0 {
+		return nil, fmt.Errorf("alerting.sensor_failure_after must not be negative")
+	}
+	if err := checkAlertRuleNames(sensors, sensorFailureAfter); err != nil {
+		return nil, err
+	}
+
+	pollInterval := DefaultPollInterval
+	if viper.IsSet("poll_interval") {
+		pollInterval, err = time.ParseDuration(viper.GetString("poll_interval"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid poll_interval: %w", err)
+		}
+		if pollInterval <= 0 {
+			return nil, fmt.Errorf("poll_interval must be positive")
+		}
+	}
+
+	execMaxConcurrent := DefaultExecMaxConcurrent
+	if viper.IsSet("exec_max_concurrent") {
+		execMaxConcurrent = viper.GetInt("exec_max_concurrent")
+		if execMaxConcurrent <= 0 {
+			return nil, fmt.Errorf("exec_max_concurrent must be positive")
+		}
+	}
+
+	temperatureMetrics := TemperatureMetricsLegacy
+	if viper.IsSet("temperature_metrics") {
+		temperatureMetrics = viper.GetString("temperature_metrics")
+		switch temperatureMetrics {
+		case TemperatureMetricsLegacy, TemperatureMetricsBase, TemperatureMetricsBoth:
+		default:
+			return nil, fmt.Errorf("invalid temperature_metrics '%s' (want legacy, base or both)", temperatureMetrics)
+		}
+	}
+
+	metricsNamespace := DefaultMetricsNamespace
+	if viper.IsSet("metrics_namespace") {
+		metricsNamespace = viper.GetString("metrics_namespace")
+		if !metricNameRE.MatchString(metricsNamespace) {
+			return nil, fmt.Errorf("invalid metrics_namespace '%s' (want letters, digits and underscores, not starting with a digit)", metricsNamespace)
+		}
+	}
+
+	constLabels := viper.GetStringMapString("const_labels")
+	if err := checkLabels(constLabels, reservedLabels, "const_labels"); err != nil {
+		return nil, err
+	}
+	ingest.Labels = fillSensorLabels(sensors, constLabels)
+
+	dropLabels := viper.GetStringSlice("drop_labels")
+	for _, label := range dropLabels {
+		if label != LabelHostname && label != LabelGPIO {
+			return nil, fmt.Errorf("invalid drop_labels entry '%s' (want %s or %s)", label, LabelHostname, LabelGPIO)
+		}
+	}
+
+	statsWindows, err := loadStatsWindows()
+	if err != nil {
+		return nil, err
+	}
+
+	var trendWindow time.Duration
+	if viper.IsSet("trend_window") {
+		trendWindow, err = time.ParseDuration(viper.GetString("trend_window"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trend_window: %w", err)
+		}
+		if trendWindow <= 0 {
+			return nil, fmt.Errorf("trend_window must be positive")
+		}
+	}
+
 	config := &Config{
-		Sensors:    sensors,
-		ListenPort: viper.GetInt("listen_port"),
-		LogLevel:   viper.GetString("log_level"),
+		Sensors: sensors,
+		Sinks:   sinks,
+		Alerting: AlertingConfig{
+			Webhooks:           webhooks,
+			Alertmanagers:      alertmanagers,
+			SensorFailureAfter: sensorFailureAfter,
+			ExternalURL:        viper.GetString("alerting.external_url"),
+		},
+		Ingest:             *ingest,
+		PollInterval:       pollInterval,
+		ExecMaxConcurrent:  execMaxConcurrent,
+		FailFast:           viper.GetBool("fail_fast"),
+		TemperatureMetrics: temperatureMetrics,
+		MetricsNamespace:   metricsNamespace,
+		ConstLabels:        constLabels,
+		DropLabels:         dropLabels,
+		Collectors:         loadCollectors(),
+		StatsWindows:       statsWindows,
+		TrendWindow:        trendWindow,
+		ListenPort:         viper.GetInt("listen_port"),
+		LogLevel:           viper.GetString("log_level"),
 	}
 
 	return config, nil
 }
 
+// loadSinks parses the optional "sinks" list.
+func loadSinks() ([]SinkConfig, error) {
+	sinksRaw := viper.Get("sinks")
+	if sinksRaw == nil {
+		return nil, nil
+	}
+	sinksList, ok := sinksRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid sinks configuration format")
+	}
+
+	sinks := make([]SinkConfig, 0, len(sinksList))
+	names := make(map[string]bool, len(sinksList))
+	for i, s := range sinksList {
+		sinkMap, ok := s.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid sink configuration at index %d", i)
+		}
+		sink := SinkConfig{
+			Name:      getString(sinkMap, "name"),
+			Type:      getString(sinkMap, "type"),
+			QueueSize: getInt(sinkMap, "queue_size"),
+			Policy:    getString(sinkMap, "policy"),
+			Address:   getString(sinkMap, "address"),
+			Prefix:    getString(sinkMap, "prefix"),
+			Template:  getString(sinkMap, "template"),
+			Path:      getString(sinkMap, "path"),
+			Format:    getString(sinkMap, "format"),
+			MaxSizeMB: getInt(sinkMap, "max_size_mb"),
+			Compress:  getBool(sinkMap, "compress"),
+			MaxFiles:  getInt(sinkMap, "max_files"),
+		}
+		if sink.Type == "" {
+			return nil, fmt.Errorf("sink at index %d has no type", i)
+		}
+		var err error
+		if sink.RotateInterval, err = getDuration(sinkMap, "rotate_interval"); err != nil {
+			return nil, fmt.Errorf("sink at index %d: %w", i, err)
+		}
+		if sink.SyncInterval, err = getDuration(sinkMap, "sync_interval"); err != nil {
+			return nil, fmt.Errorf("sink at index %d: %w", i, err)
+		}
+		if sink.Name == "" {
+			sink.Name = sink.Type
+		}
+		if names[sink.Name] {
+			return nil, fmt.Errorf("duplicate sink name '%s' at index %d, set a unique name", sink.Name, i)
+		}
+		names[sink.Name] = true
+
+		if sink.QueueSize == 0 {
+			sink.QueueSize = DefaultSinkQueueSize
+		} else if sink.QueueSize < 0 {
+			return nil, fmt.Errorf("sink '%s' has negative queue_size", sink.Name)
+		}
+		switch sink.Policy {
+		case "":
+			sink.Policy = SinkPolicyDrop
+		case SinkPolicyDrop, SinkPolicyBlock:
+		default:
+			return nil, fmt.Errorf("sink '%s' has invalid policy '%s' (want %s or %s)", sink.Name, sink.Policy, SinkPolicyDrop, SinkPolicyBlock)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// loadCollectors parses the optional "collectors" section. The Go and
+// process collectors are enabled unless disabled.
+func loadCollectors() CollectorsConfig {
+	collectors := CollectorsConfig{Go: true, Process: true}
+	if viper.IsSet("collectors.go") {
+		collectors.Go = viper.GetBool("collectors.go")
+	}
+	if viper.IsSet("collectors.process") {
+		collectors.Process = viper.GetBool("collectors.process")
+	}
+	collectors.Host = viper.GetBool("collectors.host")
+	return collectors
+}
+
+// loadStatsWindows parses the optional "stats_windows" list of durations.
+func loadStatsWindows() ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, v := range viper.GetStringSlice("stats_windows") {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stats_windows entry '%s': %w", v, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("stats_windows entry '%s' must be positive", v)
+		}
+		if slices.Contains(windows, d) {
+			return nil, fmt.Errorf("duplicate stats_windows entry '%s'", v)
+		}
+		windows = append(windows, d)
+	}
+	return windows, nil
+}
+
+// loadIngest parses the optional "ingest" section.
+func loadIngest() (*IngestConfig, error) {
+	ingest := &IngestConfig{StaleAfter: DefaultIngestStaleAfter}
+	if viper.IsSet("ingest.stale_after") {
+		d, err := time.ParseDuration(viper.GetString("ingest.stale_after"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ingest.stale_after: %w", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("ingest.stale_after must be positive")
+		}
+		ingest.StaleAfter = d
+	}
+
+	devicesRaw := viper.Get("ingest.devices")
+	if devicesRaw == nil {
+		return ingest, nil
+	}
+	devicesList, ok := devicesRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid ingest.devices configuration format")
+	}
+
+	names := make(map[string]bool, len(devicesList))
+	tokens := make(map[string]bool, len(devicesList))
+	for i, d := range devicesList {
+		deviceMap, ok := d.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid ingest device configuration at index %d", i)
+		}
+		device := IngestDeviceConfig{
+			Name:            getString(deviceMap, "name"),
+			Token:           getString(deviceMap, "token"),
+			TemperatureUnit: getString(deviceMap, "temperature_unit"),
+		}
+		if device.Name == "" {
+			return nil, fmt.Errorf("ingest device at index %d has no name", i)
+		}
+		if names[device.Name] {
+			return nil, fmt.Errorf("duplicate ingest device name '%s'", device.Name)
+		}
+		names[device.Name] = true
+		if device.Token == "" {
+			return nil, fmt.Errorf("ingest device '%s' has no token", device.Name)
+		}
+		if tokens[device.Token] {
+			return nil, fmt.Errorf("ingest device '%s' reuses the token of another device", device.Name)
+		}
+		tokens[device.Token] = true
+		switch device.TemperatureUnit {
+		case "":
+			device.TemperatureUnit = "celsius"
+		case "celsius", "fahrenheit", "kelvin":
+		default:
+			return nil, fmt.Errorf("ingest device '%s' has invalid temperature_unit '%s' (want celsius, fahrenheit or kelvin)", device.Name, device.TemperatureUnit)
+		}
+		var err error
+		if device.StaleAfter, err = getDuration(deviceMap, "stale_after"); err != nil {
+			return nil, fmt.Errorf("ingest device '%s': %w", device.Name, err)
+		}
+		if device.StaleAfter == 0 {
+			device.StaleAfter = ingest.StaleAfter
+		}
+		ingest.Devices = append(ingest.Devices, device)
+	}
+	return ingest, nil
+}
+
+// checkIngestDeviceNames rejects ingest devices named like the gpio of a local
+// sensor: remote sensors carry the name of their device in their gpio label,
+// so a remote sensor named like that local sensor would expose the same series.
+func checkIngestDeviceNames(sensors []SensorConfig, devices []IngestDeviceConfig) error {
+	for _, d := range devices {
+		for _, s := range sensors {
+			if s.GPIO == d.Name {
+				return fmt.Errorf("ingest device '%s' is named like the gpio of sensor '%s'", d.Name, s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// loadAlertRules parses the optional "alerts" list of a sensor.
+func loadAlertRules(sensorMap map[string]interface{}, sensorName string) ([]AlertRuleConfig, error) {
+	alertsRaw, ok := sensorMap["alerts"]
+	if !ok || alertsRaw == nil {
+		return nil, nil
+	}
+	alertsList, ok := alertsRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid alerts configuration for sensor '%s'", sensorName)
+	}
+
+	rules := make([]AlertRuleConfig, 0, len(alertsList))
+	for i, a := range alertsList {
+		alertMap, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid alert rule at index %d for sensor '%s'", i, sensorName)
+		}
+		rule := AlertRuleConfig{
+			Name:       getString(alertMap, "name"),
+			Metric:     getString(alertMap, "metric"),
+			Comparison: getString(alertMap, "comparison"),
+			Threshold:  getFloat(alertMap, "threshold"),
+			Hysteresis: getFloat(alertMap, "hysteresis"),
+			Labels:     getStringMap(alertMap, "labels"),
+		}
+		if rule.Name == "" {
+			rule.Name = sensorName + "-" + rule.Metric
+		}
+		if _, ok := alertMap["threshold"]; !ok {
+			return nil, fmt.Errorf("alert rule '%s' has no threshold", rule.Name)
+		}
+		switch rule.Metric {
+		case "temperature", "humidity":
+		default:
+			return nil, fmt.Errorf("alert rule '%s' has invalid metric '%s' (want temperature or humidity)", rule.Name, rule.Metric)
+		}
+		switch rule.Comparison {
+		case ">", ">=", "<", "<=":
+		default:
+			return nil, fmt.Errorf("alert rule '%s' has invalid comparison '%s' (want >, >=, < or <=)", rule.Name, rule.Comparison)
+		}
+		if rule.Hysteresis < 0 {
+			return nil, fmt.Errorf("alert rule '%s' has negative hysteresis", rule.Name)
+		}
+		if err := checkLabels(rule.Labels, reservedAlertLabels, fmt.Sprintf("labels of alert rule '%s'", rule.Name)); err != nil {
+			return nil, err
+		}
+		var err error
+		if rule.For, err = getDuration(alertMap, "for"); err != nil {
+			return nil, fmt.Errorf("alert rule '%s': %w", rule.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// loadExec validates the exec backend settings of a sensor and applies their defaults.
+func loadExec(sensor *SensorConfig, sensorMap map[string]interface{}) error {
+	if sensor.Backend != "exec" {
+		return nil
+	}
+	if len(sensor.ExecCommand) == 0 || sensor.ExecCommand[0] == "" {
+		return fmt.Errorf("sensor '%s' uses the exec backend but has no exec_command", sensor.Name)
+	}
+	for _, kv := range sensor.ExecEnv {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+			return fmt.Errorf("sensor '%s' has invalid exec_env entry '%s' (want KEY=value)", sensor.Name, kv)
+		}
+	}
+	timeout, err := getDuration(sensorMap, "exec_timeout")
+	if err != nil {
+		return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("sensor '%s' has negative exec_timeout", sensor.Name)
+	}
+	if timeout == 0 {
+		timeout = DefaultExecTimeout
+	}
+	sensor.ExecTimeout = timeout
+	return nil
+}
+
+// loadPower parses and validates the power_* settings of a sensor.
+func loadPower(sensor *SensorConfig, sensorMap map[string]interface{}) error {
+	p := PowerConfig{
+		GPIO:       -1,
+		CycleAfter: DefaultPowerCycleAfter,
+		OffTime:    DefaultPowerOffTime,
+		WarmUp:     DefaultPowerWarmUp,
+	}
+	if _, ok := sensorMap["power_gpio"]; !ok {
+		sensor.Power = p
+		return nil
+	}
+	p.GPIO = getInt(sensorMap, "power_gpio")
+	if _, ok := sensorMap["power_cycle_after"]; ok {
+		p.CycleAfter = getInt(sensorMap, "power_cycle_after")
+	}
+	var err error
+	if _, ok := sensorMap["power_off_time"]; ok {
+		if p.OffTime, err = getDuration(sensorMap, "power_off_time"); err != nil {
+			return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+		}
+	}
+	if _, ok := sensorMap["power_warmup"]; ok {
+		if p.WarmUp, err = getDuration(sensorMap, "power_warmup"); err != nil {
+			return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+		}
+	}
+
+	switch {
+	case p.GPIO < 0:
+		return fmt.Errorf("sensor '%s' has invalid power_gpio %d", sensor.Name, p.GPIO)
+	case p.GPIO == sensor.Pin && (sensor.Backend == "" || sensor.Backend == "periph" || sensor.Backend == "gpiocdev"):
+		return fmt.Errorf("sensor '%s' uses its data pin GPIO%d as power_gpio", sensor.Name, p.GPIO)
+	case p.CycleAfter < 1:
+		return fmt.Errorf("sensor '%s' has power_cycle_after below 1", sensor.Name)
+	case p.OffTime <= 0:
+		return fmt.Errorf("sensor '%s' has non-positive power_off_time", sensor.Name)
+	case p.WarmUp < 0:
+		return fmt.Errorf("sensor '%s' has negative power_warmup", sensor.Name)
+	}
+	sensor.Power = p
+	return nil
+}
+
+// loadHealth parses and validates the health_* settings of a sensor.
+func loadHealth(sensor *SensorConfig, sensorMap map[string]interface{}) error {
+	h := HealthConfig{
+		DegradedAfter: DefaultHealthDegradedAfter,
+		FailedAfter:   DefaultHealthFailedAfter,
+		Backoff:       DefaultHealthBackoff,
+		BackoffMax:    DefaultHealthBackoffMax,
+	}
+	if _, ok := sensorMap["health_degraded_after"]; ok {
+		h.DegradedAfter = getInt(sensorMap, "health_degraded_after")
+	}
+	if _, ok := sensorMap["health_failed_after"]; ok {
+		h.FailedAfter = getInt(sensorMap, "health_failed_after")
+	}
+	if _, ok := sensorMap["health_backoff"]; ok {
+		backoff, err := getDuration(sensorMap, "health_backoff")
+		if err != nil {
+			return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+		}
+		h.Backoff = backoff
+	}
+	if _, ok := sensorMap["health_backoff_max"]; ok {
+		backoffMax, err := getDuration(sensorMap, "health_backoff_max")
+		if err != nil {
+			return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+		}
+		h.BackoffMax = backoffMax
+	} else {
+		h.BackoffMax = max(h.BackoffMax, h.Backoff)
+	}
+
+	switch {
+	case h.DegradedAfter < 1:
+		return fmt.Errorf("sensor '%s' has health_degraded_after below 1", sensor.Name)
+	case h.FailedAfter < h.DegradedAfter:
+		return fmt.Errorf("sensor '%s' has health_failed_after below health_degraded_after", sensor.Name)
+	case h.Backoff <= 0:
+		return fmt.Errorf("sensor '%s' has non-positive health_backoff", sensor.Name)
+	case h.BackoffMax < h.Backoff:
+		return fmt.Errorf("sensor '%s' has health_backoff_max below health_backoff", sensor.Name)
+	}
+	sensor.Health = h
+	return nil
+}
+
+// loadStale parses and validates the stale_policy and stale_after settings of a sensor.
+func loadStale(sensor *SensorConfig, sensorMap map[string]interface{}) error {
+	sensor.StalePolicy = StalePolicyDrop
+	if _, ok := sensorMap["stale_policy"]; ok {
+		sensor.StalePolicy = getString(sensorMap, "stale_policy")
+	}
+	switch sensor.StalePolicy {
+	case StalePolicyDrop, StalePolicyServe, StalePolicyTimestamp:
+	default:
+		return fmt.Errorf("sensor '%s' has invalid stale_policy '%s' (want drop, serve or timestamp)", sensor.Name, sensor.StalePolicy)
+	}
+
+	sensor.StaleAfter = DefaultStaleAfter
+	if _, ok := sensorMap["stale_after"]; ok {
+		staleAfter, err := getDuration(sensorMap, "stale_after")
+		if err != nil {
+			return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+		}
+		if staleAfter <= 0 {
+			return fmt.Errorf("sensor '%s' has non-positive stale_after", sensor.Name)
+		}
+		sensor.StaleAfter = staleAfter
+	}
+	return nil
+}
+
+// loadModbus parses and validates the modbus_* settings of a modbus sensor.
+func loadModbus(sensor *SensorConfig, sensorMap map[string]interface{}) error {
+	if sensor.Backend != "modbus" {
+		return nil
+	}
+	m := ModbusConfig{
+		Address:          getString(sensorMap, "modbus_address"),
+		Device:           getString(sensorMap, "modbus_device"),
+		BaudRate:         DefaultModbusBaudRate,
+		Parity:           getString(sensorMap, "modbus_parity"),
+		SlaveID:          DefaultModbusSlaveID,
+		Function:         DefaultModbusFunction,
+		HumidityRegister: -1,
+		TemperatureScale: 1,
+		HumidityScale:    1,
+		DataType:         getString(sensorMap, "modbus_data_type"),
+		ByteOrder:        strings.ToUpper(getString(sensorMap, "modbus_byte_order")),
+		Timeout:          DefaultModbusTimeout,
+	}
+	if _, ok := sensorMap["modbus_baud_rate"]; ok {
+		m.BaudRate = getInt(sensorMap, "modbus_baud_rate")
+	}
+	if _, ok := sensorMap["modbus_slave_id"]; ok {
+		m.SlaveID = getInt(sensorMap, "modbus_slave_id")
+	}
+	if _, ok := sensorMap["modbus_function"]; ok {
+		m.Function = getInt(sensorMap, "modbus_function")
+	}
+	if _, ok := sensorMap["modbus_humidity_register"]; ok {
+		m.HumidityRegister = getInt(sensorMap, "modbus_humidity_register")
+	}
+	if _, ok := sensorMap["modbus_temperature_scale"]; ok {
+		m.TemperatureScale = getFloat(sensorMap, "modbus_temperature_scale")
+	}
+	if _, ok := sensorMap["modbus_humidity_scale"]; ok {
+		m.HumidityScale = getFloat(sensorMap, "modbus_humidity_scale")
+	}
+	if m.Parity == "" {
+		m.Parity = "none"
+	}
+	if m.DataType == "" {
+		m.DataType = "int16"
+	}
+	if m.ByteOrder == "" {
+		m.ByteOrder = "ABCD"
+	}
+
+	if (m.Address == "") == (m.Device == "") {
+		return fmt.Errorf("sensor '%s' must set exactly one of modbus_address and modbus_device", sensor.Name)
+	}
+	if _, ok := sensorMap["modbus_temperature_register"]; !ok {
+		return fmt.Errorf("sensor '%s' uses the modbus backend but has no modbus_temperature_register", sensor.Name)
+	}
+	m.TemperatureRegister = getInt(sensorMap, "modbus_temperature_register")
+	if m.TemperatureRegister < 0 || m.TemperatureRegister > 0xFFFF {
+		return fmt.Errorf("sensor '%s' has invalid modbus_temperature_register %d", sensor.Name, m.TemperatureRegister)
+	}
+	if m.HumidityRegister < -1 || m.HumidityRegister > 0xFFFF {
+		return fmt.Errorf("sensor '%s' has invalid modbus_humidity_register %d", sensor.Name, m.HumidityRegister)
+	}
+	if m.SlaveID < 1 || m.SlaveID > 247 {
+		return fmt.Errorf("sensor '%s' has invalid modbus_slave_id %d (want 1-247)", sensor.Name, m.SlaveID)
+	}
+	if m.Function != 3 && m.Function != 4 {
+		return fmt.Errorf("sensor '%s' has invalid modbus_function %d (want 3 or 4)", sensor.Name, m.Function)
+	}
+	if m.BaudRate <= 0 {
+		return fmt.Errorf("sensor '%s' has invalid modbus_baud_rate %d", sensor.Name, m.BaudRate)
+	}
+	switch m.Parity {
+	case "none", "even", "odd":
+	default:
+		return fmt.Errorf("sensor '%s' has invalid modbus_parity '%s' (want none, even or odd)", sensor.Name, m.Parity)
+	}
+	switch m.DataType {
+	case "int16", "uint16", "int32", "uint32", "float32":
+	default:
+		return fmt.Errorf("sensor '%s' has invalid modbus_data_type '%s'", sensor.Name, m.DataType)
+	}
+	switch m.ByteOrder {
+	case "ABCD", "DCBA", "BADC", "CDAB":
+	default:
+		return fmt.Errorf("sensor '%s' has invalid modbus_byte_order '%s' (want ABCD, DCBA, BADC or CDAB)", sensor.Name, m.ByteOrder)
+	}
+	if m.TemperatureScale == 0 || m.HumidityScale == 0 {
+		return fmt.Errorf("sensor '%s' has a zero modbus scale", sensor.Name)
+	}
+
+	timeout, err := getDuration(sensorMap, "modbus_timeout")
+	if err != nil {
+		return fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("sensor '%s' has negative modbus_timeout", sensor.Name)
+	}
+	if timeout > 0 {
+		m.Timeout = timeout
+	}
+
+	sensor.Modbus = m
+	return nil
+}
+
+// checkI2CAddress rejects addresses outside the 7-bit I2C address range.
+func checkI2CAddress(sensor *SensorConfig) error {
+	if sensor.I2CAddress < 0 || sensor.I2CAddress > 0x7F {
+		return fmt.Errorf("sensor '%s' has invalid i2c_address %d (want 0x00-0x7f)", sensor.Name, sensor.I2CAddress)
+	}
+	return nil
+}
+
+// checkLabels rejects labels with invalid names, names among reserved, or
+// empty values. what describes the labels in errors.
+func checkLabels(labels map[string]string, reserved []string, what string) error {
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		switch {
+		case !metricNameRE.MatchString(name) || strings.HasPrefix(name, "__"):
+			return fmt.Errorf("invalid label name '%s' in %s", name, what)
+		case slices.Contains(reserved, name):
+			return fmt.Errorf("label name '%s' in %s is reserved", name, what)
+		case labels[name] == "":
+			return fmt.Errorf("label '%s' in %s has an empty value", name, what)
+		}
+	}
+	return nil
+}
+
+// fillSensorLabels gives every sensor the label names of all the sensors.
+// Sensors without one of them take the value of the const label of the same
+// name, or an empty value, which Prometheus treats as an absent label.
+// It returns those labels of a sensor setting none of them.
+func fillSensorLabels(sensors []SensorConfig, constLabels map[string]string) map[string]string {
+	names := make(map[string]bool)
+	for _, s := range sensors {
+		for name := range s.Labels {
+			names[name] = true
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	defaults := make(map[string]string, len(names))
+	for name := range names {
+		defaults[name] = constLabels[name]
+	}
+	for i := range sensors {
+		labels := make(map[string]string, len(names))
+		for name := range names {
+			value, ok := sensors[i].Labels[name]
+			if !ok {
+				value = defaults[name]
+			}
+			labels[name] = value
+		}
+		sensors[i].Labels = labels
+	}
+	return defaults
+}
+
+// checkTemperatureUnit rejects temperature units the exporter cannot convert to.
+func checkTemperatureUnit(sensor *SensorConfig) error {
+	switch sensor.TemperatureUnit {
+	case "", "celsius", "fahrenheit", "kelvin":
+		return nil
+	}
+	return fmt.Errorf("sensor '%s' has invalid temperature_unit '%s' (want celsius, fahrenheit or kelvin)", sensor.Name, sensor.TemperatureUnit)
+}
+
+// checkTemperatureOnlyAlerts rejects alert rules that cannot apply to a
+// DS18B20 entry: humidity rules, and any rule on an entry discovering several
+// probes since rule names must be unique. Humidity rules are also rejected on
+// modbus sensors without a humidity register.
+func checkTemperatureOnlyAlerts(sensor *SensorConfig) error {
+	if sensor.Backend == "modbus" && sensor.Modbus.HumidityRegister < 0 {
+		for _, r := range sensor.Alerts {
+			if r.Metric == "humidity" {
+				return fmt.Errorf("alert rule '%s' uses humidity but sensor '%s' has no modbus_humidity_register", r.Name, sensor.Name)
+			}
+		}
+	}
+	if sensor.Backend != "ds18b20" {
+		return nil
+	}
+	for _, r := range sensor.Alerts {
+		if r.Metric == "humidity" {
+			return fmt.Errorf("alert rule '%s' uses humidity but sensor '%s' only measures temperature", r.Name, sensor.Name)
+		}
+		if sensor.W1Device == "" {
+			return fmt.Errorf("alert rule '%s' requires sensor '%s' to set w1_device", r.Name, sensor.Name)
+		}
+	}
+	return nil
+}
+
+// ReadFailureRuleName returns the name of the rule firing after
+// sensor_failure_after consecutive failed reads of a sensor.
+func ReadFailureRuleName(sensor string) string {
+	return sensor + "-read-failure"
+}
+
+// checkAlertRuleNames ensures alert rule names are unique across all sensors,
+// including the read failure rules when sensorFailureAfter is positive.
+func checkAlertRuleNames(sensors []SensorConfig, sensorFailureAfter int) error {
+	names := make(map[string]bool)
+	if sensorFailureAfter > 0 {
+		for _, s := range sensors {
+			names[ReadFailureRuleName(s.Name)] = true
+		}
+	}
+	for _, s := range sensors {
+		for _, r := range s.Alerts {
+			if names[r.Name] {
+				return fmt.Errorf("duplicate alert rule name '%s', set a unique name", r.Name)
+			}
+			names[r.Name] = true
+		}
+	}
+	return nil
+}
+
+// loadWebhooks parses the optional "alerting.webhooks" list.
+func loadWebhooks() ([]WebhookConfig, error) {
+	webhooksRaw := viper.Get("alerting.webhooks")
+	if webhooksRaw == nil {
+		return nil, nil
+	}
+	webhooksList, ok := webhooksRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid alerting.webhooks configuration format")
+	}
+
+	webhooks := make([]WebhookConfig, 0, len(webhooksList))
+	for i, w := range webhooksList {
+		webhookMap, ok := w.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid webhook configuration at index %d", i)
+		}
+		webhook := WebhookConfig{
+			URL:          getString(webhookMap, "url"),
+			BodyTemplate: getString(webhookMap, "body_template"),
+			Headers:      getStringMap(webhookMap, "headers"),
+		}
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook at index %d has no url", i)
+		}
+		var err error
+		if webhook.Timeout, err = getDuration(webhookMap, "timeout"); err != nil {
+			return nil, fmt.Errorf("webhook at index %d: %w", i, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// loadAlertmanagers parses the optional "alerting.alertmanagers" list.
+func loadAlertmanagers() ([]AlertmanagerConfig, error) {
+	raw := viper.Get("alerting.alertmanagers")
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid alerting.alertmanagers configuration format")
+	}
+
+	alertmanagers := make([]AlertmanagerConfig, 0, len(list))
+	for i, a := range list {
+		amMap, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid alertmanager configuration at index %d", i)
+		}
+		am := AlertmanagerConfig{
+			URL: getString(amMap, "url"),
+		}
+		if am.URL == "" {
+			return nil, fmt.Errorf("alertmanager at index %d has no url", i)
+		}
+		var err error
+		if am.Timeout, err = getDuration(amMap, "timeout"); err != nil {
+			return nil, fmt.Errorf("alertmanager at index %d: %w", i, err)
+		}
+		if am.ResendInterval, err = getDuration(amMap, "resend_interval"); err != nil {
+			return nil, fmt.Errorf("alertmanager at index %d: %w", i, err)
+		}
+		alertmanagers = append(alertmanagers, am)
+	}
+	return alertmanagers, nil
+}
+
 func getString(m map[string]interface{}, key string) string {
 	if v, ok := m[key]; ok {
 		if s, ok := v.(string); ok {
@@ -90,3 +1287,70 @@ func getInt(m map[string]interface{}, key string) int {
 	}
 	return 0
 }
+
+func getFloat(m map[string]interface{}, key string) float64 {
+	if v, ok := m[key]; ok {
+		switch n := v.(type) {
+		case int:
+			return float64(n)
+		case float64:
+			return n
+		}
+	}
+	return 0
+}
+
+func getStringMap(m map[string]interface{}, key string) map[string]string {
+	v, ok := m[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	result := make(map[string]string, len(v))
+	for k, val := range v {
+		result[k] = fmt.Sprint(val)
+	}
+	return result
+}
+
+// getStringSlice returns a list of strings, or nil if the key is absent or not a list.
+func getStringSlice(m map[string]interface{}, key string) []string {
+	v, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(v))
+	for _, val := range v {
+		result = append(result, fmt.Sprint(val))
+	}
+	return result
+}
+
+func getBool(m map[string]interface{}, key string) bool {
+	if v, ok := m[key]; ok {
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	}
+	return false
+}
+
+// getDuration parses a duration string such as "30s" or "1h".
+// Returns zero if the key is absent and an error if the value is not a valid duration.
+func getDuration(m map[string]interface{}, key string) (time.Duration, error) {
+	v, ok := m[key]
+	if !ok {
+		return 0, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s: expected a duration string like \"30s\"", key)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return d, nil
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -169,6 +171,1155 @@ log_level: info
 	}
 }
 
+// loadFromContent writes content as the config file in a temp directory and calls Load()
+func loadFromContent(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	resetViper()
+	t.Cleanup(resetViper)
+
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(originalWd) })
+
+	configPath := filepath.Join(tempDir, "dht-prometheus-exporter.yml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	return Load()
+}
+
+const minimalSensors = `---
+sensors:
+  - name: test
+    gpio_pin: 4
+    max_retries: 10
+    temperature_unit: celsius
+`
+
+func TestLoad_SensorBackend(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: pi5
+    gpio_pin: 17
+    max_retries: 5
+    temperature_unit: celsius
+    backend: gpiocdev
+    gpio_chip: /dev/gpiochip4
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	sensor := config.Sensors[0]
+	if sensor.Pin != 17 {
+		t.Errorf("Sensor.Pin = %d, want 17", sensor.Pin)
+	}
+	if sensor.Backend != "gpiocdev" {
+		t.Errorf("Sensor.Backend = %q, want %q", sensor.Backend, "gpiocdev")
+	}
+	if sensor.GPIOChip != "/dev/gpiochip4" {
+		t.Errorf("Sensor.GPIOChip = %q, want %q", sensor.GPIOChip, "/dev/gpiochip4")
+	}
+}
+
+func TestLoad_IIODevice(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: kernel
+    max_retries: 5
+    temperature_unit: celsius
+    backend: iio
+    iio_device: dht11@4
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	sensor := config.Sensors[0]
+	if sensor.Backend != "iio" {
+		t.Errorf("Sensor.Backend = %q, want %q", sensor.Backend, "iio")
+	}
+	if sensor.IIODevice != "dht11@4" {
+		t.Errorf("Sensor.IIODevice = %q, want %q", sensor.IIODevice, "dht11@4")
+	}
+}
+
+func TestLoad_W1Device(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: tank
+    max_retries: 3
+    temperature_unit: celsius
+    backend: ds18b20
+    w1_device: 28-0316a2794fff
+    alerts:
+      - metric: temperature
+        comparison: ">"
+        threshold: 30
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if got := config.Sensors[0].W1Device; got != "28-0316a2794fff" {
+		t.Errorf("Sensor.W1Device = %q, want %q", got, "28-0316a2794fff")
+	}
+}
+
+func TestLoad_TemperatureOnlyAlertsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "humidity rule",
+			content: `---
+sensors:
+  - name: tank
+    backend: ds18b20
+    w1_device: 28-0316a2794fff
+    alerts:
+      - metric: humidity
+        comparison: ">"
+        threshold: 80
+`,
+		},
+		{
+			name: "rule on discovered probes",
+			content: `---
+sensors:
+  - name: tank
+    backend: ds18b20
+    alerts:
+      - metric: temperature
+        comparison: ">"
+        threshold: 30
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadFromContent(t, tt.content); err == nil {
+				t.Error("Load() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_I2C(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    max_retries: 3
+    temperature_unit: celsius
+    backend: bme280
+    i2c_bus: /dev/i2c-3
+    i2c_address: 0x77
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	sensor := config.Sensors[0]
+	if sensor.I2CBus != "/dev/i2c-3" {
+		t.Errorf("Sensor.I2CBus = %q, want %q", sensor.I2CBus, "/dev/i2c-3")
+	}
+	if sensor.I2CAddress != 0x77 {
+		t.Errorf("Sensor.I2CAddress = 0x%02x, want 0x77", sensor.I2CAddress)
+	}
+}
+
+func TestLoad_I2CAddressInvalid(t *testing.T) {
+	_, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    backend: sht3x
+    i2c_address: 0x144
+`)
+	if err == nil {
+		t.Error("Load() expected error for i2c_address above 0x7f, got nil")
+	}
+}
+
+func TestLoad_TemperatureUnit(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    gpio_pin: 4
+    temperature_unit: kelvin
+temperature_metrics: both
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if unit := config.Sensors[0].TemperatureUnit; unit != "kelvin" {
+		t.Errorf("Sensor.TemperatureUnit = %q, want kelvin", unit)
+	}
+	if config.TemperatureMetrics != TemperatureMetricsBoth {
+		t.Errorf("TemperatureMetrics = %q, want %q", config.TemperatureMetrics, TemperatureMetricsBoth)
+	}
+
+	config, err = loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if config.TemperatureMetrics != TemperatureMetricsLegacy {
+		t.Errorf("default TemperatureMetrics = %q, want %q", config.TemperatureMetrics, TemperatureMetricsLegacy)
+	}
+}
+
+func TestLoad_MetricLabels(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    gpio_pin: 4
+    model: AM2302
+    labels:
+      room: attic
+      floor: 2
+  - name: cellar
+    gpio_pin: 17
+    labels:
+      room: cellar
+      rack: r1
+metrics_namespace: home
+const_labels:
+  building: north
+drop_labels: [hostname]
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if config.Sensors[0].Model != "AM2302" {
+		t.Errorf("Sensor.Model = %q, want AM2302", config.Sensors[0].Model)
+	}
+	if config.MetricsNamespace != "home" {
+		t.Errorf("MetricsNamespace = %q, want home", config.MetricsNamespace)
+	}
+	if !reflect.DeepEqual(config.ConstLabels, map[string]string{"building": "north"}) {
+		t.Errorf("ConstLabels = %v, want map[building:north]", config.ConstLabels)
+	}
+	if !reflect.DeepEqual(config.DropLabels, []string{LabelHostname}) {
+		t.Errorf("DropLabels = %v, want [hostname]", config.DropLabels)
+	}
+
+	// Every sensor has the label names of all the sensors
+	wantLabels := []map[string]string{
+		{"room": "attic", "floor": "2", "rack": ""},
+		{"room": "cellar", "floor": "", "rack": "r1"},
+	}
+	for i, want := range wantLabels {
+		if !reflect.DeepEqual(config.Sensors[i].Labels, want) {
+			t.Errorf("Sensors[%d].Labels = %v, want %v", i, config.Sensors[i].Labels, want)
+		}
+	}
+
+	config, err = loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if config.MetricsNamespace != DefaultMetricsNamespace {
+		t.Errorf("default MetricsNamespace = %q, want %q", config.MetricsNamespace, DefaultMetricsNamespace)
+	}
+	if config.Sensors[0].Labels != nil {
+		t.Errorf("default Labels = %v, want none", config.Sensors[0].Labels)
+	}
+}
+
+func TestLoad_SensorLabelOverridingConstLabel(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: annex
+    gpio_pin: 4
+    labels:
+      building: annex
+  - name: office
+    gpio_pin: 17
+const_labels:
+  building: hq
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	// Sensors without the label keep the value of the const label
+	wantLabels := []map[string]string{
+		{"building": "annex"},
+		{"building": "hq"},
+	}
+	for i, want := range wantLabels {
+		if !reflect.DeepEqual(config.Sensors[i].Labels, want) {
+			t.Errorf("Sensors[%d].Labels = %v, want %v", i, config.Sensors[i].Labels, want)
+		}
+	}
+	// So do remote sensors
+	if want := map[string]string{"building": "hq"}; !reflect.DeepEqual(config.Ingest.Labels, want) {
+		t.Errorf("Ingest.Labels = %v, want %v", config.Ingest.Labels, want)
+	}
+}
+
+func TestLoad_MetricLabelsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid namespace", minimalSensors + "metrics_namespace: home-lab\n"},
+		{"namespace starting with a digit", minimalSensors + "metrics_namespace: 1dht\n"},
+		{"invalid label name", "sensors:\n  - name: attic\n    labels:\n      room-name: attic\n"},
+		{"double underscore label", "sensors:\n  - name: attic\n    labels:\n      __room: attic\n"},
+		{"reserved label", "sensors:\n  - name: attic\n    labels:\n      gpio: 4\n"},
+		{"empty label value", "sensors:\n  - name: attic\n    labels:\n      room: \"\"\n"},
+		{"reserved const label", minimalSensors + "const_labels:\n  hostname: pi\n"},
+		{"reserved calibration label", minimalSensors + "const_labels:\n  temperature_scale: 1\n"},
+		{"reserved window label", "sensors:\n  - name: attic\n    labels:\n      window: north\n"},
+		{"reserved rule label", minimalSensors + "const_labels:\n  rule: strict\n"},
+		{"invalid dropped label", minimalSensors + "drop_labels: [dht_name]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadFromContent(t, tt.content); err == nil {
+				t.Error("Load() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_TemperatureUnitInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid unit", "sensors:\n  - name: attic\n    temperature_unit: rankine\n"},
+		{"invalid metrics", minimalSensors + "temperature_metrics: celsius\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadFromContent(t, tt.content); err == nil {
+				t.Error("Load() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Ingest(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors+`ingest:
+  stale_after: 10m
+  devices:
+    - name: esp-kitchen
+      token: kitchen-token
+    - name: esp-garage
+      token: garage-token
+      temperature_unit: fahrenheit
+      stale_after: 1m
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	ingest := config.Ingest
+	if ingest.StaleAfter != 10*time.Minute {
+		t.Errorf("Ingest.StaleAfter = %v, want %v", ingest.StaleAfter, 10*time.Minute)
+	}
+	if len(ingest.Devices) != 2 {
+		t.Fatalf("len(Ingest.Devices) = %d, want 2", len(ingest.Devices))
+	}
+	kitchen, garage := ingest.Devices[0], ingest.Devices[1]
+	if kitchen.Name != "esp-kitchen" || kitchen.Token != "kitchen-token" {
+		t.Errorf("Devices[0] = %+v, want esp-kitchen with kitchen-token", kitchen)
+	}
+	if kitchen.TemperatureUnit != "celsius" {
+		t.Errorf("Devices[0].TemperatureUnit = %q, want %q", kitchen.TemperatureUnit, "celsius")
+	}
+	if kitchen.StaleAfter != 10*time.Minute {
+		t.Errorf("Devices[0].StaleAfter = %v, want %v", kitchen.StaleAfter, 10*time.Minute)
+	}
+	if garage.TemperatureUnit != "fahrenheit" {
+		t.Errorf("Devices[1].TemperatureUnit = %q, want %q", garage.TemperatureUnit, "fahrenheit")
+	}
+	if garage.StaleAfter != time.Minute {
+		t.Errorf("Devices[1].StaleAfter = %v, want %v", garage.StaleAfter, time.Minute)
+	}
+}
+
+func TestLoad_IngestDefault(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if len(config.Ingest.Devices) != 0 {
+		t.Errorf("len(Ingest.Devices) = %d, want 0", len(config.Ingest.Devices))
+	}
+	if config.Ingest.StaleAfter != DefaultIngestStaleAfter {
+		t.Errorf("Ingest.StaleAfter = %v, want %v", config.Ingest.StaleAfter, DefaultIngestStaleAfter)
+	}
+}
+
+func TestLoad_IngestWithoutLocalSensors(t *testing.T) {
+	config, err := loadFromContent(t, `---
+ingest:
+  devices:
+    - name: esp-kitchen
+      token: kitchen-token
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if len(config.Sensors) != 0 {
+		t.Errorf("len(Sensors) = %d, want 0", len(config.Sensors))
+	}
+}
+
+func TestLoad_IngestInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		ingest string
+	}{
+		{"invalid stale_after", "  stale_after: soon\n"},
+		{"negative stale_after", "  stale_after: -1m\n"},
+		{"missing name", "  devices:\n    - token: secret\n"},
+		{"missing token", "  devices:\n    - name: esp\n"},
+		{"duplicate name", "  devices:\n    - name: esp\n      token: a\n    - name: esp\n      token: b\n"},
+		{"duplicate token", "  devices:\n    - name: esp1\n      token: a\n    - name: esp2\n      token: a\n"},
+		{"invalid temperature_unit", "  devices:\n    - name: esp\n      token: a\n      temperature_unit: rankine\n"},
+		{"invalid device stale_after", "  devices:\n    - name: esp\n      token: a\n      stale_after: soon\n"},
+		{"named like a sensor gpio", "  devices:\n    - name: GPIO4\n      token: a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFromContent(t, minimalSensors+"ingest:\n"+tt.ingest)
+			if err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_Exec(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: plugin
+    temperature_unit: celsius
+    backend: exec
+    exec_command: ["/usr/local/bin/read-sensor", "--bus", 1]
+    exec_timeout: 3s
+    exec_dir: /var/lib/sensors
+    exec_env:
+      - SENSOR_ID=42
+  - name: defaults
+    backend: exec
+    exec_command: ["/usr/local/bin/read-sensor"]
+exec_max_concurrent: 2
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	sensor := config.Sensors[0]
+	wantCommand := []string{"/usr/local/bin/read-sensor", "--bus", "1"}
+	if !reflect.DeepEqual(sensor.ExecCommand, wantCommand) {
+		t.Errorf("Sensor.ExecCommand = %v, want %v", sensor.ExecCommand, wantCommand)
+	}
+	if sensor.ExecTimeout != 3*time.Second {
+		t.Errorf("Sensor.ExecTimeout = %v, want %v", sensor.ExecTimeout, 3*time.Second)
+	}
+	if sensor.ExecDir != "/var/lib/sensors" {
+		t.Errorf("Sensor.ExecDir = %q, want %q", sensor.ExecDir, "/var/lib/sensors")
+	}
+	if !reflect.DeepEqual(sensor.ExecEnv, []string{"SENSOR_ID=42"}) {
+		t.Errorf("Sensor.ExecEnv = %v, want [SENSOR_ID=42]", sensor.ExecEnv)
+	}
+	if timeout := config.Sensors[1].ExecTimeout; timeout != DefaultExecTimeout {
+		t.Errorf("default ExecTimeout = %v, want %v", timeout, DefaultExecTimeout)
+	}
+	if config.ExecMaxConcurrent != 2 {
+		t.Errorf("ExecMaxConcurrent = %d, want 2", config.ExecMaxConcurrent)
+	}
+}
+
+func TestLoad_FailFast(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if config.FailFast {
+		t.Error("FailFast = true, want false by default")
+	}
+
+	config, err = loadFromContent(t, minimalSensors+"fail_fast: true\n")
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if !config.FailFast {
+		t.Error("FailFast = false, want true")
+	}
+}
+
+func TestLoad_Collectors(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if want := (CollectorsConfig{Go: true, Process: true}); config.Collectors != want {
+		t.Errorf("Collectors = %+v, want %+v by default", config.Collectors, want)
+	}
+
+	config, err = loadFromContent(t, minimalSensors+"collectors:\n  go: false\n  process: false\n  host: true\n")
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if want := (CollectorsConfig{Host: true}); config.Collectors != want {
+		t.Errorf("Collectors = %+v, want %+v", config.Collectors, want)
+	}
+}
+
+func TestLoad_StatsWindows(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors+"stats_windows: [5m, 1h]\n")
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if want := []time.Duration{5 * time.Minute, time.Hour}; !reflect.DeepEqual(config.StatsWindows, want) {
+		t.Errorf("StatsWindows = %v, want %v", config.StatsWindows, want)
+	}
+
+	for _, windows := range []string{"[soon]", "[0s]", "[5m, 300s]"} {
+		if _, err := loadFromContent(t, minimalSensors+"stats_windows: "+windows+"\n"); err == nil {
+			t.Errorf("Load() expected error for stats_windows %s, got nil", windows)
+		}
+	}
+}
+
+func TestLoad_TrendWindow(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if config.TrendWindow != 0 {
+		t.Errorf("TrendWindow = %v, want 0 by default", config.TrendWindow)
+	}
+
+	config, err = loadFromContent(t, minimalSensors+"trend_window: 15m\n")
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if config.TrendWindow != 15*time.Minute {
+		t.Errorf("TrendWindow = %v, want 15m", config.TrendWindow)
+	}
+
+	for _, window := range []string{"soon", "0s", "-1m"} {
+		if _, err := loadFromContent(t, minimalSensors+"trend_window: "+window+"\n"); err == nil {
+			t.Errorf("Load() expected error for trend_window %s, got nil", window)
+		}
+	}
+}
+
+func TestLoad_ExecInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"missing command", "sensors:\n  - name: plugin\n    backend: exec\n"},
+		{"command not a list", "sensors:\n  - name: plugin\n    backend: exec\n    exec_command: /bin/true\n"},
+		{"invalid timeout", "sensors:\n  - name: plugin\n    backend: exec\n    exec_command: [/bin/true]\n    exec_timeout: soon\n"},
+		{"negative timeout", "sensors:\n  - name: plugin\n    backend: exec\n    exec_command: [/bin/true]\n    exec_timeout: -1s\n"},
+		{"invalid env entry", "sensors:\n  - name: plugin\n    backend: exec\n    exec_command: [/bin/true]\n    exec_env: [SENSOR_ID]\n"},
+		{"invalid max concurrent", minimalSensors + "exec_max_concurrent: 0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFromContent(t, tt.content)
+			if err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_Health(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    gpio_pin: 4
+    health_degraded_after: 2
+    health_failed_after: 10
+    health_backoff: 1m
+    health_backoff_max: 1h
+  - name: defaults
+    gpio_pin: 17
+  - name: long-backoff
+    gpio_pin: 27
+    health_backoff: 30m
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	want := HealthConfig{DegradedAfter: 2, FailedAfter: 10, Backoff: time.Minute, BackoffMax: time.Hour}
+	if got := config.Sensors[0].Health; got != want {
+		t.Errorf("Sensor.Health = %+v, want %+v", got, want)
+	}
+	want = HealthConfig{
+		DegradedAfter: DefaultHealthDegradedAfter,
+		FailedAfter:   DefaultHealthFailedAfter,
+		Backoff:       DefaultHealthBackoff,
+		BackoffMax:    DefaultHealthBackoffMax,
+	}
+	if got := config.Sensors[1].Health; got != want {
+		t.Errorf("default Sensor.Health = %+v, want %+v", got, want)
+	}
+	// The default maximum grows to a longer initial backoff
+	if got := config.Sensors[2].Health.BackoffMax; got != 30*time.Minute {
+		t.Errorf("Sensor.Health.BackoffMax = %v, want 30m", got)
+	}
+}
+
+func TestLoad_HealthInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"degraded after zero", "sensors:\n  - name: attic\n    health_degraded_after: 0\n"},
+		{"failed before degraded", "sensors:\n  - name: attic\n    health_degraded_after: 3\n    health_failed_after: 2\n"},
+		{"invalid backoff", "sensors:\n  - name: attic\n    health_backoff: soon\n"},
+		{"zero backoff", "sensors:\n  - name: attic\n    health_backoff: 0s\n"},
+		{"max below backoff", "sensors:\n  - name: attic\n    health_backoff: 1m\n    health_backoff_max: 30s\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadFromContent(t, tt.content); err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_Stale(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    gpio_pin: 4
+    stale_policy: timestamp
+    stale_after: 2m
+  - name: defaults
+    gpio_pin: 17
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	if got := config.Sensors[0]; got.StalePolicy != StalePolicyTimestamp || got.StaleAfter != 2*time.Minute {
+		t.Errorf("StalePolicy, StaleAfter = %q, %v, want %q, 2m", got.StalePolicy, got.StaleAfter, StalePolicyTimestamp)
+	}
+	if got := config.Sensors[1]; got.StalePolicy != StalePolicyDrop || got.StaleAfter != DefaultStaleAfter {
+		t.Errorf("default StalePolicy, StaleAfter = %q, %v, want %q, %v", got.StalePolicy, got.StaleAfter, StalePolicyDrop, DefaultStaleAfter)
+	}
+}
+
+func TestLoad_StaleInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid policy", "sensors:\n  - name: attic\n    stale_policy: keep\n"},
+		{"invalid stale after", "sensors:\n  - name: attic\n    stale_after: soon\n"},
+		{"zero stale after", "sensors:\n  - name: attic\n    stale_policy: serve\n    stale_after: 0s\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadFromContent(t, tt.content); err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_Power(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: attic
+    gpio_pin: 4
+    power_gpio: 22
+    power_cycle_after: 5
+    power_off_time: 10s
+    power_warmup: 3s
+  - name: defaults
+    gpio_pin: 17
+    power_gpio: 27
+  - name: unpowered
+    gpio_pin: 18
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	want := PowerConfig{GPIO: 22, CycleAfter: 5, OffTime: 10 * time.Second, WarmUp: 3 * time.Second}
+	if got := config.Sensors[0].Power; got != want {
+		t.Errorf("Sensor.Power = %+v, want %+v", got, want)
+	}
+	want = PowerConfig{GPIO: 27, CycleAfter: DefaultPowerCycleAfter, OffTime: DefaultPowerOffTime, WarmUp: DefaultPowerWarmUp}
+	if got := config.Sensors[1].Power; got != want {
+		t.Errorf("default Sensor.Power = %+v, want %+v", got, want)
+	}
+	if got := config.Sensors[2].Power.GPIO; got != -1 {
+		t.Errorf("Sensor.Power.GPIO = %d, want -1 without power_gpio", got)
+	}
+}
+
+func TestLoad_PowerInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"negative gpio", "sensors:\n  - name: attic\n    gpio_pin: 4\n    power_gpio: -2\n"},
+		{"data pin", "sensors:\n  - name: attic\n    gpio_pin: 4\n    power_gpio: 4\n"},
+		{"cycle after zero", "sensors:\n  - name: attic\n    gpio_pin: 4\n    power_gpio: 22\n    power_cycle_after: 0\n"},
+		{"invalid off time", "sensors:\n  - name: attic\n    gpio_pin: 4\n    power_gpio: 22\n    power_off_time: long\n"},
+		{"zero off time", "sensors:\n  - name: attic\n    gpio_pin: 4\n    power_gpio: 22\n    power_off_time: 0s\n"},
+		{"negative warmup", "sensors:\n  - name: attic\n    gpio_pin: 4\n    power_gpio: 22\n    power_warmup: -1s\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadFromContent(t, tt.content); err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_Modbus(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: warehouse
+    temperature_unit: celsius
+    backend: modbus
+    modbus_device: /dev/ttyUSB0
+    modbus_baud_rate: 19200
+    modbus_parity: even
+    modbus_slave_id: 5
+    modbus_function: 4
+    modbus_temperature_register: 1
+    modbus_humidity_register: 2
+    modbus_temperature_scale: 0.1
+    modbus_humidity_scale: 0.1
+    modbus_timeout: 500ms
+  - name: gateway
+    temperature_unit: celsius
+    backend: modbus
+    modbus_address: 192.0.2.10:502
+    modbus_temperature_register: 0
+    modbus_data_type: float32
+    modbus_byte_order: cdab
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	want := ModbusConfig{
+		Device:              "/dev/ttyUSB0",
+		BaudRate:            19200,
+		Parity:              "even",
+		SlaveID:             5,
+		Function:            4,
+		TemperatureRegister: 1,
+		HumidityRegister:    2,
+		TemperatureScale:    0.1,
+		HumidityScale:       0.1,
+		DataType:            "int16",
+		ByteOrder:           "ABCD",
+		Timeout:             500 * time.Millisecond,
+	}
+	if got := config.Sensors[0].Modbus; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sensors[0].Modbus = %+v, want %+v", got, want)
+	}
+
+	want = ModbusConfig{
+		Address:             "192.0.2.10:502",
+		BaudRate:            DefaultModbusBaudRate,
+		Parity:              "none",
+		SlaveID:             DefaultModbusSlaveID,
+		Function:            DefaultModbusFunction,
+		TemperatureRegister: 0,
+		HumidityRegister:    -1,
+		TemperatureScale:    1,
+		HumidityScale:       1,
+		DataType:            "float32",
+		ByteOrder:           "CDAB",
+		Timeout:             DefaultModbusTimeout,
+	}
+	if got := config.Sensors[1].Modbus; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sensors[1].Modbus = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_ModbusInvalid(t *testing.T) {
+	const base = "sensors:\n  - name: warehouse\n    backend: modbus\n"
+	const valid = base + "    modbus_address: 192.0.2.10:502\n    modbus_temperature_register: 1\n"
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no transport", base + "    modbus_temperature_register: 1\n"},
+		{"both transports", valid + "    modbus_device: /dev/ttyUSB0\n"},
+		{"no temperature register", base + "    modbus_address: 192.0.2.10:502\n"},
+		{"register out of range", base + "    modbus_address: 192.0.2.10:502\n    modbus_temperature_register: 65536\n"},
+		{"invalid slave id", valid + "    modbus_slave_id: 248\n"},
+		{"invalid function", valid + "    modbus_function: 6\n"},
+		{"invalid parity", valid + "    modbus_parity: mark\n"},
+		{"invalid data type", valid + "    modbus_data_type: float64\n"},
+		{"invalid byte order", valid + "    modbus_byte_order: ACBD\n"},
+		{"zero scale", valid + "    modbus_temperature_scale: 0\n"},
+		{"invalid timeout", valid + "    modbus_timeout: soon\n"},
+		{"humidity alert without register", valid + "    alerts:\n      - metric: humidity\n        comparison: \">\"\n        threshold: 80\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFromContent(t, tt.content)
+			if err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_PollIntervalDefault(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	if config.PollInterval != DefaultPollInterval {
+		t.Errorf("Config.PollInterval = %v, want %v", config.PollInterval, DefaultPollInterval)
+	}
+	if len(config.Sinks) != 0 {
+		t.Errorf("Config.Sinks length = %d, want 0", len(config.Sinks))
+	}
+}
+
+func TestLoad_PollIntervalInvalid(t *testing.T) {
+	for _, value := range []string{"soon", "0s", "-5s"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := loadFromContent(t, minimalSensors+"poll_interval: "+value+"\n")
+			if err == nil {
+				t.Errorf("Load() expected error for poll_interval %q, got nil", value)
+			}
+		})
+	}
+}
+
+func TestLoad_Sinks(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors+`poll_interval: 15s
+sinks:
+  - type: graphite
+    address: carbon:2003
+    prefix: facilities
+    template: "{prefix}.{dht_name}.{metric}"
+  - type: statsd
+    address: localhost:8125
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	if config.PollInterval != 15*time.Second {
+		t.Errorf("Config.PollInterval = %v, want %v", config.PollInterval, 15*time.Second)
+	}
+	if len(config.Sinks) != 2 {
+		t.Fatalf("Config.Sinks length = %d, want 2", len(config.Sinks))
+	}
+
+	graphite := config.Sinks[0]
+	if graphite.Type != "graphite" || graphite.Address != "carbon:2003" || graphite.Prefix != "facilities" {
+		t.Errorf("unexpected graphite sink config: %+v", graphite)
+	}
+	if graphite.Template != "{prefix}.{dht_name}.{metric}" {
+		t.Errorf("SinkConfig.Template = %q, want %q", graphite.Template, "{prefix}.{dht_name}.{metric}")
+	}
+
+	if config.Sinks[1].Type != "statsd" {
+		t.Errorf("SinkConfig.Type = %q, want %q", config.Sinks[1].Type, "statsd")
+	}
+
+	// Name, queue size and policy fall back to defaults
+	if graphite.Name != "graphite" {
+		t.Errorf("SinkConfig.Name = %q, want %q", graphite.Name, "graphite")
+	}
+	if graphite.QueueSize != DefaultSinkQueueSize {
+		t.Errorf("SinkConfig.QueueSize = %d, want %d", graphite.QueueSize, DefaultSinkQueueSize)
+	}
+	if graphite.Policy != SinkPolicyDrop {
+		t.Errorf("SinkConfig.Policy = %q, want %q", graphite.Policy, SinkPolicyDrop)
+	}
+}
+
+func TestLoad_SinkQueueSettings(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors+`sinks:
+  - name: carbon-primary
+    type: graphite
+    address: carbon:2003
+    queue_size: 500
+    policy: block
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	sink := config.Sinks[0]
+	if sink.Name != "carbon-primary" {
+		t.Errorf("SinkConfig.Name = %q, want %q", sink.Name, "carbon-primary")
+	}
+	if sink.QueueSize != 500 {
+		t.Errorf("SinkConfig.QueueSize = %d, want %d", sink.QueueSize, 500)
+	}
+	if sink.Policy != SinkPolicyBlock {
+		t.Errorf("SinkConfig.Policy = %q, want %q", sink.Policy, SinkPolicyBlock)
+	}
+}
+
+func TestLoad_FileSink(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors+`sinks:
+  - type: file
+    path: /var/lib/dht/readings.jsonl
+    format: jsonl
+    max_size_mb: 10
+    rotate_interval: 24h
+    compress: true
+    max_files: 30
+    sync_interval: 1m
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	sink := config.Sinks[0]
+	if sink.Path != "/var/lib/dht/readings.jsonl" || sink.Format != "jsonl" {
+		t.Errorf("unexpected file sink path/format: %+v", sink)
+	}
+	if sink.MaxSizeMB != 10 || sink.MaxFiles != 30 || !sink.Compress {
+		t.Errorf("unexpected file sink retention settings: %+v", sink)
+	}
+	if sink.RotateInterval != 24*time.Hour {
+		t.Errorf("SinkConfig.RotateInterval = %v, want %v", sink.RotateInterval, 24*time.Hour)
+	}
+	if sink.SyncInterval != time.Minute {
+		t.Errorf("SinkConfig.SyncInterval = %v, want %v", sink.SyncInterval, time.Minute)
+	}
+}
+
+func TestLoad_FileSinkInvalidDuration(t *testing.T) {
+	_, err := loadFromContent(t, minimalSensors+`sinks:
+  - type: file
+    path: readings.csv
+    rotate_interval: daily
+`)
+	if err == nil {
+		t.Error("Load() expected error for invalid rotate_interval, got nil")
+	}
+}
+
+func TestLoad_Alerting(t *testing.T) {
+	config, err := loadFromContent(t, `---
+sensors:
+  - name: freezer
+    gpio_pin: 4
+    max_retries: 10
+    temperature_unit: celsius
+    alerts:
+      - name: freezer-too-warm
+        metric: temperature
+        comparison: ">"
+        threshold: -10
+        for: 5m
+        hysteresis: 1.5
+      - metric: humidity
+        comparison: ">="
+        threshold: 80
+alerting:
+  webhooks:
+    - url: https://hooks.example.com/alerts
+      body_template: '{"text": {{ json .Summary }}}'
+      timeout: 5s
+      headers:
+        Authorization: Bearer secret
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	rules := config.Sensors[0].Alerts
+	if len(rules) != 2 {
+		t.Fatalf("SensorConfig.Alerts length = %d, want 2", len(rules))
+	}
+	want := AlertRuleConfig{
+		Name:       "freezer-too-warm",
+		Metric:     "temperature",
+		Comparison: ">",
+		Threshold:  -10,
+		For:        5 * time.Minute,
+		Hysteresis: 1.5,
+	}
+	if !reflect.DeepEqual(rules[0], want) {
+		t.Errorf("Alerts[0] = %+v, want %+v", rules[0], want)
+	}
+	if rules[1].Name != "freezer-humidity" {
+		t.Errorf("Alerts[1].Name = %q, want default %q", rules[1].Name, "freezer-humidity")
+	}
+
+	webhooks := config.Alerting.Webhooks
+	if len(webhooks) != 1 {
+		t.Fatalf("Alerting.Webhooks length = %d, want 1", len(webhooks))
+	}
+	if webhooks[0].URL != "https://hooks.example.com/alerts" || webhooks[0].Timeout != 5*time.Second {
+		t.Errorf("unexpected webhook config: %+v", webhooks[0])
+	}
+	if webhooks[0].BodyTemplate != `{"text": {{ json .Summary }}}` {
+		t.Errorf("WebhookConfig.BodyTemplate = %q", webhooks[0].BodyTemplate)
+	}
+	// Header names are case-insensitive; viper may lowercase map keys
+	found := false
+	for k, v := range webhooks[0].Headers {
+		if (k == "Authorization" || k == "authorization") && v == "Bearer secret" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("WebhookConfig.Headers = %v, want Authorization header", webhooks[0].Headers)
+	}
+}
+
+func TestLoad_AlertingInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		alerts string
+	}{
+		{"missing threshold", "      - metric: temperature\n        comparison: \">\"\n"},
+		{"invalid metric", "      - metric: pressure\n        comparison: \">\"\n        threshold: 1\n"},
+		{"invalid comparison", "      - metric: humidity\n        comparison: \"==\"\n        threshold: 1\n"},
+		{"invalid for", "      - metric: humidity\n        comparison: \">\"\n        threshold: 1\n        for: soon\n"},
+		{"negative hysteresis", "      - metric: humidity\n        comparison: \">\"\n        threshold: 1\n        hysteresis: -1\n"},
+		{"reserved label", "      - metric: humidity\n        comparison: \">\"\n        threshold: 1\n        labels:\n          alertname: other\n"},
+		{"invalid label name", "      - metric: humidity\n        comparison: \">\"\n        threshold: 1\n        labels:\n          team-name: ops\n"},
+		{"duplicate name", "      - metric: humidity\n        comparison: \">\"\n        threshold: 1\n      - metric: humidity\n        comparison: \"<\"\n        threshold: 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFromContent(t, minimalSensors+"    alerts:\n"+tt.alerts)
+			if err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_AlertingReadFailureName(t *testing.T) {
+	rule := "    alerts:\n      - name: test-read-failure\n        metric: humidity\n        comparison: \">\"\n        threshold: 1\n"
+	if _, err := loadFromContent(t, minimalSensors+rule); err != nil {
+		t.Errorf("Load() returned unexpected error without read failure rules: %v", err)
+	}
+	if _, err := loadFromContent(t, minimalSensors+rule+"alerting:\n  sensor_failure_after: 3\n"); err == nil {
+		t.Error("Load() expected error for a rule named like a read failure rule, got nil")
+	}
+}
+
+func TestLoad_Alertmanagers(t *testing.T) {
+	config, err := loadFromContent(t, minimalSensors+`alerting:
+  sensor_failure_after: 3
+  external_url: http://pi.example.com:8080/
+  alertmanagers:
+    - url: http://alertmanager:9093
+      timeout: 5s
+      resend_interval: 30s
+`)
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+
+	alerting := config.Alerting
+	if alerting.SensorFailureAfter != 3 {
+		t.Errorf("Alerting.SensorFailureAfter = %d, want 3", alerting.SensorFailureAfter)
+	}
+	if alerting.ExternalURL != "http://pi.example.com:8080/" {
+		t.Errorf("Alerting.ExternalURL = %q", alerting.ExternalURL)
+	}
+	want := []AlertmanagerConfig{{URL: "http://alertmanager:9093", Timeout: 5 * time.Second, ResendInterval: 30 * time.Second}}
+	if !reflect.DeepEqual(alerting.Alertmanagers, want) {
+		t.Errorf("Alerting.Alertmanagers = %+v, want %+v", alerting.Alertmanagers, want)
+	}
+}
+
+func TestLoad_AlertmanagerInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		alerting string
+	}{
+		{"missing url", "  alertmanagers:\n    - timeout: 5s\n"},
+		{"invalid resend interval", "  alertmanagers:\n    - url: http://am:9093\n      resend_interval: often\n"},
+		{"negative failure threshold", "  sensor_failure_after: -1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFromContent(t, minimalSensors+"alerting:\n"+tt.alerting)
+			if err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_WebhookWithoutURL(t *testing.T) {
+	_, err := loadFromContent(t, minimalSensors+`alerting:
+  webhooks:
+    - timeout: 5s
+`)
+	if err == nil {
+		t.Error("Load() expected error for webhook without url, got nil")
+	}
+}
+
+func TestLoad_SinkInvalidQueueSettings(t *testing.T) {
+	tests := []struct {
+		name  string
+		sinks string
+	}{
+		{"invalid policy", "  - type: statsd\n    policy: wait\n"},
+		{"negative queue size", "  - type: statsd\n    queue_size: -1\n"},
+		{"duplicate default name", "  - type: statsd\n  - type: statsd\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadFromContent(t, minimalSensors+"sinks:\n"+tt.sinks)
+			if err == nil {
+				t.Errorf("Load() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_SinkWithoutType(t *testing.T) {
+	_, err := loadFromContent(t, minimalSensors+`sinks:
+  - address: carbon:2003
+`)
+	if err == nil {
+		t.Error("Load() expected error for sink without type, got nil")
+	}
+}
+
 // copyFile is a helper function to copy a file
 func copyFile(src, dst string) error {
 	data, err := os.ReadFile(src)
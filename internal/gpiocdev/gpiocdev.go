@@ -0,0 +1,21 @@
+package gpiocdev
+
+import (
+	"errors"
+	"time"
+)
+
+// Edge is a level change on a GPIO line as timestamped by the kernel.
+type Edge struct {
+	// Time is the kernel event timestamp (CLOCK_MONOTONIC).
+	Time time.Duration
+	// Rising is true for a low to high transition and false for high to low.
+	Rising bool
+}
+
+// ErrUnsupported is returned on platforms without the GPIO character device.
+var ErrUnsupported = errors.New("GPIO character device is only supported on Linux")
+
+// DefaultChip is the GPIO chip used when none is configured.
+// On the Raspberry Pi 5 with older kernels the header pins are on /dev/gpiochip4.
+const DefaultChip = "/dev/gpiochip0"
@@ -0,0 +1,204 @@
+package gpiocdev
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// GPIO character device v2 uAPI, see include/uapi/linux/gpio.h.
+const (
+	ioctlGetLine       = 0xc250b407 // GPIO_V2_GET_LINE_IOCTL
+	ioctlLineSetConfig = 0xc110b40d // GPIO_V2_LINE_SET_CONFIG_IOCTL
+	ioctlLineSetValues = 0xc010b40f // GPIO_V2_LINE_SET_VALUES_IOCTL
+	ioctlLineGetValues = 0xc010b40e // GPIO_V2_LINE_GET_VALUES_IOCTL
+
+	flagInput       = 1 << 2
+	flagOutput      = 1 << 3
+	flagEdgeRising  = 1 << 4
+	flagEdgeFalling = 1 << 5
+	flagBiasPullUp  = 1 << 8
+
+	attrIDOutputValues = 2
+
+	eventRisingEdge = 1
+
+	// eventBufferSize must hold a full DHT frame (about 85 edges).
+	eventBufferSize = 256
+)
+
+type lineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64
+}
+
+type lineConfigAttribute struct {
+	Attr lineAttribute
+	Mask uint64
+}
+
+type lineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [10]lineConfigAttribute
+}
+
+type lineRequest struct {
+	Offsets         [64]uint32
+	Consumer        [32]byte
+	Config          lineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type lineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+type lineEvent struct {
+	TimestampNs uint64
+	ID          uint32
+	Offset      uint32
+	Seqno       uint32
+	LineSeqno   uint32
+	Padding     [6]uint32
+}
+
+// Line is a single requested GPIO line.
+type Line struct {
+	fd     int
+	offset int
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// RequestOutput requests offset on chip as an output driven to value.
+// The line is released with Close.
+func RequestOutput(chip string, offset int, consumer string, value int) (*Line, error) {
+	cfg := lineConfig{Flags: flagOutput}
+	cfg.NumAttrs = 1
+	cfg.Attrs[0] = lineConfigAttribute{
+		Attr: lineAttribute{ID: attrIDOutputValues, Value: uint64(value & 1)},
+		Mask: 1,
+	}
+	return request(chip, offset, consumer, cfg)
+}
+
+// RequestInput requests offset on chip as an input with pull-up bias.
+// The line is released with Close.
+func RequestInput(chip string, offset int, consumer string) (*Line, error) {
+	return request(chip, offset, consumer, lineConfig{Flags: flagInput | flagBiasPullUp})
+}
+
+func request(chip string, offset int, consumer string, cfg lineConfig) (*Line, error) {
+	f, err := os.OpenFile(chip, os.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", chip, err)
+	}
+	// The line request fd stays valid after the chip is closed.
+	defer func() { _ = f.Close() }()
+
+	req := lineRequest{
+		NumLines:        1,
+		EventBufferSize: eventBufferSize,
+		Config:          cfg,
+	}
+	req.Offsets[0] = uint32(offset)
+	copy(req.Consumer[:len(req.Consumer)-1], consumer)
+
+	if err := ioctl(int(f.Fd()), ioctlGetLine, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("failed to request line %d on %s: %w", offset, chip, err)
+	}
+	return &Line{fd: int(req.Fd), offset: offset}, nil
+}
+
+// SetValue drives an output line high (1) or low (0).
+func (l *Line) SetValue(value int) error {
+	v := lineValues{Bits: uint64(value & 1), Mask: 1}
+	if err := ioctl(l.fd, ioctlLineSetValues, unsafe.Pointer(&v)); err != nil {
+		return fmt.Errorf("failed to set line %d: %w", l.offset, err)
+	}
+	return nil
+}
+
+// Value returns the current level of the line.
+func (l *Line) Value() (int, error) {
+	v := lineValues{Mask: 1}
+	if err := ioctl(l.fd, ioctlLineGetValues, unsafe.Pointer(&v)); err != nil {
+		return 0, fmt.Errorf("failed to read line %d: %w", l.offset, err)
+	}
+	return int(v.Bits & 1), nil
+}
+
+// WatchEdges reconfigures the line as a pulled-up input reporting both edges.
+// Switching to input releases the line, so this also ends a start signal.
+func (l *Line) WatchEdges() error {
+	cfg := lineConfig{Flags: flagInput | flagBiasPullUp | flagEdgeRising | flagEdgeFalling}
+	if err := ioctl(l.fd, ioctlLineSetConfig, unsafe.Pointer(&cfg)); err != nil {
+		return fmt.Errorf("failed to reconfigure line %d: %w", l.offset, err)
+	}
+	return nil
+}
+
+// ReadEdges collects edge events until limit events were read, no edge arrived
+// for idle after the first one, or timeout elapsed.
+func (l *Line) ReadEdges(limit int, idle, timeout time.Duration) ([]Edge, error) {
+	edges := make([]Edge, 0, limit)
+	buf := make([]byte, eventBufferSize*int(unsafe.Sizeof(lineEvent{})))
+	deadline := time.Now().Add(timeout)
+
+	for len(edges) < limit {
+		wait := time.Until(deadline)
+		if len(edges) > 0 && wait > idle {
+			wait = idle
+		}
+		if wait <= 0 {
+			break
+		}
+
+		fds := []unix.PollFd{{Fd: int32(l.fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(wait.Milliseconds())+1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return edges, fmt.Errorf("failed to poll line %d: %w", l.offset, err)
+		}
+		if n == 0 {
+			break
+		}
+
+		read, err := unix.Read(l.fd, buf)
+		if err != nil {
+			return edges, fmt.Errorf("failed to read events from line %d: %w", l.offset, err)
+		}
+		size := int(unsafe.Sizeof(lineEvent{}))
+		for i := 0; i+size <= read && len(edges) < limit; i += size {
+			ev := (*lineEvent)(unsafe.Pointer(&buf[i]))
+			edges = append(edges, Edge{
+				Time:   time.Duration(ev.TimestampNs),
+				Rising: ev.ID == eventRisingEdge,
+			})
+		}
+	}
+	return edges, nil
+}
+
+// Close releases the line.
+func (l *Line) Close() error {
+	return unix.Close(l.fd)
+}
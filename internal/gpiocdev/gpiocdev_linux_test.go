@@ -0,0 +1,54 @@
+package gpiocdev
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// The structs are passed to the kernel as-is, so their layout must match the uAPI
+func TestStructSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"gpio_v2_line_attribute", unsafe.Sizeof(lineAttribute{}), 16},
+		{"gpio_v2_line_config_attribute", unsafe.Sizeof(lineConfigAttribute{}), 24},
+		{"gpio_v2_line_config", unsafe.Sizeof(lineConfig{}), 272},
+		{"gpio_v2_line_request", unsafe.Sizeof(lineRequest{}), 592},
+		{"gpio_v2_line_values", unsafe.Sizeof(lineValues{}), 16},
+		{"gpio_v2_line_event", unsafe.Sizeof(lineEvent{}), 48},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("sizeof(%s) = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+// The ioctl numbers encode the struct sizes; check them against _IOWR(0xB4, nr, size)
+func TestIoctlNumbers(t *testing.T) {
+	iowr := func(nr, size uintptr) uintptr {
+		return 3<<30 | size<<16 | 0xB4<<8 | nr
+	}
+
+	if got := iowr(0x07, unsafe.Sizeof(lineRequest{})); got != ioctlGetLine {
+		t.Errorf("GPIO_V2_GET_LINE_IOCTL = %#x, want %#x", ioctlGetLine, got)
+	}
+	if got := iowr(0x0D, unsafe.Sizeof(lineConfig{})); got != ioctlLineSetConfig {
+		t.Errorf("GPIO_V2_LINE_SET_CONFIG_IOCTL = %#x, want %#x", ioctlLineSetConfig, got)
+	}
+	if got := iowr(0x0F, unsafe.Sizeof(lineValues{})); got != ioctlLineSetValues {
+		t.Errorf("GPIO_V2_LINE_SET_VALUES_IOCTL = %#x, want %#x", ioctlLineSetValues, got)
+	}
+	if got := iowr(0x0E, unsafe.Sizeof(lineValues{})); got != ioctlLineGetValues {
+		t.Errorf("GPIO_V2_LINE_GET_VALUES_IOCTL = %#x, want %#x", ioctlLineGetValues, got)
+	}
+}
+
+func TestRequestOutput_MissingChip(t *testing.T) {
+	if _, err := RequestOutput("/nonexistent/gpiochip", 4, "test", 0); err == nil {
+		t.Error("RequestOutput() expected error for missing chip, got nil")
+	}
+}
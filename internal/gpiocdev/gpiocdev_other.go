@@ -0,0 +1,43 @@
+//go:build !linux
+
+package gpiocdev
+
+import "time"
+
+// Line is a single requested GPIO line.
+type Line struct{}
+
+// RequestOutput is not supported on this platform.
+func RequestOutput(chip string, offset int, consumer string, value int) (*Line, error) {
+	return nil, ErrUnsupported
+}
+
+// RequestInput is not supported on this platform.
+func RequestInput(chip string, offset int, consumer string) (*Line, error) {
+	return nil, ErrUnsupported
+}
+
+// SetValue is not supported on this platform.
+func (l *Line) SetValue(value int) error {
+	return ErrUnsupported
+}
+
+// Value is not supported on this platform.
+func (l *Line) Value() (int, error) {
+	return 0, ErrUnsupported
+}
+
+// WatchEdges is not supported on this platform.
+func (l *Line) WatchEdges() error {
+	return ErrUnsupported
+}
+
+// ReadEdges is not supported on this platform.
+func (l *Line) ReadEdges(limit int, idle, timeout time.Duration) ([]Edge, error) {
+	return nil, ErrUnsupported
+}
+
+// Close is not supported on this platform.
+func (l *Line) Close() error {
+	return ErrUnsupported
+}
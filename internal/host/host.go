@@ -0,0 +1,158 @@
+package host
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	// sysfsRoot is where the kernel exposes sysfs.
+	sysfsRoot = "/sys"
+
+	// thermalZonesGlob matches the thermal zones under sysfsRoot.
+	thermalZonesGlob = "class/thermal/thermal_zone*"
+
+	// throttledFile is the Raspberry Pi firmware throttling state under
+	// sysfsRoot, a bitmask in hexadecimal. Older kernels do not expose it.
+	throttledFile = "devices/platform/soc/soc:firmware/get_throttled"
+
+	// throttledOccurredShift shifts the bits of the current throttling
+	// conditions to those of the conditions that occurred since boot.
+	throttledOccurredShift = 16
+)
+
+// throttledConditions are the bits of the current throttling conditions in
+// get_throttled, by condition label.
+var throttledConditions = []struct {
+	name string
+	bit  uint
+}{
+	{"under_voltage", 0},
+	{"frequency_capped", 1},
+	{"throttled", 2},
+	{"soft_temperature_limit", 3},
+}
+
+// Collector exposes the SoC temperature of the host, from its thermal zones,
+// and the throttling state of Raspberry Pi hosts. Metrics whose sysfs files
+// do not exist on the host are left out.
+type Collector struct {
+	root   string
+	logger *log.Logger
+
+	temperatureMetric       *prometheus.Desc
+	throttledMetric         *prometheus.Desc
+	throttledOccurredMetric *prometheus.Desc
+}
+
+// New creates a Collector reading the sysfs of the host, whose metric names
+// are prefixed with namespace.
+func New(namespace string, logger *log.Logger) *Collector {
+	return newCollector(sysfsRoot, namespace, logger)
+}
+
+// newCollector is New with the sysfs root as a parameter.
+func newCollector(root, namespace string, logger *log.Logger) *Collector {
+	return &Collector{
+		root:   root,
+		logger: logger,
+		temperatureMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "host_temperature_celsius"),
+			"Temperature of a thermal zone of the host, such as the SoC",
+			[]string{"zone", "type"}, nil,
+		),
+		throttledMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "host_throttled"),
+			"Whether the throttling condition is active (1) or not (0), from the Raspberry Pi firmware",
+			[]string{"condition"}, nil,
+		),
+		throttledOccurredMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "host_throttled_occurred"),
+			"Whether the throttling condition occurred since boot (1) or not (0), from the Raspberry Pi firmware",
+			[]string{"condition"}, nil,
+		),
+	}
+}
+
+// Describe sends the descriptors of the host metrics.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.temperatureMetric
+	ch <- c.throttledMetric
+	ch <- c.throttledOccurredMetric
+}
+
+// Collect reads the thermal zones and throttling state of the host.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.collectTemperatures(ch)
+	c.collectThrottled(ch)
+}
+
+// collectTemperatures sends the temperature of every thermal zone.
+func (c *Collector) collectTemperatures(ch chan<- prometheus.Metric) {
+	zones, err := filepath.Glob(filepath.Join(c.root, thermalZonesGlob))
+	if err != nil {
+		return
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		temperature, err := readInt(filepath.Join(zone, "temp"), 10)
+		if err != nil {
+			// Zones such as those of disabled sensors fail to read
+			c.logger.WithFields(log.Fields{
+				"zone":  filepath.Base(zone),
+				"error": err,
+			}).Debug("Failed to read thermal zone")
+			continue
+		}
+		// The type, such as cpu-thermal, is left empty when it cannot be read
+		zoneType, _ := os.ReadFile(filepath.Join(zone, "type"))
+		name := strings.TrimPrefix(filepath.Base(zone), "thermal_zone")
+		ch <- prometheus.MustNewConstMetric(
+			c.temperatureMetric, prometheus.GaugeValue,
+			float64(temperature)/1000, name, strings.TrimSpace(string(zoneType)),
+		)
+	}
+}
+
+// collectThrottled sends the throttling conditions of Raspberry Pi hosts.
+func (c *Collector) collectThrottled(ch chan<- prometheus.Metric) {
+	throttled, err := readInt(filepath.Join(c.root, throttledFile), 16)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.logger.WithError(err).Debug("Failed to read throttling state")
+		}
+		return
+	}
+	for _, condition := range throttledConditions {
+		ch <- prometheus.MustNewConstMetric(
+			c.throttledMetric, prometheus.GaugeValue,
+			float64(throttled>>condition.bit&1), condition.name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.throttledOccurredMetric, prometheus.GaugeValue,
+			float64(throttled>>(condition.bit+throttledOccurredShift)&1), condition.name,
+		)
+	}
+}
+
+// readInt reads a sysfs attribute holding an integer in base.
+func readInt(path string, base int) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"), base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %w", path, err)
+	}
+	return value, nil
+}
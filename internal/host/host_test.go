@@ -0,0 +1,92 @@
+package host
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// writeSysfs writes files, by path relative to root, into a fake sysfs.
+func writeSysfs(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollect_RaspberryPi(t *testing.T) {
+	root := t.TempDir()
+	writeSysfs(t, root, map[string]string{
+		"class/thermal/thermal_zone0/temp": "48312\n",
+		"class/thermal/thermal_zone0/type": "cpu-thermal\n",
+		"class/thermal/thermal_zone1/temp": "-1500\n",
+		// Under-voltage now, and frequency capping and throttling since boot
+		"devices/platform/soc/soc:firmware/get_throttled": "0x60001\n",
+	})
+
+	want := `
+# HELP dht_host_temperature_celsius Temperature of a thermal zone of the host, such as the SoC
+# TYPE dht_host_temperature_celsius gauge
+dht_host_temperature_celsius{type="cpu-thermal",zone="0"} 48.312
+dht_host_temperature_celsius{type="",zone="1"} -1.5
+# HELP dht_host_throttled Whether the throttling condition is active (1) or not (0), from the Raspberry Pi firmware
+# TYPE dht_host_throttled gauge
+dht_host_throttled{condition="frequency_capped"} 0
+dht_host_throttled{condition="soft_temperature_limit"} 0
+dht_host_throttled{condition="throttled"} 0
+dht_host_throttled{condition="under_voltage"} 1
+# HELP dht_host_throttled_occurred Whether the throttling condition occurred since boot (1) or not (0), from the Raspberry Pi firmware
+# TYPE dht_host_throttled_occurred gauge
+dht_host_throttled_occurred{condition="frequency_capped"} 1
+dht_host_throttled_occurred{condition="soft_temperature_limit"} 0
+dht_host_throttled_occurred{condition="throttled"} 1
+dht_host_throttled_occurred{condition="under_voltage"} 0
+`
+	if err := testutil.CollectAndCompare(newCollector(root, "", getSilentLogger()), strings.NewReader(want)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
+// Hosts without the firmware throttling state only expose their thermal zones
+func TestCollect_NoThrottling(t *testing.T) {
+	root := t.TempDir()
+	writeSysfs(t, root, map[string]string{
+		"class/thermal/thermal_zone0/temp": "35000",
+		"class/thermal/thermal_zone0/type": "x86_pkg_temp",
+		"class/thermal/thermal_zone1/temp": "invalid",
+	})
+
+	want := `
+# HELP dht_host_temperature_celsius Temperature of a thermal zone of the host, such as the SoC
+# TYPE dht_host_temperature_celsius gauge
+dht_host_temperature_celsius{type="x86_pkg_temp",zone="0"} 35
+`
+	if err := testutil.CollectAndCompare(newCollector(root, "", getSilentLogger()), strings.NewReader(want)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
+func TestCollect_Empty(t *testing.T) {
+	c := newCollector(t.TempDir(), "", getSilentLogger())
+	if got := testutil.CollectAndCount(c); got != 0 {
+		t.Errorf("CollectAndCount() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,10 @@
+package i2c
+
+import "errors"
+
+// ErrUnsupported is returned on platforms without i2c-dev.
+var ErrUnsupported = errors.New("I2C is only supported on Linux")
+
+// DefaultBus is the I2C bus used when none is configured.
+// It is the bus on the Raspberry Pi header pins 3 (SDA) and 5 (SCL).
+const DefaultBus = "/dev/i2c-1"
@@ -0,0 +1,55 @@
+package i2c
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctlSlave selects the target address of following reads and writes,
+// see include/uapi/linux/i2c-dev.h.
+const ioctlSlave = 0x0703 // I2C_SLAVE
+
+// Device is an I2C device at a fixed address on a bus.
+type Device struct {
+	f *os.File
+}
+
+// Open opens the device at addr on bus, e.g. "/dev/i2c-1".
+func Open(bus string, addr int) (*Device, error) {
+	f, err := os.OpenFile(bus, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", bus, err)
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), ioctlSlave, addr); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to select I2C address 0x%02x on %s: %w", addr, bus, err)
+	}
+	return &Device{f: f}, nil
+}
+
+// Tx writes w to the device, then reads len(r) bytes into r.
+// Either may be empty to only read or only write.
+func (d *Device) Tx(w, r []byte) error {
+	if len(w) > 0 {
+		if _, err := d.f.Write(w); err != nil {
+			return fmt.Errorf("I2C write failed: %w", err)
+		}
+	}
+	if len(r) > 0 {
+		n, err := d.f.Read(r)
+		if err != nil {
+			return fmt.Errorf("I2C read failed: %w", err)
+		}
+		if n != len(r) {
+			return fmt.Errorf("I2C read returned %d bytes, want %d", n, len(r))
+		}
+	}
+	return nil
+}
+
+// Close releases the bus file descriptor.
+func (d *Device) Close() error {
+	return d.f.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package i2c
+
+// Device is an I2C device at a fixed address on a bus.
+type Device struct{}
+
+// Open is not supported on this platform.
+func Open(bus string, addr int) (*Device, error) {
+	return nil, ErrUnsupported
+}
+
+// Tx is not supported on this platform.
+func (d *Device) Tx(w, r []byte) error {
+	return ErrUnsupported
+}
+
+// Close is not supported on this platform.
+func (d *Device) Close() error {
+	return ErrUnsupported
+}
@@ -0,0 +1,407 @@
+package ingest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+)
+
+// Path is the HTTP path of the ingest endpoint.
+const Path = "/api/v1/ingest"
+
+const (
+	// maxBodySize bounds the size of a request body.
+	maxBodySize = 64 << 10
+
+	// maxSensorsPerDevice bounds the number of sensors a device can create,
+	// which bounds the number of series a misbehaving device can add.
+	maxSensorsPerDevice = 32
+
+	// maxSensorNameLength bounds the length of posted sensor names.
+	maxSensorNameLength = 64
+)
+
+// quantityName is the pattern of posted quantity names.
+var quantityName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedQuantityPrefixes are the prefixes of the metrics of the exporter,
+// after the namespace. Posted quantities other than temperature and humidity
+// may not start with them, as their metrics would collide with the
+// exporter's, e.g. humidity_percent.
+var reservedQuantityPrefixes = []string{
+	"alert_", "exporter_", "host_", "humidity_", "ingest_", "read_", "reading_",
+	"sensor_", "sink_", "temperature_", "time_to_threshold",
+}
+
+// device is a configured remote device.
+type device struct {
+	config.IngestDeviceConfig
+	temperatureSymbol string
+}
+
+// entry is a remote sensor with the collector exposing its last reading.
+type entry struct {
+	remote     *Remote
+	collector  *collector.Collector
+	staleAfter time.Duration
+}
+
+// Store receives readings from remote devices on the ingest endpoint and
+// exposes them as sensors alongside the local ones. It implements
+// http.Handler for the endpoint and prometheus.Collector for the readings.
+// Remote sensors are created on their first reading and removed once they
+// have not been updated for the stale_after of their device.
+type Store struct {
+	devices       []device
+	collectorOpts collector.Options
+	logger        *log.Logger
+	timeNow       func() time.Time
+
+	mu       sync.Mutex
+	remotes  map[string]*entry
+	requests map[[2]string]uint64
+
+	requestsMetric *prometheus.Desc
+}
+
+// New creates a Store accepting readings from the devices of cfg, exposed
+// with the collector options opts. Temperatures keep the unit of their device
+// unless opts sets one. Remote sensors have the labels of cfg, so that their
+// metrics have the label names of those of the local sensors. The gpio label,
+// holding the name of the device, is kept even if opts drops it, so that the
+// sensors of different devices, and local sensors, never expose the same series.
+func New(cfg *config.IngestConfig, opts collector.Options, logger *log.Logger) *Store {
+	opts = opts.WithLabels(cfg.Labels)
+	opts.DropLabels = slices.DeleteFunc(slices.Clone(opts.DropLabels), func(label string) bool {
+		return label == config.LabelGPIO
+	})
+
+	devices := make([]device, 0, len(cfg.Devices))
+	for _, d := range cfg.Devices {
+		symbol := sensor.TemperatureSymbol(d.TemperatureUnit)
+		if d.StaleAfter == 0 {
+			d.StaleAfter = cfg.StaleAfter
+		}
+		devices = append(devices, device{IngestDeviceConfig: d, temperatureSymbol: symbol})
+	}
+
+	return &Store{
+		devices:       devices,
+		collectorOpts: opts,
+		logger:        logger,
+		timeNow:       time.Now,
+		remotes:       make(map[string]*entry),
+		requests:      make(map[[2]string]uint64),
+		requestsMetric: prometheus.NewDesc(
+			opts.MetricName("ingest_requests_total"),
+			"Total number of requests to the ingest endpoint by device and result",
+			[]string{"device", "result"}, nil,
+		),
+	}
+}
+
+// authenticate returns the device owning the bearer token of r, or nil.
+func (s *Store) authenticate(r *http.Request) *device {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	var found *device
+	for i := range s.devices {
+		// Compare against every token to not leak which one matched through timing.
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.devices[i].Token)) == 1 {
+			found = &s.devices[i]
+		}
+	}
+	return found
+}
+
+// ServeHTTP handles POST requests carrying readings keyed by sensor name,
+// either as JSON:
+//
+//	{"office": {"temperature": 21.5, "humidity": 40.2}}
+//
+// or form-encoded for a single sensor:
+//
+//	sensor=office&temperature=21.5&humidity=40.2
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d := s.authenticate(r)
+	if d == nil {
+		s.count("", "unauthorized")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dht-prometheus-exporter"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	values, status, err := parseRequest(r)
+	if err == nil {
+		err = s.ingest(d, values)
+		status = http.StatusBadRequest
+	}
+	if err != nil {
+		s.count(d.Name, "invalid")
+		s.logger.WithFields(log.Fields{
+			"device": d.Name,
+			"error":  err,
+		}).Warn("Rejected remote readings")
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	s.count(d.Name, "ok")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseRequest decodes the body of r into values keyed by sensor name and quantity.
+// On error it also returns the HTTP status to answer with.
+func parseRequest(r *http.Request) (map[string]map[string]float64, int, error) {
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("invalid content type: %w", err)
+		}
+	}
+
+	switch mediaType {
+	case "application/json":
+		var values map[string]map[string]float64
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err)
+		}
+		return values, 0, nil
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid form body: %w", err)
+		}
+		name := r.PostForm.Get("sensor")
+		if name == "" {
+			return nil, http.StatusBadRequest, errors.New("missing sensor field")
+		}
+		quantities := make(map[string]float64)
+		for key, vals := range r.PostForm {
+			if key == "sensor" {
+				continue
+			}
+			v, err := strconv.ParseFloat(vals[0], 64)
+			if err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+			quantities[key] = v
+		}
+		return map[string]map[string]float64{name: quantities}, 0, nil
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type '%s'", mediaType)
+	}
+}
+
+// ingest validates values posted by d and updates its remote sensors.
+// Nothing is stored if any sensor is invalid.
+func (s *Store) ingest(d *device, values map[string]map[string]float64) error {
+	if len(values) == 0 {
+		return errors.New("no readings")
+	}
+	now := s.timeNow()
+	readings := make(map[string]sensor.Reading, len(values))
+	for name, quantities := range values {
+		reading, err := d.reading(name, quantities, now)
+		if err != nil {
+			return err
+		}
+		readings[name] = reading
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for key := range s.remotes {
+		if strings.HasPrefix(key, d.Name+"\x00") {
+			count++
+		}
+	}
+	for name := range readings {
+		if _, ok := s.remotes[remoteKey(d.Name, name)]; !ok {
+			count++
+		}
+	}
+	if count > maxSensorsPerDevice {
+		return fmt.Errorf("too many sensors for device, at most %d are allowed", maxSensorsPerDevice)
+	}
+
+	for name, reading := range readings {
+		key := remoteKey(d.Name, name)
+		e, ok := s.remotes[key]
+		if !ok {
+			e = &entry{
+				remote:     &Remote{name: name, device: d.Name, temperatureSymbol: d.temperatureSymbol},
+				staleAfter: d.StaleAfter,
+			}
+			s.remotes[key] = e
+			s.logger.WithFields(log.Fields{
+				"device": d.Name,
+				"sensor": name,
+			}).Info("Remote sensor registered")
+		}
+		// The collector describes the sensor's quantities, so it is rebuilt when they change.
+		if e.remote.update(reading) {
+			e.collector = collector.New(e.remote, s.collectorOpts, s.logger)
+		}
+
+		s.logger.WithFields(log.Fields{
+			"device":       d.Name,
+			"sensor":       name,
+			"measurements": len(reading.Measurements),
+		}).Debug("Remote reading received")
+	}
+	return nil
+}
+
+// reading converts the posted quantities of a sensor to a sensor.Reading.
+// Temperature and humidity come first, other quantities follow by name.
+func (d *device) reading(name string, quantities map[string]float64, now time.Time) (sensor.Reading, error) {
+	if name == "" || len(name) > maxSensorNameLength {
+		return sensor.Reading{}, fmt.Errorf("invalid sensor name '%s'", name)
+	}
+	if len(quantities) == 0 {
+		return sensor.Reading{}, fmt.Errorf("no values for sensor '%s'", name)
+	}
+
+	names := make([]string, 0, len(quantities))
+	for q, v := range quantities {
+		if !quantityName.MatchString(q) {
+			return sensor.Reading{}, fmt.Errorf("invalid quantity name '%s' for sensor '%s'", q, name)
+		}
+		if isReservedQuantity(q) {
+			return sensor.Reading{}, fmt.Errorf("reserved quantity name '%s' for sensor '%s'", q, name)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return sensor.Reading{}, fmt.Errorf("invalid %s value for sensor '%s'", q, name)
+		}
+		names = append(names, q)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := quantityRank(names[i]), quantityRank(names[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	reading := sensor.Reading{Time: now, Measurements: make([]sensor.Measurement, 0, len(names))}
+	for _, q := range names {
+		reading.Measurements = append(reading.Measurements, sensor.Measurement{
+			Quantity: sensor.Quantity{Name: q, Unit: d.unit(q)},
+			Value:    quantities[q],
+		})
+	}
+	return reading, nil
+}
+
+// unit returns the unit symbol of a posted quantity.
+func (d *device) unit(quantity string) string {
+	switch quantity {
+	case sensor.QuantityTemperature:
+		return d.temperatureSymbol
+	case sensor.QuantityHumidity:
+		return sensor.UnitPercent
+	case sensor.QuantityPressure:
+		return sensor.UnitPascal
+	default:
+		return ""
+	}
+}
+
+// isReservedQuantity reports whether the metric of a posted quantity would
+// collide with a metric of the exporter.
+func isReservedQuantity(quantity string) bool {
+	for _, prefix := range reservedQuantityPrefixes {
+		if strings.HasPrefix(quantity, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// quantityRank orders temperature and humidity before other quantities.
+func quantityRank(quantity string) int {
+	switch quantity {
+	case sensor.QuantityTemperature:
+		return 0
+	case sensor.QuantityHumidity:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func remoteKey(device, sensor string) string {
+	return device + "\x00" + sensor
+}
+
+// count increments the request counter of a device and result.
+func (s *Store) count(device, result string) {
+	s.mu.Lock()
+	s.requests[[2]string{device, result}]++
+	s.mu.Unlock()
+}
+
+// Describe sends no descriptors: remote sensors appear and expire at runtime,
+// so the Store is an unchecked collector.
+func (s *Store) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect removes stale remote sensors and sends the metrics of the others,
+// followed by the request counters.
+func (s *Store) Collect(ch chan<- prometheus.Metric) {
+	now := s.timeNow()
+
+	s.mu.Lock()
+	collectors := make([]*collector.Collector, 0, len(s.remotes))
+	for key, e := range s.remotes {
+		reading, _ := e.remote.last()
+		if now.Sub(reading.Time) > e.staleAfter {
+			delete(s.remotes, key)
+			s.logger.WithFields(log.Fields{
+				"device":  e.remote.device,
+				"sensor":  e.remote.name,
+				"updated": reading.Time,
+			}).Info("Remote sensor expired")
+			continue
+		}
+		collectors = append(collectors, e.collector)
+	}
+	for k, n := range s.requests {
+		ch <- prometheus.MustNewConstMetric(s.requestsMetric, prometheus.CounterValue, float64(n), k[0], k[1])
+	}
+	s.mu.Unlock()
+
+	for _, c := range collectors {
+		c.Collect(ch)
+	}
+}
@@ -0,0 +1,378 @@
+package ingest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+)
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// newTestStore returns a Store with two devices and a controllable clock.
+func newTestStore(now *time.Time) *Store {
+	s := New(&config.IngestConfig{
+		StaleAfter: 5 * time.Minute,
+		Devices: []config.IngestDeviceConfig{
+			{Name: "esp-kitchen", Token: "kitchen-token", TemperatureUnit: "celsius"},
+			{Name: "esp-garage", Token: "garage-token", TemperatureUnit: "fahrenheit", StaleAfter: time.Minute},
+		},
+	}, collector.Options{}, getSilentLogger())
+	s.timeNow = func() time.Time { return *now }
+	return s
+}
+
+func post(s *Store, token, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+// gather registers s in a new registry and returns the metric families by name.
+func gather(t *testing.T, s *Store) map[string]*dto.MetricFamily {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(s); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, f := range families {
+		byName[f.GetName()] = f
+	}
+	return byName
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func TestServeHTTP_JSON(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	rec := post(s, "kitchen-token", "application/json",
+		`{"fridge": {"temperature": 4.5, "humidity": 60}, "oven": {"temperature": 180}}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	families := gather(t, s)
+	temperature := families["dht_temperature_degree"]
+	if temperature == nil || len(temperature.GetMetric()) != 2 {
+		t.Fatalf("dht_temperature_degree = %v, want 2 series", temperature)
+	}
+	for _, m := range temperature.GetMetric() {
+		labels := labelMap(m)
+		if labels["gpio"] != "esp-kitchen" || labels["unit"] != "C" {
+			t.Errorf("labels = %v, want gpio=esp-kitchen and unit=C", labels)
+		}
+		want := map[string]float64{"fridge": 4.5, "oven": 180}[labels["dht_name"]]
+		if m.GetGauge().GetValue() != want {
+			t.Errorf("%s temperature = %v, want %v", labels["dht_name"], m.GetGauge().GetValue(), want)
+		}
+	}
+
+	humidity := families["dht_humidity_percent"]
+	if humidity == nil || len(humidity.GetMetric()) != 1 {
+		t.Fatalf("dht_humidity_percent = %v, want 1 series", humidity)
+	}
+	if name := labelMap(humidity.GetMetric()[0])["dht_name"]; name != "fridge" {
+		t.Errorf("humidity dht_name = %q, want %q", name, "fridge")
+	}
+}
+
+func TestServeHTTP_Form(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	rec := post(s, "garage-token", "application/x-www-form-urlencoded", "sensor=garage&temperature=50&humidity=70")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	families := gather(t, s)
+	temperature := families["dht_temperature_degree"]
+	if temperature == nil || len(temperature.GetMetric()) != 1 {
+		t.Fatalf("dht_temperature_degree = %v, want 1 series", temperature)
+	}
+	m := temperature.GetMetric()[0]
+	if labels := labelMap(m); labels["dht_name"] != "garage" || labels["unit"] != "F" {
+		t.Errorf("labels = %v, want dht_name=garage and unit=F", labels)
+	}
+	if m.GetGauge().GetValue() != 50 {
+		t.Errorf("temperature = %v, want 50", m.GetGauge().GetValue())
+	}
+}
+
+func TestServeHTTP_Unauthorized(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	for _, token := range []string{"", "wrong-token"} {
+		rec := post(s, token, "application/json", `{"fridge": {"temperature": 4.5}}`)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status with token %q = %d, want %d", token, rec.Code, http.StatusUnauthorized)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("missing WWW-Authenticate header with token %q", token)
+		}
+	}
+
+	if _, ok := gather(t, s)["dht_temperature_degree"]; ok {
+		t.Error("unauthorized readings were stored")
+	}
+}
+
+func TestServeHTTP_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"malformed JSON", "application/json", `{"fridge":`, http.StatusBadRequest},
+		{"no readings", "application/json", `{}`, http.StatusBadRequest},
+		{"no values", "application/json", `{"fridge": {}}`, http.StatusBadRequest},
+		{"invalid quantity", "application/json", `{"fridge": {"Temp C": 4}}`, http.StatusBadRequest},
+		{"reserved quantity", "application/json", `{"fridge": {"humidity_percent": 40}}`, http.StatusBadRequest},
+		{"non-numeric value", "application/json", `{"fridge": {"temperature": "cold"}}`, http.StatusBadRequest},
+		{"form without sensor", "application/x-www-form-urlencoded", "temperature=4", http.StatusBadRequest},
+		{"form non-numeric value", "application/x-www-form-urlencoded", "sensor=fridge&temperature=NaN", http.StatusBadRequest},
+		{"unsupported content type", "text/plain", "fridge 4.5", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			s := newTestStore(&now)
+			rec := post(s, "kitchen-token", tt.contentType, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_MethodNotAllowed(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServeHTTP_TooManySensors(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	var body strings.Builder
+	body.WriteString("{")
+	for i := 0; i <= maxSensorsPerDevice; i++ {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		body.WriteString(`"s` + strings.Repeat("x", i) + `": {"temperature": 20}`)
+	}
+	body.WriteString("}")
+
+	rec := post(s, "kitchen-token", "application/json", body.String())
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if len(s.remotes) != 0 {
+		t.Errorf("stored %d sensors, want 0", len(s.remotes))
+	}
+}
+
+func TestCollect_Staleness(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	post(s, "kitchen-token", "application/json", `{"fridge": {"temperature": 4.5}}`)
+	post(s, "garage-token", "application/json", `{"garage": {"temperature": 50}}`)
+
+	// The garage device overrides stale_after to one minute
+	now = now.Add(2 * time.Minute)
+	temperature := gather(t, s)["dht_temperature_degree"]
+	if temperature == nil || len(temperature.GetMetric()) != 1 {
+		t.Fatalf("dht_temperature_degree = %v, want 1 series", temperature)
+	}
+	if name := labelMap(temperature.GetMetric()[0])["dht_name"]; name != "fridge" {
+		t.Errorf("dht_name = %q, want %q", name, "fridge")
+	}
+
+	now = now.Add(4 * time.Minute)
+	if _, ok := gather(t, s)["dht_temperature_degree"]; ok {
+		t.Error("expired sensors are still exposed")
+	}
+
+	// An expired sensor reappears with its next reading
+	post(s, "kitchen-token", "application/json", `{"fridge": {"temperature": 5}}`)
+	if _, ok := gather(t, s)["dht_temperature_degree"]; !ok {
+		t.Error("sensor not exposed after a new reading")
+	}
+}
+
+func TestCollect_QuantitiesChange(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	post(s, "kitchen-token", "application/json", `{"hall": {"temperature": 20}}`)
+	post(s, "kitchen-token", "application/json", `{"hall": {"temperature": 20, "pressure": 101325}}`)
+
+	pressure := gather(t, s)["dht_pressure_pascals"]
+	if pressure == nil || len(pressure.GetMetric()) != 1 {
+		t.Fatalf("dht_pressure_pascals = %v, want 1 series", pressure)
+	}
+	if v := pressure.GetMetric()[0].GetGauge().GetValue(); v != 101325 {
+		t.Errorf("pressure = %v, want 101325", v)
+	}
+}
+
+func TestCollect_Requests(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+
+	post(s, "kitchen-token", "application/json", `{"fridge": {"temperature": 4.5}}`)
+	post(s, "kitchen-token", "application/json", `{"fridge": {}}`)
+	post(s, "wrong-token", "application/json", `{"fridge": {"temperature": 4.5}}`)
+
+	requests := gather(t, s)["dht_ingest_requests_total"]
+	if requests == nil {
+		t.Fatal("dht_ingest_requests_total not exposed")
+	}
+	got := make(map[string]float64)
+	for _, m := range requests.GetMetric() {
+		labels := labelMap(m)
+		got[labels["device"]+"/"+labels["result"]] = m.GetCounter().GetValue()
+	}
+	want := map[string]float64{"esp-kitchen/ok": 1, "esp-kitchen/invalid": 1, "/unauthorized": 1}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("requests[%s] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestCollect_AlongsideLocalSensors(t *testing.T) {
+	now := time.Now()
+	opts := collector.Options{DropLabels: []string{config.LabelGPIO}}
+	s := New(&config.IngestConfig{
+		StaleAfter: 5 * time.Minute,
+		Devices: []config.IngestDeviceConfig{
+			{Name: "esp-kitchen", Token: "kitchen-token"},
+			{Name: "esp-garage", Token: "garage-token"},
+		},
+	}, opts, getSilentLogger())
+	s.timeNow = func() time.Time { return now }
+
+	// Both devices post a sensor named like the local one
+	for _, token := range []string{"kitchen-token", "garage-token"} {
+		if rec := post(s, token, "application/json", `{"fridge": {"temperature": 4.5, "humidity": 60}}`); rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusNoContent, rec.Body.String())
+		}
+	}
+
+	local := &Remote{name: "fridge", device: "GPIO4", temperatureSymbol: "C"}
+	local.update(sensor.Reading{Time: now, Measurements: []sensor.Measurement{
+		{Quantity: sensor.Quantity{Name: sensor.QuantityTemperature, Unit: "C"}, Value: 5},
+		{Quantity: sensor.Quantity{Name: sensor.QuantityHumidity, Unit: sensor.UnitPercent}, Value: 55},
+	}})
+	localRegistry := prometheus.NewPedanticRegistry()
+	if err := localRegistry.Register(collector.New(local, opts, getSilentLogger())); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	remoteRegistry := prometheus.NewPedanticRegistry()
+	if err := remoteRegistry.Register(s); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+
+	families, err := prometheus.Gatherers{localRegistry, remoteRegistry}.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() == "dht_temperature_degree" && len(f.GetMetric()) != 3 {
+			t.Errorf("dht_temperature_degree has %d series, want 3", len(f.GetMetric()))
+		}
+	}
+}
+
+func TestCollect_SensorLabels(t *testing.T) {
+	now := time.Now()
+	opts := collector.Options{ConstLabels: map[string]string{"building": "hq"}}
+	s := New(&config.IngestConfig{
+		StaleAfter: 5 * time.Minute,
+		Devices:    []config.IngestDeviceConfig{{Name: "esp-kitchen", Token: "kitchen-token"}},
+		Labels:     map[string]string{"room": ""},
+	}, opts, getSilentLogger())
+	s.timeNow = func() time.Time { return now }
+	if rec := post(s, "kitchen-token", "application/json", `{"fridge": {"temperature": 4.5}}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	// A local sensor setting the label
+	local := &Remote{name: "attic", device: "GPIO4", temperatureSymbol: "C"}
+	local.update(sensor.Reading{Time: now, Measurements: []sensor.Measurement{
+		{Quantity: sensor.Quantity{Name: sensor.QuantityTemperature, Unit: "C"}, Value: 20},
+	}})
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector.New(local, opts.WithLabels(map[string]string{"room": "attic"}), getSilentLogger())); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	if err := registry.Register(s); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "dht_temperature_degree" {
+			continue
+		}
+		if len(f.GetMetric()) != 2 {
+			t.Fatalf("dht_temperature_degree has %d series, want 2", len(f.GetMetric()))
+		}
+		for _, m := range f.GetMetric() {
+			labels := labelMap(m)
+			if _, ok := labels["room"]; !ok || labels["building"] != "hq" {
+				t.Errorf("labels = %v, want room and building=hq", labels)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+)
+
+// errNoReading is returned by remote sensors before their first reading.
+var errNoReading = errors.New("no reading received yet")
+
+// Remote is a sensor whose readings are posted by a remote device.
+// It implements sensor.MultiReader, returning the last posted reading.
+type Remote struct {
+	name              string
+	device            string
+	temperatureSymbol string
+
+	mu         sync.Mutex
+	reading    sensor.Reading
+	quantities []sensor.Quantity
+}
+
+// update stores a new reading and reports whether its set of quantities
+// differs from the previous one.
+func (r *Remote) update(reading sensor.Reading) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := len(reading.Measurements) != len(r.quantities)
+	quantities := make([]sensor.Quantity, len(reading.Measurements))
+	for i, m := range reading.Measurements {
+		quantities[i] = m.Quantity
+		if !changed && r.quantities[i] != m.Quantity {
+			changed = true
+		}
+	}
+	r.reading = reading
+	r.quantities = quantities
+	return changed
+}
+
+// Read returns the last posted reading.
+func (r *Remote) Read(ctx context.Context) (sensor.Reading, error) {
+	return r.last()
+}
+
+// last returns the last posted reading.
+func (r *Remote) last() (sensor.Reading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reading.Time.IsZero() {
+		return sensor.Reading{}, errNoReading
+	}
+	return r.reading, nil
+}
+
+// Quantities returns the quantities of the last posted reading.
+func (r *Remote) Quantities() []sensor.Quantity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.quantities
+}
+
+// ReadData returns the humidity and temperature of the last posted reading.
+func (r *Remote) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	reading, err := r.last()
+	if err != nil {
+		return 0, 0, err
+	}
+	humidity, _ = reading.Value(sensor.QuantityHumidity)
+	temperature, _ = reading.Value(sensor.QuantityTemperature)
+	return humidity, temperature, nil
+}
+
+// HasHumidity reports whether the last posted reading has a humidity value.
+func (r *Remote) HasHumidity() bool {
+	reading, err := r.last()
+	if err != nil {
+		return true
+	}
+	_, ok := reading.Value(sensor.QuantityHumidity)
+	return ok
+}
+
+// TemperatureUnit returns the temperature unit symbol of the posting device.
+func (r *Remote) TemperatureUnit() string {
+	return r.temperatureSymbol
+}
+
+// Name returns the sensor name.
+func (r *Remote) Name() string {
+	return r.name
+}
+
+// GPIO returns the name of the posting device, which stands in for the GPIO
+// pin so that sensors of different devices can share a name.
+func (r *Remote) GPIO() string {
+	return r.device
+}
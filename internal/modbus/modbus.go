@@ -0,0 +1,279 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Function codes of the register reads, see the Modbus Application Protocol
+// Specification V1.1b3 section 6.
+const (
+	FuncReadHoldingRegisters = 0x03
+	FuncReadInputRegisters   = 0x04
+)
+
+// MaxRegisters is the maximum number of registers of a single read.
+const MaxRegisters = 125
+
+// ErrUnsupported is returned on platforms without serial port support.
+var ErrUnsupported = errors.New("Modbus RTU is only supported on Linux")
+
+// Exception is an exception response of a slave.
+type Exception struct {
+	Function byte
+	Code     byte
+}
+
+var exceptionNames = map[byte]string{
+	0x01: "illegal function",
+	0x02: "illegal data address",
+	0x03: "illegal data value",
+	0x04: "slave device failure",
+	0x06: "slave device busy",
+	0x0A: "gateway path unavailable",
+	0x0B: "gateway target device failed to respond",
+}
+
+func (e *Exception) Error() string {
+	if name, ok := exceptionNames[e.Code]; ok {
+		return fmt.Sprintf("modbus exception 0x%02x (%s) for function 0x%02x", e.Code, name, e.Function)
+	}
+	return fmt.Sprintf("modbus exception 0x%02x for function 0x%02x", e.Code, e.Function)
+}
+
+// transport sends a request PDU to a slave and returns the response PDU.
+// byteCount is the byte count of a normal response, which transports reading
+// frames of unknown length check before reading the data of the response.
+type transport interface {
+	roundTrip(slave byte, pdu []byte, byteCount int) ([]byte, error)
+	Close() error
+}
+
+// Client reads registers over a Modbus TCP or RTU transport.
+// It is safe for concurrent use; requests are serialized, so several
+// slaves can share a serial bus or a TCP gateway through one Client.
+type Client struct {
+	mu sync.Mutex
+	t  transport
+}
+
+// NewTCP returns a client for the Modbus TCP server at address ("host:port").
+// The connection is made on the first request and remade after an error.
+func NewTCP(address string, timeout time.Duration) *Client {
+	return &Client{t: &tcpTransport{address: address, timeout: timeout}}
+}
+
+// NewRTU returns a client framing requests as Modbus RTU on port.
+// A read on port returning no data is treated as a response timeout.
+func NewRTU(port io.ReadWriteCloser) *Client {
+	return &Client{t: &rtuTransport{port: port}}
+}
+
+// ReadRegisters reads count registers from address with function
+// FuncReadHoldingRegisters or FuncReadInputRegisters.
+func (c *Client) ReadRegisters(slave, function byte, address, count uint16) ([]uint16, error) {
+	if function != FuncReadHoldingRegisters && function != FuncReadInputRegisters {
+		return nil, fmt.Errorf("unsupported function 0x%02x", function)
+	}
+	if count == 0 || count > MaxRegisters {
+		return nil, fmt.Errorf("invalid register count %d", count)
+	}
+
+	pdu := make([]byte, 5)
+	pdu[0] = function
+	binary.BigEndian.PutUint16(pdu[1:], address)
+	binary.BigEndian.PutUint16(pdu[3:], count)
+
+	c.mu.Lock()
+	resp, err := c.t.roundTrip(slave, pdu, 2*int(count))
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) == 2 && resp[0] == function|0x80 {
+		return nil, &Exception{Function: function, Code: resp[1]}
+	}
+	if len(resp) < 2 || resp[0] != function {
+		return nil, fmt.Errorf("unexpected response to function 0x%02x", function)
+	}
+	if int(resp[1]) != 2*int(count) || len(resp) != 2+2*int(count) {
+		return nil, fmt.Errorf("response has %d bytes of registers, want %d", resp[1], 2*count)
+	}
+	regs := make([]uint16, count)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(resp[2+2*i:])
+	}
+	return regs, nil
+}
+
+// Close closes the underlying connection or port.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Close()
+}
+
+// tcpTransport frames PDUs with the MBAP header of Modbus TCP.
+type tcpTransport struct {
+	address string
+	timeout time.Duration
+	conn    net.Conn
+	tid     uint16
+}
+
+func (t *tcpTransport) roundTrip(slave byte, pdu []byte, byteCount int) ([]byte, error) {
+	if t.conn == nil {
+		conn, err := net.DialTimeout("tcp", t.address, t.timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", t.address, err)
+		}
+		t.conn = conn
+	}
+
+	resp, err := t.exchange(slave, pdu)
+	if err != nil {
+		// The stream may hold a late response, so start over with a new connection
+		_ = t.conn.Close()
+		t.conn = nil
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *tcpTransport) exchange(slave byte, pdu []byte) ([]byte, error) {
+	if err := t.conn.SetDeadline(time.Now().Add(t.timeout)); err != nil {
+		return nil, err
+	}
+
+	t.tid++
+	req := make([]byte, 7+len(pdu))
+	binary.BigEndian.PutUint16(req[0:], t.tid)
+	binary.BigEndian.PutUint16(req[2:], 0) // protocol identifier
+	binary.BigEndian.PutUint16(req[4:], uint16(1+len(pdu)))
+	req[6] = slave
+	copy(req[7:], pdu)
+	if _, err := t.conn.Write(req); err != nil {
+		return nil, fmt.Errorf("modbus TCP write failed: %w", err)
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(t.conn, header); err != nil {
+		return nil, fmt.Errorf("modbus TCP read failed: %w", err)
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	switch {
+	case binary.BigEndian.Uint16(header[0:]) != t.tid:
+		return nil, errors.New("modbus TCP response has a different transaction identifier")
+	case binary.BigEndian.Uint16(header[2:]) != 0:
+		return nil, errors.New("modbus TCP response has a non-zero protocol identifier")
+	case header[6] != slave:
+		return nil, fmt.Errorf("modbus TCP response from unit %d, want %d", header[6], slave)
+	case length < 2 || length > 254:
+		return nil, fmt.Errorf("modbus TCP response has invalid length %d", length)
+	}
+	resp := make([]byte, length-1)
+	if _, err := io.ReadFull(t.conn, resp); err != nil {
+		return nil, fmt.Errorf("modbus TCP read failed: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *tcpTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// rtuTransport frames PDUs as Modbus RTU: slave address, PDU and CRC.
+type rtuTransport struct {
+	port io.ReadWriteCloser
+}
+
+// flusher is implemented by ports able to discard unread input.
+type flusher interface {
+	Flush() error
+}
+
+func (t *rtuTransport) roundTrip(slave byte, pdu []byte, byteCount int) ([]byte, error) {
+	// Drop the remains of a previous, timed out response
+	if f, ok := t.port.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	req := make([]byte, 0, len(pdu)+3)
+	req = append(req, slave)
+	req = append(req, pdu...)
+	req = binary.LittleEndian.AppendUint16(req, CRC16(req))
+	if _, err := t.port.Write(req); err != nil {
+		return nil, fmt.Errorf("modbus RTU write failed: %w", err)
+	}
+
+	// Read the slave address, function and first data byte, which is the
+	// exception code or the byte count, to know the frame length.
+	frame := make([]byte, 3, 3+MaxRegisters*2+2)
+	if err := t.read(frame); err != nil {
+		return nil, err
+	}
+	if frame[0] != slave {
+		return nil, fmt.Errorf("modbus RTU response from slave %d, want %d", frame[0], slave)
+	}
+	rest := 2 // CRC
+	if frame[1]&0x80 == 0 {
+		// A byte count from a faulty slave or line noise would overrun the frame
+		if int(frame[2]) != byteCount {
+			return nil, fmt.Errorf("modbus RTU response has %d bytes of data, want %d", frame[2], byteCount)
+		}
+		rest += byteCount
+	}
+	frame = frame[:3+rest]
+	if err := t.read(frame[3:]); err != nil {
+		return nil, err
+	}
+
+	n := len(frame) - 2
+	if CRC16(frame[:n]) != binary.LittleEndian.Uint16(frame[n:]) {
+		return nil, errors.New("modbus RTU response has an invalid CRC")
+	}
+	return frame[1:n], nil
+}
+
+func (t *rtuTransport) read(b []byte) error {
+	if _, err := io.ReadFull(t.port, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errors.New("modbus RTU response timed out")
+		}
+		return fmt.Errorf("modbus RTU read failed: %w", err)
+	}
+	return nil
+}
+
+func (t *rtuTransport) Close() error {
+	return t.port.Close()
+}
+
+// CRC16 returns the Modbus RTU CRC of b (polynomial 0xA001 reflected, initial value 0xFFFF).
+func CRC16(b []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, v := range b {
+		crc ^= uint16(v)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
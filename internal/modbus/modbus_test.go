@@ -0,0 +1,178 @@
+package modbus_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/modbus"
+	"github.com/guivin/dht-prometheus-exporter/internal/modbus/modbustest"
+)
+
+// fakePort is a serial port replaying a response and recording requests.
+type fakePort struct {
+	written  bytes.Buffer
+	response *bytes.Reader
+	flushes  int
+}
+
+func newFakePort(response []byte) *fakePort {
+	return &fakePort{response: bytes.NewReader(response)}
+}
+
+func (p *fakePort) Read(b []byte) (int, error)  { return p.response.Read(b) }
+func (p *fakePort) Write(b []byte) (int, error) { return p.written.Write(b) }
+func (p *fakePort) Flush() error                { p.flushes++; return nil }
+func (p *fakePort) Close() error                { return nil }
+
+// withCRC appends the Modbus RTU CRC to frame.
+func withCRC(frame ...byte) []byte {
+	crc := modbus.CRC16(frame)
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+func TestCRC16(t *testing.T) {
+	// Read one holding register at 0 from slave 1, a common reference frame
+	if crc := modbus.CRC16([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}); crc != 0x0A84 {
+		t.Errorf("CRC16() = 0x%04x, want 0x0a84", crc)
+	}
+}
+
+func TestRTU_ReadRegisters(t *testing.T) {
+	port := newFakePort(withCRC(0x01, 0x04, 0x04, 0x01, 0x0E, 0x01, 0xF4))
+	c := modbus.NewRTU(port)
+
+	regs, err := c.ReadRegisters(1, modbus.FuncReadInputRegisters, 1, 2)
+	if err != nil {
+		t.Fatalf("ReadRegisters() returned unexpected error: %v", err)
+	}
+	if len(regs) != 2 || regs[0] != 270 || regs[1] != 500 {
+		t.Errorf("ReadRegisters() = %v, want [270 500]", regs)
+	}
+
+	want := withCRC(0x01, 0x04, 0x00, 0x01, 0x00, 0x02)
+	if !bytes.Equal(port.written.Bytes(), want) {
+		t.Errorf("request = % x, want % x", port.written.Bytes(), want)
+	}
+	if port.flushes != 1 {
+		t.Errorf("flushes = %d, want 1", port.flushes)
+	}
+}
+
+func TestRTU_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte
+	}{
+		{"timeout", nil},
+		{"truncated", withCRC(0x01, 0x03, 0x02, 0x01)[:4]},
+		{"invalid CRC", append(withCRC(0x01, 0x03, 0x02, 0x01, 0x0E)[:5], 0x00, 0x00)},
+		{"other slave", withCRC(0x02, 0x03, 0x02, 0x01, 0x0E)},
+		{"wrong byte count", withCRC(0x01, 0x03, 0x04, 0x01, 0x0E, 0x01, 0xF4)},
+		// The byte count exceeds any frame and must not be trusted
+		{"byte count overflow", append([]byte{0x01, 0x03, 0xFF}, make([]byte, 260)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := modbus.NewRTU(newFakePort(tt.response))
+			if regs, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 0, 1); err == nil {
+				t.Errorf("ReadRegisters() = %v, want error", regs)
+			}
+		})
+	}
+}
+
+func TestRTU_Exception(t *testing.T) {
+	c := modbus.NewRTU(newFakePort(withCRC(0x01, 0x83, 0x02)))
+
+	_, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 100, 1)
+	var exc *modbus.Exception
+	if !errors.As(err, &exc) {
+		t.Fatalf("ReadRegisters() error = %v, want an Exception", err)
+	}
+	if exc.Code != 0x02 || exc.Function != modbus.FuncReadHoldingRegisters {
+		t.Errorf("Exception = %+v, want code 0x02 for function 0x03", exc)
+	}
+}
+
+func TestTCP_ReadRegisters(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	srv.SetHoldingRegisters(1, 0, 215, 402)
+	srv.SetInputRegisters(7, 1, 0xFF38)
+
+	c := modbus.NewTCP(srv.Addr, time.Second)
+	defer c.Close()
+
+	regs, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 0, 2)
+	if err != nil {
+		t.Fatalf("ReadRegisters() returned unexpected error: %v", err)
+	}
+	if len(regs) != 2 || regs[0] != 215 || regs[1] != 402 {
+		t.Errorf("ReadRegisters() = %v, want [215 402]", regs)
+	}
+
+	// A second unit behind the same connection
+	regs, err = c.ReadRegisters(7, modbus.FuncReadInputRegisters, 1, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisters() returned unexpected error: %v", err)
+	}
+	if regs[0] != 0xFF38 {
+		t.Errorf("ReadRegisters() = %v, want [65336]", regs)
+	}
+
+	var exc *modbus.Exception
+	if _, err := c.ReadRegisters(1, modbus.FuncReadInputRegisters, 0, 1); !errors.As(err, &exc) || exc.Code != 0x02 {
+		t.Errorf("ReadRegisters() of an unset register error = %v, want illegal data address", err)
+	}
+	if _, err := c.ReadRegisters(9, modbus.FuncReadHoldingRegisters, 0, 1); !errors.As(err, &exc) || exc.Code != 0x0B {
+		t.Errorf("ReadRegisters() of an unknown unit error = %v, want gateway target exception", err)
+	}
+}
+
+func TestTCP_Timeout(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	srv.SetHoldingRegisters(1, 0, 215)
+	srv.SetDelay(200 * time.Millisecond)
+
+	c := modbus.NewTCP(srv.Addr, 50*time.Millisecond)
+	defer c.Close()
+	if _, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 0, 1); err == nil {
+		t.Fatal("ReadRegisters() expected timeout error, got nil")
+	}
+
+	// The late response must not be taken for the answer to the next request
+	srv.SetDelay(0)
+	srv.SetHoldingRegisters(1, 0, 216)
+	regs, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 0, 1)
+	if err != nil {
+		t.Fatalf("ReadRegisters() after a timeout returned unexpected error: %v", err)
+	}
+	if regs[0] != 216 {
+		t.Errorf("ReadRegisters() = %v, want [216]", regs)
+	}
+}
+
+func TestTCP_ConnectionRefused(t *testing.T) {
+	srv := modbustest.NewServer()
+	addr := srv.Addr
+	srv.Close()
+
+	c := modbus.NewTCP(addr, 100*time.Millisecond)
+	if _, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 0, 1); err == nil {
+		t.Error("ReadRegisters() expected connection error, got nil")
+	}
+}
+
+func TestReadRegisters_InvalidRequest(t *testing.T) {
+	c := modbus.NewRTU(newFakePort(nil))
+	if _, err := c.ReadRegisters(1, 0x06, 0, 1); err == nil {
+		t.Error("ReadRegisters() expected error for unsupported function, got nil")
+	}
+	if _, err := c.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 0, modbus.MaxRegisters+1); err == nil {
+		t.Error("ReadRegisters() expected error for too many registers, got nil")
+	}
+}
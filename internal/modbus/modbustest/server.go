@@ -0,0 +1,187 @@
+package modbustest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/modbus"
+)
+
+// Exception codes answered by the server.
+const (
+	exceptionIllegalFunction    = 0x01
+	exceptionIllegalDataAddress = 0x02
+	exceptionTargetFailed       = 0x0B
+)
+
+type registerKey struct {
+	unit     byte
+	function byte
+	address  uint16
+}
+
+// Server is a Modbus TCP server answering register reads from registers set
+// with SetHoldingRegisters and SetInputRegisters. Reads from unknown units
+// get a gateway target exception and reads of unset registers an illegal
+// data address exception.
+type Server struct {
+	// Addr is the "host:port" the server listens on.
+	Addr string
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu        sync.Mutex
+	registers map[registerKey]uint16
+	units     map[byte]bool
+	delay     time.Duration
+	requests  int
+}
+
+// NewServer starts a server on a loopback port. Close it when done.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("modbustest: failed to listen: " + err.Error())
+	}
+	s := &Server{
+		Addr:      ln.Addr().String(),
+		ln:        ln,
+		registers: make(map[registerKey]uint16),
+		units:     make(map[byte]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// SetHoldingRegisters sets consecutive holding registers of unit from address.
+func (s *Server) SetHoldingRegisters(unit byte, address uint16, values ...uint16) {
+	s.set(unit, modbus.FuncReadHoldingRegisters, address, values)
+}
+
+// SetInputRegisters sets consecutive input registers of unit from address.
+func (s *Server) SetInputRegisters(unit byte, address uint16, values ...uint16) {
+	s.set(unit, modbus.FuncReadInputRegisters, address, values)
+}
+
+func (s *Server) set(unit, function byte, address uint16, values []uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.units[unit] = true
+	for i, v := range values {
+		s.registers[registerKey{unit, function, address + uint16(i)}] = v
+	}
+}
+
+// SetDelay makes the server wait d before answering each request.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	s.delay = d
+	s.mu.Unlock()
+}
+
+// Requests returns the number of requests received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Close stops the server and waits for its connections to end.
+func (s *Server) Close() {
+	_ = s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	var conns sync.WaitGroup
+	var mu sync.Mutex
+	open := make(map[net.Conn]bool)
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			break
+		}
+		mu.Lock()
+		open[conn] = true
+		mu.Unlock()
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			s.handle(conn)
+			mu.Lock()
+			delete(open, conn)
+			mu.Unlock()
+			_ = conn.Close()
+		}()
+	}
+	mu.Lock()
+	for conn := range open {
+		_ = conn.Close()
+	}
+	mu.Unlock()
+	conns.Wait()
+}
+
+func (s *Server) handle(conn net.Conn) {
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint16(header[4:])
+		if length < 2 {
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		resp := s.respond(header[6], pdu)
+
+		s.mu.Lock()
+		s.requests++
+		delay := s.delay
+		s.mu.Unlock()
+		time.Sleep(delay)
+
+		out := make([]byte, 7, 7+len(resp))
+		copy(out, header[:4])
+		binary.BigEndian.PutUint16(out[4:], uint16(1+len(resp)))
+		out[6] = header[6]
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// respond returns the response PDU to a request PDU.
+func (s *Server) respond(unit byte, pdu []byte) []byte {
+	function := pdu[0]
+	if function != modbus.FuncReadHoldingRegisters && function != modbus.FuncReadInputRegisters || len(pdu) != 5 {
+		return []byte{function | 0x80, exceptionIllegalFunction}
+	}
+	address := binary.BigEndian.Uint16(pdu[1:])
+	count := binary.BigEndian.Uint16(pdu[3:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.units[unit] {
+		return []byte{function | 0x80, exceptionTargetFailed}
+	}
+	resp := []byte{function, byte(2 * count)}
+	for i := range count {
+		v, ok := s.registers[registerKey{unit, function, address + i}]
+		if !ok {
+			return []byte{function | 0x80, exceptionIllegalDataAddress}
+		}
+		resp = binary.BigEndian.AppendUint16(resp, v)
+	}
+	return resp
+}
@@ -0,0 +1,116 @@
+package modbus
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+}
+
+// serialPort is a serial device in raw mode whose reads return no data
+// after the configured timeout.
+type serialPort struct {
+	fd int
+}
+
+// OpenRTU opens device (e.g. "/dev/ttyUSB0") as an 8 data bits, 1 stop bit
+// serial port with the given baud rate and parity ("none", "even" or "odd"),
+// and returns a Modbus RTU client waiting up to timeout for responses.
+func OpenRTU(device string, baudRate int, parity string, timeout time.Duration) (*Client, error) {
+	speed, ok := baudRates[baudRate]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", baudRate)
+	}
+
+	// The descriptor is used in blocking mode: reads are bounded by VTIME,
+	// which the runtime poller would bypass.
+	fd, err := unix.Open(device, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", device, err)
+	}
+
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to get %s attributes: %w", device, err)
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF | unix.IXANY
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CBAUD | unix.CRTSCTS
+	t.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | speed
+	switch parity {
+	case "", "none":
+	case "even":
+		t.Cflag |= unix.PARENB
+	case "odd":
+		t.Cflag |= unix.PARENB | unix.PARODD
+	default:
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("unsupported parity '%s'", parity)
+	}
+	t.Ispeed = speed
+	t.Ospeed = speed
+	// Reads return what arrived, or nothing once VTIME (in tenths of a second) elapses
+	t.Cc[unix.VMIN] = 0
+	t.Cc[unix.VTIME] = uint8(min(max(timeout/(100*time.Millisecond), 1), 255))
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, t); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to set %s attributes: %w", device, err)
+	}
+
+	return NewRTU(&serialPort{fd: fd}), nil
+}
+
+// Read returns io.EOF when no data arrived before the timeout.
+func (p *serialPort) Read(b []byte) (int, error) {
+	for {
+		n, err := unix.Read(p.fd, b)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 && len(b) > 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+}
+
+func (p *serialPort) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := unix.Write(p.fd, b[written:])
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Flush discards received but unread data.
+func (p *serialPort) Flush() error {
+	return unix.IoctlSetInt(p.fd, unix.TCFLSH, unix.TCIFLUSH)
+}
+
+func (p *serialPort) Close() error {
+	return unix.Close(p.fd)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package modbus
+
+import "time"
+
+// OpenRTU is only supported on Linux.
+func OpenRTU(device string, baudRate int, parity string, timeout time.Duration) (*Client, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,95 @@
+package poller
+
+import (
+	"context"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// Poller periodically reads every sensor and delivers the readings to a sink,
+// usually a sink.Fanout. It runs independently of Prometheus scrapes.
+type Poller struct {
+	readers  []sensor.Reader
+	sink     sink.Sink
+	interval time.Duration
+	logger   *log.Logger
+	timeNow  func() time.Time
+}
+
+// New creates a Poller that reads all sensors every interval and writes the readings to s.
+func New(readers []sensor.Reader, s sink.Sink, interval time.Duration, logger *log.Logger) *Poller {
+	return &Poller{
+		readers:  readers,
+		sink:     s,
+		interval: interval,
+		logger:   logger,
+		timeNow:  time.Now,
+	}
+}
+
+// Run polls immediately and then on every interval until ctx is cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	p.logger.WithFields(log.Fields{
+		"interval": p.interval,
+		"sensors":  len(p.readers),
+	}).Info("Starting sensor poller")
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			p.logger.Info("Sensor poller stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads every sensor once and writes the readings to the sink.
+// Sink errors are logged and do not stop polling of the remaining sensors.
+// Reads are abandoned once ctx is done, and their failure is not written.
+func (p *Poller) Poll(ctx context.Context) {
+	for _, r := range p.readers {
+		reading := p.read(ctx, r)
+		if reading.Err != nil && ctx.Err() != nil {
+			return
+		}
+		if err := p.sink.Write(reading); err != nil {
+			p.logger.WithFields(log.Fields{
+				"sensor": reading.Sensor,
+				"error":  err,
+			}).Warn("Failed to write reading to sink")
+		}
+	}
+}
+
+// read performs a single sensor read and wraps the result in a sink.Reading.
+// Humidity is set to NaN for sensors that do not measure it.
+func (p *Poller) read(ctx context.Context, r sensor.Reader) sink.Reading {
+	var humidity, temperature float64
+	reading, err := sensor.Extend(r).Read(ctx)
+	if err == nil {
+		temperature, _ = reading.Value(sensor.QuantityTemperature)
+		var ok bool
+		if humidity, ok = reading.Value(sensor.QuantityHumidity); !ok {
+			humidity = math.NaN()
+		}
+	}
+	return sink.Reading{
+		Time:        p.timeNow(),
+		Sensor:      r.Name(),
+		GPIO:        r.GPIO(),
+		Temperature: temperature,
+		Humidity:    humidity,
+		Unit:        r.TemperatureUnit(),
+		Err:         err,
+	}
+}
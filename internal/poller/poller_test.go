@@ -0,0 +1,192 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// mockSensor is a mock implementation of sensor.Reader for testing
+type mockSensor struct {
+	name        string
+	gpio        string
+	humidity    float64
+	temperature float64
+	err         error
+	unit        string
+}
+
+// ReadData returns the mock values, or the context's error once ctx is done.
+func (m *mockSensor) ReadData(ctx context.Context) (float64, float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	return m.humidity, m.temperature, m.err
+}
+
+func (m *mockSensor) TemperatureUnit() string {
+	return m.unit
+}
+
+func (m *mockSensor) Name() string {
+	return m.name
+}
+
+func (m *mockSensor) GPIO() string {
+	return m.gpio
+}
+
+// temperatureOnlySensor is a mock sensor without a humidity sensor
+type temperatureOnlySensor struct {
+	mockSensor
+}
+
+func (m *temperatureOnlySensor) HasHumidity() bool {
+	return false
+}
+
+// recordingSink stores every reading written to it
+type recordingSink struct {
+	mu       sync.Mutex
+	readings []sink.Reading
+	err      error
+}
+
+func (s *recordingSink) Start() error {
+	return nil
+}
+
+func (s *recordingSink) Write(r sink.Reading) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readings = append(s.readings, r)
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.readings)
+}
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestPoll_DeliversReadings(t *testing.T) {
+	readers := []sensor.Reader{
+		&mockSensor{name: "a", gpio: "GPIO4", humidity: 40, temperature: 20, unit: "C"},
+		&mockSensor{name: "b", gpio: "GPIO17", err: errors.New("read failed"), unit: "F"},
+	}
+	s := &recordingSink{}
+	p := New(readers, s, time.Minute, getSilentLogger())
+	now := time.Unix(1700000000, 0)
+	p.timeNow = func() time.Time { return now }
+
+	p.Poll(context.Background())
+
+	if len(s.readings) != 2 {
+		t.Fatalf("sink received %d readings, want 2", len(s.readings))
+	}
+
+	a := s.readings[0]
+	if a.Sensor != "a" || a.GPIO != "GPIO4" || a.Temperature != 20 || a.Humidity != 40 || a.Unit != "C" || a.Err != nil {
+		t.Errorf("unexpected reading for sensor a: %+v", a)
+	}
+	if !a.Time.Equal(now) {
+		t.Errorf("reading time = %v, want %v", a.Time, now)
+	}
+
+	// Failed reads are still delivered so sinks can record them
+	b := s.readings[1]
+	if b.Sensor != "b" || b.Err == nil {
+		t.Errorf("unexpected reading for sensor b: %+v", b)
+	}
+}
+
+func TestPoll_TemperatureOnly(t *testing.T) {
+	readers := []sensor.Reader{
+		&temperatureOnlySensor{mockSensor{name: "tank", temperature: 12.5, unit: "C"}},
+	}
+	s := &recordingSink{}
+	New(readers, s, time.Minute, getSilentLogger()).Poll(context.Background())
+
+	if r := s.readings[0]; r.Temperature != 12.5 || !math.IsNaN(r.Humidity) {
+		t.Errorf("reading = %+v, want temperature 12.5 and NaN humidity", r)
+	}
+}
+
+// A sink error must not stop polling of the remaining sensors
+func TestPoll_SinkErrorContinues(t *testing.T) {
+	readers := []sensor.Reader{
+		&mockSensor{name: "a", unit: "C"},
+		&mockSensor{name: "b", unit: "C"},
+	}
+	failing := &recordingSink{err: errors.New("unavailable")}
+	p := New(readers, failing, time.Minute, getSilentLogger())
+
+	p.Poll(context.Background())
+
+	if failing.count() != 2 {
+		t.Errorf("sink received %d readings, want 2", failing.count())
+	}
+}
+
+// Readings abandoned at shutdown are not delivered as failures
+func TestPoll_Cancelled(t *testing.T) {
+	readers := []sensor.Reader{&mockSensor{name: "a", unit: "C"}}
+	s := &recordingSink{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	New(readers, s, time.Minute, getSilentLogger()).Poll(ctx)
+
+	if s.count() != 0 {
+		t.Errorf("sink received %d readings, want 0", s.count())
+	}
+}
+
+func TestRun_StopsOnCancel(t *testing.T) {
+	readers := []sensor.Reader{&mockSensor{name: "a", unit: "C"}}
+	s := &recordingSink{}
+	p := New(readers, s, 10*time.Millisecond, getSilentLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for s.count() < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("Timed out waiting for polls")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+}
@@ -0,0 +1,184 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// AHT20 commands and timings, see the Aosong AHT20 datasheet section 5.
+const (
+	aht20DefaultAddress = 0x38
+
+	aht20CmdStatus  = 0x71
+	aht20CmdInit    = 0xBE
+	aht20CmdTrigger = 0xAC
+
+	aht20StatusBusy       = 0x80
+	aht20StatusCalibrated = 0x08
+
+	// aht20InitTime is the wait after sending the initialization command.
+	aht20InitTime = 10 * time.Millisecond
+
+	// aht20MeasureTime is the wait after triggering a measurement.
+	aht20MeasureTime = 80 * time.Millisecond
+)
+
+// aht20Convert converts the 20-bit AHT20 signals to degrees Celsius and
+// percent relative humidity (datasheet section 6).
+func aht20Convert(rawRH, rawT uint32) (temperature, humidity float64) {
+	humidity = float64(rawRH) / (1 << 20) * 100
+	temperature = float64(rawT)/(1<<20)*200 - 50
+	return temperature, humidity
+}
+
+// AHT20Sensor implements the Reader interface for Aosong AHT20 temperature
+// and humidity sensors on I2C.
+type AHT20Sensor struct {
+	name       string
+	gpio       string
+	location   string
+	maxRetries int
+	logger     *log.Logger
+
+	mu  sync.Mutex
+	dev i2cConn
+
+	// sleep is replaced in tests.
+	sleep func(time.Duration)
+}
+
+// NewAHT20 opens the AHT20 configured by i2c_bus and i2c_address (default 0x38)
+// and calibrates it if needed.
+// Returns an error if the sensor does not answer.
+func NewAHT20(cfg *config.SensorConfig, logger *log.Logger) (*AHT20Sensor, error) {
+	dev, location, err := openI2C(cfg, aht20DefaultAddress)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newAHT20(cfg, logger, dev, location, time.Sleep)
+	if err != nil {
+		_ = dev.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newAHT20 initializes an AHT20 on an open device.
+func newAHT20(cfg *config.SensorConfig, logger *log.Logger, dev i2cConn, location string, sleep func(time.Duration)) (*AHT20Sensor, error) {
+	logger.WithFields(log.Fields{
+		"sensor": cfg.Name,
+		"device": location,
+	}).Info("Initializing AHT20 sensor")
+
+	status := make([]byte, 1)
+	if err := dev.Tx([]byte{aht20CmdStatus}, status); err != nil {
+		return nil, fmt.Errorf("failed to read AHT20 status for sensor '%s': %w", cfg.Name, err)
+	}
+	if status[0]&aht20StatusCalibrated == 0 {
+		if err := dev.Tx([]byte{aht20CmdInit, 0x08, 0x00}, nil); err != nil {
+			return nil, fmt.Errorf("failed to initialize AHT20 for sensor '%s': %w", cfg.Name, err)
+		}
+		sleep(aht20InitTime)
+	}
+
+	return &AHT20Sensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		location:   location,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		dev:        dev,
+		sleep:      sleep,
+	}, nil
+}
+
+// measure triggers a measurement and returns the raw 20-bit signals.
+func (s *AHT20Sensor) measure() (rawRH, rawT uint32, err error) {
+	if err := s.dev.Tx([]byte{aht20CmdTrigger, 0x33, 0x00}, nil); err != nil {
+		return 0, 0, err
+	}
+	s.sleep(aht20MeasureTime)
+
+	data := make([]byte, 7)
+	if err := s.dev.Tx(nil, data); err != nil {
+		return 0, 0, err
+	}
+	if data[0]&aht20StatusBusy != 0 {
+		return 0, 0, errors.New("AHT20 measurement did not complete")
+	}
+	if crc8(data[:6]) != data[6] {
+		return 0, 0, ErrCRC
+	}
+	rawRH = uint32(data[1])<<12 | uint32(data[2])<<4 | uint32(data[3])>>4
+	rawT = uint32(data[3]&0x0F)<<16 | uint32(data[4])<<8 | uint32(data[5])
+	return rawRH, rawT, nil
+}
+
+// ReadData reads humidity and temperature, retrying up to max_retries times.
+// Returns an error if all attempts fail.
+func (s *AHT20Sensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rawRH, rawT uint32
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		rawRH, rawT, err = s.measure()
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"device":  s.location,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"device": s.location,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return 0, 0, err
+	}
+
+	temperature, humidity = aht20Convert(rawRH, rawT)
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"device":      s.location,
+		"humidity":    humidity,
+		"temperature": temperature,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	return humidity, temperature, nil
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *AHT20Sensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *AHT20Sensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *AHT20Sensor) GPIO() string {
+	return s.gpio
+}
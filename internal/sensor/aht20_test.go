@@ -0,0 +1,107 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// aht20Response encodes raw signals as the 7-byte AHT20 measurement response
+func aht20Response(status byte, rawRH, rawT uint32) []byte {
+	data := []byte{
+		status,
+		byte(rawRH >> 12), byte(rawRH >> 4), byte(rawRH<<4) | byte(rawT>>16&0x0F),
+		byte(rawT >> 8), byte(rawT), 0,
+	}
+	data[6] = crc8(data[:6])
+	return data
+}
+
+func newTestAHT20(t *testing.T, unit string, status byte, responses ...[]byte) (*AHT20Sensor, *fakeI2C) {
+	t.Helper()
+	dev := &fakeI2C{
+		regs:      map[byte][]byte{aht20CmdStatus: {status}},
+		responses: responses,
+	}
+	s, err := newAHT20(testI2CConfig(unit), getSilentLogger(), dev, "/dev/i2c-1@0x38", func(time.Duration) {})
+	if err != nil {
+		t.Fatalf("newAHT20() returned unexpected error: %v", err)
+	}
+	return s, dev
+}
+
+func TestAHT20Sensor_ImplementsReader(t *testing.T) {
+	var _ Reader = (*AHT20Sensor)(nil)
+}
+
+// Conversion formulas of the AHT20 datasheet, section 6
+func TestAHT20Convert(t *testing.T) {
+	tests := []struct {
+		rawRH, rawT           uint32
+		temperature, humidity float64
+	}{
+		{0x00000, 0x00000, -50, 0},
+		{0x80000, 0x60000, 25, 50},
+		{0xC0000, 0x80000, 50, 75},
+	}
+
+	for _, tt := range tests {
+		temperature, humidity := aht20Convert(tt.rawRH, tt.rawT)
+		if temperature != tt.temperature || humidity != tt.humidity {
+			t.Errorf("aht20Convert(0x%05x, 0x%05x) = %v, %v; want %v, %v",
+				tt.rawRH, tt.rawT, temperature, humidity, tt.temperature, tt.humidity)
+		}
+	}
+}
+
+func TestAHT20Sensor_ReadData(t *testing.T) {
+	s, dev := newTestAHT20(t, "celsius", aht20StatusCalibrated, aht20Response(0x1C, 0x80000, 0x60000))
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 50 || temperature != 25 {
+		t.Errorf("ReadData() = %v, %v; want 50, 25", humidity, temperature)
+	}
+	// Status read, then trigger measurement; no initialization when calibrated
+	if len(dev.writes) != 2 || dev.writes[1][0] != aht20CmdTrigger {
+		t.Errorf("writes = %x, want status then trigger", dev.writes)
+	}
+}
+
+func TestNewAHT20_Initializes(t *testing.T) {
+	_, dev := newTestAHT20(t, "celsius", 0x10)
+
+	if len(dev.writes) != 2 || dev.writes[1][0] != aht20CmdInit {
+		t.Errorf("writes = %x, want status then initialization", dev.writes)
+	}
+}
+
+func TestAHT20Sensor_Errors(t *testing.T) {
+	badCRC := aht20Response(0x1C, 0x80000, 0x60000)
+	badCRC[6] ^= 0xFF
+
+	tests := []struct {
+		name     string
+		response []byte
+		wantErr  error
+	}{
+		{"busy", aht20Response(aht20StatusBusy|0x1C, 0x80000, 0x60000), nil},
+		{"bad crc", badCRC, ErrCRC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestAHT20(t, "celsius", aht20StatusCalibrated, tt.response, tt.response, tt.response)
+			_, _, err := s.ReadData(context.Background())
+			if err == nil {
+				t.Fatal("ReadData() expected error, got nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadData() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
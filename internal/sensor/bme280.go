@@ -0,0 +1,322 @@
+package sensor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// BME280 registers and settings, see the Bosch BME280 datasheet section 5.
+const (
+	bme280DefaultAddress = 0x76
+
+	bme280RegCalib00  = 0x88 // dig_T1 to dig_H1, 26 bytes
+	bme280RegChipID   = 0xD0
+	bme280RegCalib26  = 0xE1 // dig_H2 to dig_H6, 7 bytes
+	bme280RegCtrlHum  = 0xF2
+	bme280RegStatus   = 0xF3
+	bme280RegCtrlMeas = 0xF4
+	bme280RegData     = 0xF7 // press_msb to hum_lsb, 8 bytes
+
+	bme280ChipID = 0x60
+
+	// bme280CtrlHum selects x1 humidity oversampling.
+	bme280CtrlHum = 0x01
+	// bme280CtrlMeas selects x1 temperature and pressure oversampling in forced mode.
+	bme280CtrlMeas = 0x25
+
+	bme280StatusMeasuring = 0x08
+
+	// bme280MeasureTime is the maximum measurement time with x1 oversampling (datasheet 9.1).
+	bme280MeasureTime = 10 * time.Millisecond
+
+	// bme280SkippedTemperature is the raw temperature when no measurement took place.
+	bme280SkippedTemperature = 0x80000
+)
+
+// bme280Calibration holds the trimming parameters stored in the sensor's NVM.
+type bme280Calibration struct {
+	T1 uint16
+	T2 int16
+	T3 int16
+
+	P1 uint16
+	P2 int16
+	P3 int16
+	P4 int16
+	P5 int16
+	P6 int16
+	P7 int16
+	P8 int16
+	P9 int16
+
+	H1 uint8
+	H2 int16
+	H3 uint8
+	H4 int16
+	H5 int16
+	H6 int8
+}
+
+// parseBME280Calibration decodes the calib00 (0x88-0xA1) and calib26 (0xE1-0xE7) register blocks.
+func parseBME280Calibration(calib00, calib26 []byte) bme280Calibration {
+	le := binary.LittleEndian
+	return bme280Calibration{
+		T1: le.Uint16(calib00[0:]),
+		T2: int16(le.Uint16(calib00[2:])),
+		T3: int16(le.Uint16(calib00[4:])),
+		P1: le.Uint16(calib00[6:]),
+		P2: int16(le.Uint16(calib00[8:])),
+		P3: int16(le.Uint16(calib00[10:])),
+		P4: int16(le.Uint16(calib00[12:])),
+		P5: int16(le.Uint16(calib00[14:])),
+		P6: int16(le.Uint16(calib00[16:])),
+		P7: int16(le.Uint16(calib00[18:])),
+		P8: int16(le.Uint16(calib00[20:])),
+		P9: int16(le.Uint16(calib00[22:])),
+		H1: calib00[25],
+		H2: int16(le.Uint16(calib26[0:])),
+		H3: calib26[2],
+		// dig_H4 and dig_H5 are signed 12-bit values sharing the nibbles of 0xE5.
+		H4: int16(int8(calib26[3]))<<4 | int16(calib26[4]&0x0F),
+		H5: int16(int8(calib26[5]))<<4 | int16(calib26[4]>>4),
+		H6: int8(calib26[6]),
+	}
+}
+
+// compensate converts raw ADC values to degrees Celsius, pascals and percent
+// relative humidity using the floating point formulas of datasheet section 8.1.
+func (c *bme280Calibration) compensate(adcT, adcP, adcH int32) (temperature, pressure, humidity float64) {
+	t := float64(adcT)
+	v1 := (t/16384 - float64(c.T1)/1024) * float64(c.T2)
+	v2 := (t/131072 - float64(c.T1)/8192) * (t/131072 - float64(c.T1)/8192) * float64(c.T3)
+	tFine := v1 + v2
+	temperature = tFine / 5120
+
+	v1 = tFine/2 - 64000
+	v2 = v1 * v1 * float64(c.P6) / 32768
+	v2 += v1 * float64(c.P5) * 2
+	v2 = v2/4 + float64(c.P4)*65536
+	v1 = (float64(c.P3)*v1*v1/524288 + float64(c.P2)*v1) / 524288
+	v1 = (1 + v1/32768) * float64(c.P1)
+	if v1 != 0 {
+		p := 1048576 - float64(adcP)
+		p = (p - v2/4096) * 6250 / v1
+		v1 = float64(c.P9) * p * p / 2147483648
+		v2 = p * float64(c.P8) / 32768
+		pressure = p + (v1+v2+float64(c.P7))/16
+	}
+
+	h := tFine - 76800
+	h = (float64(adcH) - (float64(c.H4)*64 + float64(c.H5)/16384*h)) *
+		(float64(c.H2) / 65536 * (1 + float64(c.H6)/67108864*h*(1+float64(c.H3)/67108864*h)))
+	h *= 1 - float64(c.H1)*h/524288
+	humidity = min(max(h, 0), 100)
+
+	return temperature, pressure, humidity
+}
+
+// BME280Sensor implements the MultiReader interface for Bosch BME280
+// temperature, humidity and pressure sensors on I2C.
+type BME280Sensor struct {
+	name       string
+	gpio       string
+	location   string
+	maxRetries int
+	logger     *log.Logger
+
+	mu    sync.Mutex
+	dev   i2cConn
+	calib bme280Calibration
+
+	// sleep is replaced in tests.
+	sleep func(time.Duration)
+}
+
+// NewBME280 opens the BME280 configured by i2c_bus and i2c_address
+// (default 0x76) and reads its calibration.
+// Returns an error if no BME280 answers at that address.
+func NewBME280(cfg *config.SensorConfig, logger *log.Logger) (*BME280Sensor, error) {
+	dev, location, err := openI2C(cfg, bme280DefaultAddress)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newBME280(cfg, logger, dev, location)
+	if err != nil {
+		_ = dev.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newBME280 initializes a BME280 on an open device.
+func newBME280(cfg *config.SensorConfig, logger *log.Logger, dev i2cConn, location string) (*BME280Sensor, error) {
+	logger.WithFields(log.Fields{
+		"sensor": cfg.Name,
+		"device": location,
+	}).Info("Initializing BME280 sensor")
+
+	id := make([]byte, 1)
+	if err := dev.Tx([]byte{bme280RegChipID}, id); err != nil {
+		return nil, fmt.Errorf("failed to read BME280 chip ID for sensor '%s': %w", cfg.Name, err)
+	}
+	if id[0] != bme280ChipID {
+		return nil, fmt.Errorf("unexpected chip ID 0x%02x for sensor '%s' (want BME280 0x%02x)", id[0], cfg.Name, bme280ChipID)
+	}
+
+	calib00 := make([]byte, 26)
+	calib26 := make([]byte, 7)
+	if err := dev.Tx([]byte{bme280RegCalib00}, calib00); err != nil {
+		return nil, fmt.Errorf("failed to read BME280 calibration for sensor '%s': %w", cfg.Name, err)
+	}
+	if err := dev.Tx([]byte{bme280RegCalib26}, calib26); err != nil {
+		return nil, fmt.Errorf("failed to read BME280 calibration for sensor '%s': %w", cfg.Name, err)
+	}
+
+	return &BME280Sensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		location:   location,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		dev:        dev,
+		calib:      parseBME280Calibration(calib00, calib26),
+		sleep:      time.Sleep,
+	}, nil
+}
+
+// measure triggers a forced mode measurement and returns the raw ADC values.
+func (s *BME280Sensor) measure() (adcT, adcP, adcH int32, err error) {
+	// ctrl_hum only takes effect after the following write to ctrl_meas.
+	if err := s.dev.Tx([]byte{bme280RegCtrlHum, bme280CtrlHum}, nil); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := s.dev.Tx([]byte{bme280RegCtrlMeas, bme280CtrlMeas}, nil); err != nil {
+		return 0, 0, 0, err
+	}
+
+	status := make([]byte, 1)
+	for wait := 0; ; wait++ {
+		s.sleep(bme280MeasureTime)
+		if err := s.dev.Tx([]byte{bme280RegStatus}, status); err != nil {
+			return 0, 0, 0, err
+		}
+		if status[0]&bme280StatusMeasuring == 0 {
+			break
+		}
+		if wait == 2 {
+			return 0, 0, 0, errors.New("BME280 measurement did not complete")
+		}
+	}
+
+	data := make([]byte, 8)
+	if err := s.dev.Tx([]byte{bme280RegData}, data); err != nil {
+		return 0, 0, 0, err
+	}
+	adcP = int32(data[0])<<12 | int32(data[1])<<4 | int32(data[2])>>4
+	adcT = int32(data[3])<<12 | int32(data[4])<<4 | int32(data[5])>>4
+	adcH = int32(data[6])<<8 | int32(data[7])
+	if adcT == bme280SkippedTemperature {
+		return 0, 0, 0, errors.New("BME280 returned no temperature measurement")
+	}
+	return adcT, adcP, adcH, nil
+}
+
+// Read measures temperature, humidity and pressure, retrying up to max_retries times.
+// Returns an error if all attempts fail.
+func (s *BME280Sensor) Read(ctx context.Context) (Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var adcT, adcP, adcH int32
+	var err error
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		adcT, adcP, adcH, err = s.measure()
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"device":  s.location,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"device": s.location,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return Reading{}, err
+	}
+
+	temperature, pressure, humidity := s.calib.compensate(adcT, adcP, adcH)
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"device":      s.location,
+		"humidity":    humidity,
+		"temperature": temperature,
+		"pressure":    pressure,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	quantities := s.Quantities()
+	return Reading{
+		Time: time.Now(),
+		Measurements: []Measurement{
+			{Quantity: quantities[0], Value: temperature},
+			{Quantity: quantities[1], Value: humidity},
+			{Quantity: quantities[2], Value: pressure},
+		},
+	}, nil
+}
+
+// ReadData reads humidity and temperature; the pressure is discarded.
+func (s *BME280Sensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	reading, err := s.Read(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	temperature, _ = reading.Value(QuantityTemperature)
+	humidity, _ = reading.Value(QuantityHumidity)
+	return humidity, temperature, nil
+}
+
+// Quantities returns temperature, humidity and pressure.
+func (s *BME280Sensor) Quantities() []Quantity {
+	return []Quantity{
+		{Name: QuantityTemperature, Unit: CelsiusSymbol},
+		{Name: QuantityHumidity, Unit: UnitPercent},
+		{Name: QuantityPressure, Unit: UnitPascal},
+	}
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *BME280Sensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *BME280Sensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *BME280Sensor) GPIO() string {
+	return s.gpio
+}
@@ -0,0 +1,208 @@
+package sensor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// bme280TestCalibration holds the trimming parameters of the BMP280 datasheet
+// computation example (section 3.12), which uses the same temperature and
+// pressure formulas as the BME280, plus typical humidity parameters.
+var bme280TestCalibration = bme280Calibration{
+	T1: 27504, T2: 26435, T3: -1000,
+	P1: 36477, P2: -10685, P3: 3024, P4: 2855, P5: 140, P6: -7, P7: 15500, P8: -14600, P9: 6000,
+	H1: 75, H2: 362, H3: 0, H4: 313, H5: 50, H6: 30,
+}
+
+// Raw values of the datasheet example
+const (
+	bme280TestAdcT = 519888
+	bme280TestAdcP = 415148
+	bme280TestAdcH = 30000
+)
+
+// bme280Registers encodes c as the calib00 and calib26 register blocks
+func bme280Registers(c bme280Calibration) (calib00, calib26 []byte) {
+	calib00 = make([]byte, 26)
+	le := binary.LittleEndian
+	for i, v := range []uint16{
+		c.T1, uint16(c.T2), uint16(c.T3),
+		c.P1, uint16(c.P2), uint16(c.P3), uint16(c.P4), uint16(c.P5), uint16(c.P6), uint16(c.P7), uint16(c.P8), uint16(c.P9),
+	} {
+		le.PutUint16(calib00[2*i:], v)
+	}
+	calib00[25] = c.H1
+
+	calib26 = make([]byte, 7)
+	le.PutUint16(calib26[0:], uint16(c.H2))
+	calib26[2] = c.H3
+	calib26[3] = byte(c.H4 >> 4)
+	calib26[4] = byte(c.H4&0x0F) | byte(c.H5&0x0F)<<4
+	calib26[5] = byte(c.H5 >> 4)
+	calib26[6] = byte(c.H6)
+	return calib00, calib26
+}
+
+// bme280HumidityInt is the 32-bit fixed point humidity compensation of the
+// BME280 datasheet (section 4.2.3), used as a reference for the float formula
+func bme280HumidityInt(c bme280Calibration, tFine, adcH int32) float64 {
+	v := tFine - 76800
+	a := ((adcH << 14) - (int32(c.H4) << 20) - (int32(c.H5) * v) + 16384) >> 15
+	b := (((v * int32(c.H6)) >> 10) * (((v * int32(c.H3)) >> 11) + 32768)) >> 10
+	b = ((b+2097152)*int32(c.H2) + 8192) >> 14
+	v = a * b
+	v -= ((((v >> 15) * (v >> 15)) >> 7) * int32(c.H1)) >> 4
+	v = min(max(v, 0), 419430400)
+	return float64(v>>12) / 1024
+}
+
+// newTestBME280 returns a BME280Sensor on a fake device holding the test
+// calibration and the given measurement registers
+func newTestBME280(t *testing.T, unit string, data []byte) (*BME280Sensor, *fakeI2C) {
+	t.Helper()
+	calib00, calib26 := bme280Registers(bme280TestCalibration)
+	dev := &fakeI2C{regs: map[byte][]byte{
+		bme280RegChipID:  {bme280ChipID},
+		bme280RegCalib00: calib00,
+		bme280RegCalib26: calib26,
+		bme280RegStatus:  {0},
+		bme280RegData:    data,
+	}}
+	s, err := newBME280(testI2CConfig(unit), getSilentLogger(), dev, "/dev/i2c-1@0x76")
+	if err != nil {
+		t.Fatalf("newBME280() returned unexpected error: %v", err)
+	}
+	s.sleep = func(time.Duration) {}
+	return s, dev
+}
+
+// bme280Data encodes raw ADC values as the 0xF7-0xFE measurement registers
+func bme280Data(adcT, adcP, adcH int32) []byte {
+	return []byte{
+		byte(adcP >> 12), byte(adcP >> 4), byte(adcP << 4),
+		byte(adcT >> 12), byte(adcT >> 4), byte(adcT << 4),
+		byte(adcH >> 8), byte(adcH),
+	}
+}
+
+func TestBME280Sensor_ImplementsMultiReader(t *testing.T) {
+	var _ MultiReader = (*BME280Sensor)(nil)
+}
+
+func TestParseBME280Calibration(t *testing.T) {
+	for _, c := range []bme280Calibration{
+		bme280TestCalibration,
+		{H4: -1000, H5: -3, H6: -7, H2: -20},
+	} {
+		calib00, calib26 := bme280Registers(c)
+		if got := parseBME280Calibration(calib00, calib26); got != c {
+			t.Errorf("parseBME280Calibration() = %+v, want %+v", got, c)
+		}
+	}
+}
+
+// Expected temperature and pressure are the results of the datasheet example
+func TestBME280Compensate(t *testing.T) {
+	c := bme280TestCalibration
+	temperature, pressure, _ := c.compensate(bme280TestAdcT, bme280TestAdcP, bme280TestAdcH)
+
+	if math.Abs(temperature-25.08) > 0.005 {
+		t.Errorf("temperature = %v, want 25.08", temperature)
+	}
+	if math.Abs(pressure-100653.27) > 0.005 {
+		t.Errorf("pressure = %v, want 100653.27", pressure)
+	}
+}
+
+func TestBME280Compensate_Humidity(t *testing.T) {
+	c := bme280TestCalibration
+	// t_fine of the datasheet example
+	const tFine = 128422
+
+	for _, adcH := range []int32{0, 25000, 30000, 35000, 65535} {
+		_, _, humidity := c.compensate(bme280TestAdcT, bme280TestAdcP, adcH)
+		want := bme280HumidityInt(c, tFine, adcH)
+		if math.Abs(humidity-want) > 0.01 {
+			t.Errorf("humidity(adc_H=%d) = %v, want %v", adcH, humidity, want)
+		}
+		if humidity < 0 || humidity > 100 {
+			t.Errorf("humidity(adc_H=%d) = %v, want within 0-100", adcH, humidity)
+		}
+	}
+}
+
+func TestBME280Sensor_Read(t *testing.T) {
+	s, dev := newTestBME280(t, "celsius", bme280Data(bme280TestAdcT, bme280TestAdcP, bme280TestAdcH))
+
+	reading, err := s.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	want := map[string]float64{QuantityTemperature: 25.08, QuantityHumidity: 55.0, QuantityPressure: 100653.27}
+	for _, q := range s.Quantities() {
+		value, ok := reading.Value(q.Name)
+		if !ok || math.Abs(value-want[q.Name]) > 0.01 {
+			t.Errorf("%s = %v, want about %v", q.Name, value, want[q.Name])
+		}
+	}
+	if len(reading.Measurements) != 3 || reading.Measurements[2].Unit != UnitPascal {
+		t.Errorf("Measurements = %+v, want temperature, humidity and pressure in Pa", reading.Measurements)
+	}
+
+	// Forced mode is requested with ctrl_hum written before ctrl_meas
+	var ctrl [][]byte
+	for _, w := range dev.writes {
+		if len(w) == 2 {
+			ctrl = append(ctrl, w)
+		}
+	}
+	if len(ctrl) != 2 || ctrl[0][0] != bme280RegCtrlHum || ctrl[1][0] != bme280RegCtrlMeas || ctrl[1][1]&0x03 != 0x01 {
+		t.Errorf("control writes = %x, want ctrl_hum then ctrl_meas in forced mode", ctrl)
+	}
+}
+
+// Sensors read in Celsius whatever their temperature_unit, which the exporter applies
+func TestBME280Sensor_ReadsCelsius(t *testing.T) {
+	s, _ := newTestBME280(t, "fahrenheit", bme280Data(bme280TestAdcT, bme280TestAdcP, bme280TestAdcH))
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if math.Abs(temperature-25.08) > 0.01 {
+		t.Errorf("temperature = %v, want about 25.08", temperature)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+func TestBME280Sensor_SkippedMeasurement(t *testing.T) {
+	s, _ := newTestBME280(t, "celsius", bme280Data(bme280SkippedTemperature, 0, 0))
+
+	if _, _, err := s.ReadData(context.Background()); err == nil {
+		t.Error("ReadData() expected error for skipped measurement, got nil")
+	}
+}
+
+func TestNewBME280_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		dev  *fakeI2C
+	}{
+		{"wrong chip", &fakeI2C{regs: map[byte][]byte{bme280RegChipID: {0x58}}}},
+		{"bus error", &fakeI2C{err: errors.New("remote I/O error")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newBME280(testI2CConfig("celsius"), getSilentLogger(), tt.dev, "test"); err == nil {
+				t.Error("newBME280() expected error, got nil")
+			}
+		})
+	}
+}
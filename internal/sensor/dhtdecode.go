@@ -0,0 +1,78 @@
+package sensor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/gpiocdev"
+)
+
+const (
+	// dhtFrameBits is the number of data bits in a DHT frame: 16 humidity,
+	// 16 temperature and 8 checksum bits.
+	dhtFrameBits = 40
+
+	// dhtOneThreshold separates bit values by the length of their high pulse:
+	// about 27µs for a 0 and 70µs for a 1.
+	dhtOneThreshold = 50 * time.Microsecond
+)
+
+// ErrChecksum is returned when a DHT frame fails its checksum.
+var ErrChecksum = errors.New("DHT checksum mismatch")
+
+// DecodeDHT22 decodes a DHT22/AM2302 frame from the edges captured after the
+// start signal. The pulse widths are converted to bits, the checksum verified
+// and the values returned in %RH and degrees Celsius.
+//
+// The capture may include the host releasing the line and the sensor's 80µs
+// response pulses before the data; only the last 40 complete high pulses are
+// used as data bits.
+func DecodeDHT22(edges []gpiocdev.Edge) (humidity, celsius float64, err error) {
+	frame, err := decodeDHTFrame(edges)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	humidity = float64(uint16(frame[0])<<8|uint16(frame[1])) / 10
+	celsius = float64(uint16(frame[2]&0x7f)<<8|uint16(frame[3])) / 10
+	if frame[2]&0x80 != 0 {
+		celsius = -celsius
+	}
+
+	if humidity > 100 || celsius < -40 || celsius > 80 {
+		return 0, 0, fmt.Errorf("DHT22 values out of range: %.1f%%RH, %.1f°C", humidity, celsius)
+	}
+	return humidity, celsius, nil
+}
+
+// decodeDHTFrame converts edges into the 5 frame bytes and verifies the checksum.
+func decodeDHTFrame(edges []gpiocdev.Edge) ([5]byte, error) {
+	var frame [5]byte
+
+	// Measure every complete high pulse (rising edge followed by falling edge).
+	var highs []time.Duration
+	for i := 0; i+1 < len(edges); i++ {
+		if edges[i].Rising && !edges[i+1].Rising {
+			highs = append(highs, edges[i+1].Time-edges[i].Time)
+		}
+	}
+	if len(highs) < dhtFrameBits {
+		return frame, fmt.Errorf("incomplete DHT frame: got %d of %d bits", len(highs), dhtFrameBits)
+	}
+
+	bits := highs[len(highs)-dhtFrameBits:]
+	for i, width := range bits {
+		if width > dhtOneThreshold {
+			frame[i/8] |= 1 << (7 - uint(i%8))
+		}
+	}
+
+	if frame == ([5]byte{}) {
+		return frame, errors.New("DHT frame is all zeros, check wiring")
+	}
+	if sum := frame[0] + frame[1] + frame[2] + frame[3]; sum != frame[4] {
+		return frame, fmt.Errorf("%w: computed %#02x, received %#02x", ErrChecksum, sum, frame[4])
+	}
+	return frame, nil
+}
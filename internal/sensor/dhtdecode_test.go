@@ -0,0 +1,118 @@
+package sensor
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/gpiocdev"
+)
+
+// loadEdges reads a recorded edge fixture: one "<timestamp ns> <R|F>" event per line
+func loadEdges(t *testing.T, name string) []gpiocdev.Edge {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer f.Close()
+
+	var edges []gpiocdev.Edge
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		ns, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid timestamp in %s: %q", name, line)
+		}
+		edges = append(edges, gpiocdev.Edge{Time: time.Duration(ns), Rising: fields[1] == "R"})
+	}
+	return edges
+}
+
+func TestDecodeDHT22_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		humidity float64
+		celsius  float64
+	}{
+		{"dht22_65.2rh_23.4c.edges", 65.2, 23.4},
+		{"dht22_38.7rh_-12.5c.edges", 38.7, -12.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			humidity, celsius, err := DecodeDHT22(loadEdges(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("DecodeDHT22() returned unexpected error: %v", err)
+			}
+			if math.Abs(humidity-tt.humidity) > 1e-9 {
+				t.Errorf("humidity = %v, want %v", humidity, tt.humidity)
+			}
+			if math.Abs(celsius-tt.celsius) > 1e-9 {
+				t.Errorf("temperature = %v, want %v", celsius, tt.celsius)
+			}
+		})
+	}
+}
+
+func TestDecodeDHT22_BadChecksum(t *testing.T) {
+	_, _, err := DecodeDHT22(loadEdges(t, "dht22_bad_checksum.edges"))
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("DecodeDHT22() error = %v, want ErrChecksum", err)
+	}
+}
+
+func TestDecodeDHT22_Truncated(t *testing.T) {
+	// The truncated frame is missing its last 6 bits
+	if _, _, err := DecodeDHT22(loadEdges(t, "dht22_truncated.edges")); err == nil {
+		t.Error("DecodeDHT22() expected error for truncated frame, got nil")
+	}
+
+	edges := loadEdges(t, "dht22_65.2rh_23.4c.edges")
+	if _, _, err := DecodeDHT22(edges[:40]); err == nil {
+		t.Error("DecodeDHT22() expected error for 20 pulses, got nil")
+	}
+}
+
+func TestDecodeDHT22_NoEdges(t *testing.T) {
+	if _, _, err := DecodeDHT22(nil); err == nil {
+		t.Error("DecodeDHT22() expected error for no edges, got nil")
+	}
+}
+
+// Without the host release and response pulses the data bits must still decode
+func TestDecodeDHT22_DataOnly(t *testing.T) {
+	edges := loadEdges(t, "dht22_65.2rh_23.4c.edges")
+	humidity, celsius, err := DecodeDHT22(edges[3:])
+	if err != nil {
+		t.Fatalf("DecodeDHT22() returned unexpected error: %v", err)
+	}
+	if humidity != 65.2 || celsius != 23.4 {
+		t.Errorf("DecodeDHT22() = %v, %v; want 65.2, 23.4", humidity, celsius)
+	}
+}
+
+func TestDecodeDHT22_AllZeros(t *testing.T) {
+	var edges []gpiocdev.Edge
+	ts := time.Duration(0)
+	for i := 0; i < dhtFrameBits; i++ {
+		edges = append(edges, gpiocdev.Edge{Time: ts, Rising: true})
+		ts += 27 * time.Microsecond
+		edges = append(edges, gpiocdev.Edge{Time: ts, Rising: false})
+		ts += 50 * time.Microsecond
+	}
+	if _, _, err := DecodeDHT22(edges); err == nil {
+		t.Error("DecodeDHT22() expected error for all-zero frame, got nil")
+	}
+}
@@ -0,0 +1,225 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	// w1DevicesDir is where the kernel exposes 1-Wire slaves.
+	w1DevicesDir = "/sys/bus/w1/devices"
+
+	// ds18b20FamilyPrefix prefixes the ID of every DS18B20 probe.
+	ds18b20FamilyPrefix = "28-"
+
+	// ds18b20PowerOnValue is the scratchpad content after a power-on reset,
+	// read back when the probe lost power before converting.
+	ds18b20PowerOnValue = 85000
+)
+
+// ErrW1CRC is returned when the w1_slave scratchpad failed its CRC check.
+var ErrW1CRC = errors.New("1-Wire CRC check failed")
+
+// DS18B20Sensor implements the Reader interface for DS18B20 1-Wire
+// temperature probes read through the kernel w1_therm driver.
+// It has no humidity sensor.
+type DS18B20Sensor struct {
+	name       string
+	gpio       string
+	device     string
+	maxRetries int
+	logger     *log.Logger
+
+	mu sync.Mutex
+
+	// readFile is replaced in tests.
+	readFile func(string) ([]byte, error)
+}
+
+// NewDS18B20 creates a reader for the probe selected by w1_device, either a
+// probe ID such as "28-0316a2794fff" or the path of its sysfs directory.
+// Returns an error if the probe cannot be found.
+func NewDS18B20(cfg *config.SensorConfig, logger *log.Logger) (*DS18B20Sensor, error) {
+	return newDS18B20(cfg, logger, w1DevicesDir)
+}
+
+// newDS18B20 is NewDS18B20 with the 1-Wire devices directory as a parameter.
+func newDS18B20(cfg *config.SensorConfig, logger *log.Logger, devicesDir string) (*DS18B20Sensor, error) {
+	if cfg.W1Device == "" {
+		return nil, fmt.Errorf("sensor '%s' uses the ds18b20 backend but has no w1_device", cfg.Name)
+	}
+	device := cfg.W1Device
+	if !strings.Contains(device, "/") {
+		device = filepath.Join(devicesDir, device)
+	}
+	if _, err := os.Stat(filepath.Join(device, "w1_slave")); err != nil {
+		return nil, fmt.Errorf("failed to find 1-Wire probe for sensor '%s': %w", cfg.Name, err)
+	}
+
+	logger.WithFields(log.Fields{
+		"sensor": cfg.Name,
+		"gpio":   cfg.GPIO,
+		"device": device,
+	}).Info("Initializing DS18B20 1-Wire sensor")
+
+	return &DS18B20Sensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		device:     device,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		readFile:   os.ReadFile,
+	}, nil
+}
+
+// discoverDS18B20 returns one configuration per probe found in devicesDir,
+// sorted by probe ID. Probes are named by ID, prefixed with the entry's name if set.
+func discoverDS18B20(cfg config.SensorConfig, devicesDir string) ([]config.SensorConfig, error) {
+	matches, err := filepath.Glob(filepath.Join(devicesDir, ds18b20FamilyPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	sensors := make([]config.SensorConfig, 0, len(matches))
+	for _, device := range matches {
+		id := filepath.Base(device)
+		probe := cfg
+		probe.W1Device = id
+		probe.Name = id
+		if cfg.Name != "" {
+			probe.Name = cfg.Name + "-" + id
+		}
+		sensors = append(sensors, probe)
+	}
+	return sensors, nil
+}
+
+// ReadData reads the temperature, retrying up to max_retries times on CRC
+// errors. Humidity is always zero; see HasHumidity.
+// Returns an error if all attempts fail.
+func (s *DS18B20Sensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		temperature, err = s.read()
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"gpio":    s.gpio,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"gpio":   s.gpio,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return 0, 0, err
+	}
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"gpio":        s.gpio,
+		"temperature": temperature,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	return 0, temperature, nil
+}
+
+// read performs one conversion and returns the temperature in Celsius.
+// The temperature file of newer kernels is preferred over parsing w1_slave.
+func (s *DS18B20Sensor) read() (float64, error) {
+	var milli int64
+	data, err := s.readFile(filepath.Join(s.device, "temperature"))
+	switch {
+	case err == nil:
+		milli, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid temperature value: %w", err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		data, err = s.readFile(filepath.Join(s.device, "w1_slave"))
+		if err != nil {
+			return 0, err
+		}
+		milli, err = parseW1Slave(string(data))
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	if milli == ds18b20PowerOnValue {
+		return 0, fmt.Errorf("probe returned its power-on reset value")
+	}
+	return float64(milli) / 1000, nil
+}
+
+// parseW1Slave extracts the temperature in millidegrees Celsius from the
+// w1_slave output of the w1_therm driver:
+//
+//	72 01 4b 46 7f ff 0e 10 57 : crc=57 YES
+//	72 01 4b 46 7f ff 0e 10 57 t=23125
+func parseW1Slave(data string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected w1_slave content %q", data)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), "YES") {
+		return 0, ErrW1CRC
+	}
+	_, value, ok := strings.Cut(lines[1], "t=")
+	if !ok {
+		return 0, fmt.Errorf("no temperature in w1_slave line %q", lines[1])
+	}
+	milli, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid temperature value: %w", err)
+	}
+	return milli, nil
+}
+
+// HasHumidity returns false since DS18B20 probes only measure temperature.
+func (s *DS18B20Sensor) HasHumidity() bool {
+	return false
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *DS18B20Sensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *DS18B20Sensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *DS18B20Sensor) GPIO() string {
+	return s.gpio
+}
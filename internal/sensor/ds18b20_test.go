@@ -0,0 +1,236 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	w1SlaveOK       = "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+	w1SlaveNegative = "5e ff 4b 46 7f ff 02 10 23 : crc=23 YES\n5e ff 4b 46 7f ff 02 10 23 t=-10125\n"
+	w1SlaveBadCRC   = "72 01 4b 46 7f ff 0e 10 57 : crc=3c NO\n72 01 4b 46 7f ff 0e 10 57 t=23125\n"
+)
+
+// writeW1Probe creates a fake sysfs 1-Wire probe directory with the given files
+func writeW1Probe(t *testing.T, root, id string, files map[string]string) string {
+	t.Helper()
+	device := filepath.Join(root, id)
+	if err := os.MkdirAll(device, 0o755); err != nil {
+		t.Fatalf("Failed to create probe directory: %v", err)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(device, file), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+	return device
+}
+
+func newTestDS18B20(t *testing.T, root, device, unit string) *DS18B20Sensor {
+	t.Helper()
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	s, err := newDS18B20(&config.SensorConfig{
+		Name:            "tank",
+		GPIO:            "GPIO4",
+		MaxRetries:      3,
+		TemperatureUnit: unit,
+		W1Device:        device,
+	}, logger, root)
+	if err != nil {
+		t.Fatalf("newDS18B20() returned unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestDS18B20Sensor_ImplementsReader(t *testing.T) {
+	var _ Reader = (*DS18B20Sensor)(nil)
+	var _ HumidityReporter = (*DS18B20Sensor)(nil)
+}
+
+func TestParseW1Slave(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int64
+		wantErr bool
+	}{
+		{"positive", w1SlaveOK, 23125, false},
+		{"negative", w1SlaveNegative, -10125, false},
+		{"bad crc", w1SlaveBadCRC, 0, true},
+		{"truncated", "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n", 0, true},
+		{"no temperature", "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n72 01 4b 46 7f ff 0e 10 57\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseW1Slave(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseW1Slave() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseW1Slave() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDS18B20Sensor_ReadW1Slave(t *testing.T) {
+	root := t.TempDir()
+	writeW1Probe(t, root, "28-0316a2794fff", map[string]string{"w1_slave": w1SlaveOK})
+	s := newTestDS18B20(t, root, "28-0316a2794fff", "celsius")
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != 23.125 || humidity != 0 {
+		t.Errorf("ReadData() = %v, %v; want 0, 23.125", humidity, temperature)
+	}
+	if HasHumidity(s) {
+		t.Error("HasHumidity() = true, want false")
+	}
+}
+
+// Newer kernels expose a temperature file, which is used instead of w1_slave
+func TestDS18B20Sensor_ReadTemperatureFile(t *testing.T) {
+	root := t.TempDir()
+	device := writeW1Probe(t, root, "28-0316a2794fff", map[string]string{
+		"w1_slave":    w1SlaveBadCRC,
+		"temperature": "-12500\n",
+	})
+	s := newTestDS18B20(t, root, device, "fahrenheit")
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != -12.5 {
+		t.Errorf("temperature = %v, want -12.5", temperature)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+func TestDS18B20Sensor_RetryOnCRCError(t *testing.T) {
+	root := t.TempDir()
+	writeW1Probe(t, root, "28-0316a2794fff", map[string]string{"w1_slave": w1SlaveOK})
+	s := newTestDS18B20(t, root, "28-0316a2794fff", "celsius")
+
+	contents := []string{w1SlaveBadCRC, w1SlaveBadCRC}
+	s.readFile = func(path string) ([]byte, error) {
+		if filepath.Base(path) == "w1_slave" && len(contents) > 0 {
+			data := contents[0]
+			contents = contents[1:]
+			return []byte(data), nil
+		}
+		return os.ReadFile(path)
+	}
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != 23.125 {
+		t.Errorf("temperature = %v, want 23.125", temperature)
+	}
+}
+
+func TestDS18B20Sensor_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		w1Slave string
+		wantErr error
+	}{
+		{"bad crc", w1SlaveBadCRC, ErrW1CRC},
+		{"power-on reset value", "50 05 4b 46 7f ff 0c 10 1c : crc=1c YES\n50 05 4b 46 7f ff 0c 10 1c t=85000\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeW1Probe(t, root, "28-0316a2794fff", map[string]string{"w1_slave": tt.w1Slave})
+			s := newTestDS18B20(t, root, "28-0316a2794fff", "celsius")
+
+			_, _, err := s.ReadData(context.Background())
+			if err == nil {
+				t.Fatal("ReadData() expected error, got nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadData() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDS18B20_Errors(t *testing.T) {
+	root := t.TempDir()
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	for _, device := range []string{"", "28-000000000000"} {
+		_, err := newDS18B20(&config.SensorConfig{Name: "tank", W1Device: device}, logger, root)
+		if err == nil {
+			t.Errorf("newDS18B20(%q) expected error, got nil", device)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeW1Probe(t, root, "28-0316a2794fff", map[string]string{"w1_slave": w1SlaveOK})
+	writeW1Probe(t, root, "28-01193a5c2a11", map[string]string{"w1_slave": w1SlaveOK})
+	writeW1Probe(t, root, "w1_bus_master1", nil)
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	sensors, err := discover([]config.SensorConfig{
+		{Name: "living-room", GPIO: "GPIO17"},
+		{Name: "tank", GPIO: "GPIO4", Backend: BackendDS18B20},
+		{Backend: BackendDS18B20},
+		{Name: "fixed", Backend: BackendDS18B20, W1Device: "28-0316a2794fff"},
+	}, root, logger)
+	if err != nil {
+		t.Fatalf("discover() returned unexpected error: %v", err)
+	}
+
+	want := []struct{ name, device string }{
+		{"living-room", ""},
+		{"tank-28-01193a5c2a11", "28-01193a5c2a11"},
+		{"tank-28-0316a2794fff", "28-0316a2794fff"},
+		{"28-01193a5c2a11", "28-01193a5c2a11"},
+		{"28-0316a2794fff", "28-0316a2794fff"},
+		{"fixed", "28-0316a2794fff"},
+	}
+	if len(sensors) != len(want) {
+		t.Fatalf("discover() returned %d sensors, want %d", len(sensors), len(want))
+	}
+	for i, w := range want {
+		if sensors[i].Name != w.name || sensors[i].W1Device != w.device {
+			t.Errorf("sensors[%d] = %q on %q, want %q on %q", i, sensors[i].Name, sensors[i].W1Device, w.name, w.device)
+		}
+	}
+	if sensors[1].GPIO != "GPIO4" {
+		t.Errorf("discovered sensor GPIO = %q, want GPIO4", sensors[1].GPIO)
+	}
+}
+
+func TestDiscover_NoProbes(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	_, err := discover([]config.SensorConfig{{Name: "tank", Backend: BackendDS18B20}}, t.TempDir(), logger)
+	if err == nil {
+		t.Error("discover() expected error when no probes are found, got nil")
+	}
+}
@@ -0,0 +1,335 @@
+package sensor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	// execDefaultPath is the PATH of exec commands when exec_env does not set one.
+	execDefaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	// execMaxOutput bounds the output kept from a command.
+	execMaxOutput = 64 << 10
+
+	// execWaitDelay is how long a timed out command may keep its output open after being killed.
+	execWaitDelay = time.Second
+)
+
+// execSlots bounds the number of exec sensor commands running at once.
+var execSlots = make(chan struct{}, config.DefaultExecMaxConcurrent)
+
+// SetExecConcurrency sets how many exec sensor commands may run at once.
+// It applies to sensors opened afterwards and must be called before opening them.
+func SetExecConcurrency(n int) {
+	execSlots = make(chan struct{}, max(n, 1))
+}
+
+// ExecSensor implements the MultiReader interface for sensors read by an
+// external command. The command prints the reading on stdout, either as a
+// JSON object or as key=value lines, in the configured temperature unit:
+//
+//	{"temperature": 21.5, "humidity": 40.2}
+//
+//	temperature=21.5
+//	humidity=40.2
+//
+// A non-zero exit status, a timeout or an output without temperature fails the read.
+type ExecSensor struct {
+	name              string
+	gpio              string
+	command           []string
+	timeout           time.Duration
+	env               []string
+	dir               string
+	maxRetries        int
+	temperatureSymbol string
+	quantities        []Quantity
+	logger            *log.Logger
+
+	mu    sync.Mutex
+	slots chan struct{}
+}
+
+// NewExec creates a sensor reading the output of exec_command.
+// The command is run once to learn the quantities it reports.
+// Returns an error if that first run fails.
+func NewExec(cfg *config.SensorConfig, logger *log.Logger) (*ExecSensor, error) {
+	logger.WithFields(log.Fields{
+		"sensor":  cfg.Name,
+		"command": cfg.ExecCommand,
+	}).Info("Initializing exec sensor")
+
+	if len(cfg.ExecCommand) == 0 {
+		return nil, fmt.Errorf("no exec_command for sensor '%s'", cfg.Name)
+	}
+
+	// The command prints temperatures in the sensor's temperature_unit
+	temperatureSymbol := TemperatureSymbol(cfg.TemperatureUnit)
+
+	timeout := cfg.ExecTimeout
+	if timeout <= 0 {
+		timeout = config.DefaultExecTimeout
+	}
+
+	s := &ExecSensor{
+		name:              cfg.Name,
+		gpio:              cfg.GPIO,
+		command:           cfg.ExecCommand,
+		timeout:           timeout,
+		env:               execEnv(cfg.ExecEnv),
+		dir:               cfg.ExecDir,
+		maxRetries:        cfg.MaxRetries,
+		temperatureSymbol: temperatureSymbol,
+		logger:            logger,
+		slots:             execSlots,
+	}
+
+	values, err := s.run(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to run exec_command for sensor '%s': %w", cfg.Name, err)
+	}
+	for _, m := range s.reading(values, time.Time{}).Measurements {
+		s.quantities = append(s.quantities, m.Quantity)
+	}
+	return s, nil
+}
+
+// execEnv returns the environment of exec commands: the configured
+// KEY=value entries, with a default PATH.
+func execEnv(vars []string) []string {
+	for _, kv := range vars {
+		if strings.HasPrefix(kv, "PATH=") {
+			return vars
+		}
+	}
+	return append([]string{"PATH=" + execDefaultPath}, vars...)
+}
+
+// limitedBuffer is a bytes.Buffer discarding writes past execMaxOutput.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := execMaxOutput - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// run runs the command once and parses its output. The command is killed
+// after exec_timeout or once ctx is done, whichever comes first.
+func (s *ExecSensor) run(ctx context.Context) (map[string]float64, error) {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.slots }()
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var stdout, stderr limitedBuffer
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Env = s.env
+	cmd.Dir = s.dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = execWaitDelay
+
+	err := cmd.Run()
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("command timed out after %s", s.timeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+			if msg != "" {
+				return nil, fmt.Errorf("command exited with status %d: %s", exitErr.ExitCode(), msg)
+			}
+			return nil, fmt.Errorf("command exited with status %d", exitErr.ExitCode())
+		}
+		return nil, err
+	}
+	return parseExecOutput(stdout.Bytes())
+}
+
+// parseExecOutput parses a JSON object or key=value lines of numbers.
+// Blank lines and lines starting with # are ignored in key=value output.
+func parseExecOutput(out []byte) (map[string]float64, error) {
+	values := make(map[string]float64)
+	if trimmed := bytes.TrimSpace(out); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return nil, fmt.Errorf("invalid JSON output: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid output line %d: want key=value", n)
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value on output line %d: %w", n, err)
+			}
+			values[strings.TrimSpace(key)] = v
+		}
+	}
+
+	for k, v := range values {
+		if k == "" {
+			return nil, errors.New("empty quantity name in output")
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid %s value in output", k)
+		}
+	}
+	if _, ok := values[QuantityTemperature]; !ok {
+		return nil, errors.New("output has no temperature")
+	}
+	return values, nil
+}
+
+// reading converts parsed values to a reading. Temperature and humidity come
+// first, other quantities follow by name.
+func (s *ExecSensor) reading(values map[string]float64, t time.Time) Reading {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if name != QuantityTemperature && name != QuantityHumidity {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := values[QuantityHumidity]; ok {
+		names = append([]string{QuantityHumidity}, names...)
+	}
+	names = append([]string{QuantityTemperature}, names...)
+
+	reading := Reading{Time: t, Measurements: make([]Measurement, 0, len(names))}
+	for _, name := range names {
+		unit := ""
+		switch name {
+		case QuantityTemperature:
+			unit = s.temperatureSymbol
+		case QuantityHumidity:
+			unit = UnitPercent
+		case QuantityPressure:
+			unit = UnitPascal
+		}
+		reading.Measurements = append(reading.Measurements, Measurement{
+			Quantity: Quantity{Name: name, Unit: unit},
+			Value:    values[name],
+		})
+	}
+	return reading
+}
+
+// Read runs the command, retrying up to max_retries times.
+// Returns an error if all attempts fail.
+func (s *ExecSensor) Read(ctx context.Context) (Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var values map[string]float64
+	var err error
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		values, err = s.run(ctx)
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return Reading{}, err
+	}
+
+	reading := s.reading(values, time.Now())
+	s.logger.WithFields(log.Fields{
+		"sensor": s.name,
+		"values": values,
+		"unit":   s.temperatureSymbol,
+	}).Info("Sensor data retrieved")
+	return reading, nil
+}
+
+// ReadData runs the command and returns its humidity and temperature.
+func (s *ExecSensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	reading, err := s.Read(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	temperature, _ = reading.Value(QuantityTemperature)
+	humidity, _ = reading.Value(QuantityHumidity)
+	return humidity, temperature, nil
+}
+
+// Quantities returns the quantities reported by the first run of the command.
+func (s *ExecSensor) Quantities() []Quantity {
+	return s.quantities
+}
+
+// HasHumidity reports whether the first run of the command reported humidity.
+func (s *ExecSensor) HasHumidity() bool {
+	for _, q := range s.quantities {
+		if q.Name == QuantityHumidity {
+			return true
+		}
+	}
+	return false
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *ExecSensor) TemperatureUnit() string {
+	return s.temperatureSymbol
+}
+
+// Name returns the sensor name.
+func (s *ExecSensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *ExecSensor) GPIO() string {
+	return s.gpio
+}
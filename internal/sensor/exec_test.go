@@ -0,0 +1,249 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// testExecConfig returns an exec sensor configuration running script with sh.
+func testExecConfig(script string) *config.SensorConfig {
+	return &config.SensorConfig{
+		Name:            "plugin",
+		GPIO:            "GPIO0",
+		MaxRetries:      1,
+		TemperatureUnit: "celsius",
+		Backend:         BackendExec,
+		ExecCommand:     []string{"/bin/sh", "-c", script},
+		ExecTimeout:     5 * time.Second,
+	}
+}
+
+func TestParseExecOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{"json", `{"temperature": 21.5, "humidity": 40.2}`, map[string]float64{"temperature": 21.5, "humidity": 40.2}, false},
+		{"key value", "# probe 1\ntemperature=21.5\n\nhumidity = 40.2\nco2=612\n", map[string]float64{"temperature": 21.5, "humidity": 40.2, "co2": 612}, false},
+		{"temperature only", "temperature=-3\n", map[string]float64{"temperature": -3}, false},
+		{"empty", "", nil, true},
+		{"no temperature", "humidity=40\n", nil, true},
+		{"not key value", "21.5\n", nil, true},
+		{"not a number", "temperature=warm\n", nil, true},
+		{"not finite", "temperature=NaN\n", nil, true},
+		{"invalid json", `{"temperature": "21.5"}`, nil, true},
+		{"empty key", "temperature=1\n=2\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExecOutput([]byte(tt.output))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseExecOutput() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExecOutput() returned unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseExecOutput() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("parseExecOutput()[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestExec_Read(t *testing.T) {
+	s, err := NewExec(testExecConfig(`echo '{"pressure": 101325, "temperature": 21.5, "humidity": 40.2}'`), getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewExec() returned unexpected error: %v", err)
+	}
+
+	want := []Quantity{
+		{Name: QuantityTemperature, Unit: CelsiusSymbol},
+		{Name: QuantityHumidity, Unit: UnitPercent},
+		{Name: QuantityPressure, Unit: UnitPascal},
+	}
+	quantities := s.Quantities()
+	if len(quantities) != len(want) {
+		t.Fatalf("Quantities() = %v, want %v", quantities, want)
+	}
+	for i := range want {
+		if quantities[i] != want[i] {
+			t.Errorf("Quantities()[%d] = %v, want %v", i, quantities[i], want[i])
+		}
+	}
+	if !s.HasHumidity() {
+		t.Error("HasHumidity() = false, want true")
+	}
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 40.2 || temperature != 21.5 {
+		t.Errorf("ReadData() = (%v, %v), want (40.2, 21.5)", humidity, temperature)
+	}
+}
+
+func TestExec_TemperatureOnly(t *testing.T) {
+	cfg := testExecConfig("echo temperature=70.7")
+	cfg.TemperatureUnit = "fahrenheit"
+	s, err := NewExec(cfg, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewExec() returned unexpected error: %v", err)
+	}
+	if s.HasHumidity() {
+		t.Error("HasHumidity() = true, want false")
+	}
+	if unit := s.Quantities()[0].Unit; unit != FahrenheitSymbol {
+		t.Errorf("temperature unit = %q, want %q", unit, FahrenheitSymbol)
+	}
+}
+
+func TestExec_ExitStatus(t *testing.T) {
+	_, err := NewExec(testExecConfig("echo sensor not found >&2; echo details >&2; exit 3"), getSilentLogger())
+	if err == nil {
+		t.Fatal("NewExec() expected error for non-zero exit status, got nil")
+	}
+	if !strings.Contains(err.Error(), "status 3") || !strings.Contains(err.Error(), "sensor not found") || strings.Contains(err.Error(), "details") {
+		t.Errorf("error = %q, want exit status and first stderr line", err)
+	}
+}
+
+func TestExec_ReadFailure(t *testing.T) {
+	// The command succeeds once, during initialization, then fails
+	marker := filepath.Join(t.TempDir(), "ran")
+	cfg := testExecConfig(`if [ -e "$MARKER" ]; then exit 1; fi; touch "$MARKER"; echo temperature=20`)
+	cfg.MaxRetries = 3
+	cfg.ExecEnv = []string{"MARKER=" + marker}
+	s, err := NewExec(cfg, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewExec() returned unexpected error: %v", err)
+	}
+	if _, err := s.Read(context.Background()); err == nil {
+		t.Error("Read() expected error after the command started failing, got nil")
+	}
+}
+
+func TestExec_Timeout(t *testing.T) {
+	cfg := testExecConfig("sleep 5; echo temperature=20")
+	cfg.ExecTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := NewExec(cfg, getSilentLogger())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("NewExec() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("NewExec() took %v, want the command killed after its timeout", elapsed)
+	}
+}
+
+func TestExec_Cancelled(t *testing.T) {
+	// The command succeeds quickly once, during initialization, then hangs
+	marker := filepath.Join(t.TempDir(), "ran")
+	cfg := testExecConfig(`if [ -e "$MARKER" ]; then sleep 5; fi; touch "$MARKER"; echo temperature=20`)
+	cfg.MaxRetries = 3
+	cfg.ExecEnv = []string{"MARKER=" + marker}
+	s, err := NewExec(cfg, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewExec() returned unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.Read(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Read() error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Read() took %v, want the command killed and no retry after the deadline", elapsed)
+	}
+}
+
+func TestExec_EnvAndDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "value"), []byte("temperature=19\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DHT_TEST_SECRET", "leaked")
+
+	cfg := testExecConfig(`cat value; echo "humidity=$HUMIDITY"; [ -z "$DHT_TEST_SECRET" ] || echo leaked=1`)
+	cfg.ExecDir = dir
+	cfg.ExecEnv = []string{"HUMIDITY=55"}
+	s, err := NewExec(cfg, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewExec() returned unexpected error: %v", err)
+	}
+
+	reading, err := s.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	if v, _ := reading.Value(QuantityTemperature); v != 19 {
+		t.Errorf("temperature = %v, want 19 from the working directory", v)
+	}
+	if v, _ := reading.Value(QuantityHumidity); v != 55 {
+		t.Errorf("humidity = %v, want 55 from exec_env", v)
+	}
+	if _, ok := reading.Value("leaked"); ok {
+		t.Error("command inherited the exporter environment")
+	}
+}
+
+func TestExec_Concurrency(t *testing.T) {
+	// Each command marks itself as running in dir and reports how many are
+	dir := t.TempDir()
+	script := `mkdir "$RUNNING/$$"; sleep 0.05; echo "running=$(ls "$RUNNING" | wc -l)"; rmdir "$RUNNING/$$"; echo temperature=20`
+	slots := make(chan struct{}, 2)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	peak := 0.0
+	for range 6 {
+		s := &ExecSensor{
+			name:       "plugin",
+			command:    []string{"/bin/sh", "-c", script},
+			timeout:    5 * time.Second,
+			env:        execEnv([]string{"RUNNING=" + dir}),
+			maxRetries: 1,
+			logger:     getSilentLogger(),
+			slots:      slots,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reading, err := s.Read(context.Background())
+			if err != nil {
+				t.Errorf("Read() returned unexpected error: %v", err)
+				return
+			}
+			running, _ := reading.Value("running")
+			mu.Lock()
+			peak = max(peak, running)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak < 1 || peak > 2 {
+		t.Errorf("peak concurrent commands = %v, want 1 to 2", peak)
+	}
+}
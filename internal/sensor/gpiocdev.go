@@ -0,0 +1,168 @@
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/gpiocdev"
+)
+
+const (
+	// dhtStartSignal is how long the host holds the line low to request a reading.
+	dhtStartSignal = 1100 * time.Microsecond
+
+	// dhtMinInterval is the minimum time between two reads of a DHT22.
+	dhtMinInterval = 2 * time.Second
+
+	// dhtMaxEdges bounds the capture; a frame has about 85 edges.
+	dhtMaxEdges = 100
+
+	// dhtEdgeIdle ends the capture once the line has been quiet this long.
+	dhtEdgeIdle = 2 * time.Millisecond
+
+	// dhtCaptureTimeout bounds the whole capture; a frame lasts about 5ms.
+	dhtCaptureTimeout = 20 * time.Millisecond
+
+	// gpioConsumer is the consumer label shown by gpioinfo for requested lines.
+	gpioConsumer = "dht-prometheus-exporter"
+)
+
+// CdevSensor implements the Reader interface for DHT22/AM2302 sensors using
+// the Linux GPIO character device (v2 uAPI) and an in-tree protocol decoder.
+// Edges are timestamped by the kernel, which keeps decoding reliable on chips
+// such as the Raspberry Pi 5's RP1 where user space bit-banging is too slow.
+type CdevSensor struct {
+	name       string
+	gpio       string
+	chip       string
+	offset     int
+	maxRetries int
+	logger     *log.Logger
+
+	mu        sync.Mutex
+	scheduler *Scheduler
+
+	// capture and sleep are replaced in tests.
+	capture func() ([]gpiocdev.Edge, error)
+	sleep   func(time.Duration)
+}
+
+// NewCdev creates a DHT22 reader on the GPIO character device.
+// The line is only requested while reading, so no hardware is accessed here.
+func NewCdev(cfg *config.SensorConfig, logger *log.Logger) (*CdevSensor, error) {
+	chip := cfg.GPIOChip
+	if chip == "" {
+		chip = gpiocdev.DefaultChip
+	}
+	if cfg.Pin < 0 {
+		return nil, fmt.Errorf("invalid GPIO pin %d for sensor '%s'", cfg.Pin, cfg.Name)
+	}
+
+	logger.WithFields(log.Fields{
+		"sensor": cfg.Name,
+		"gpio":   cfg.GPIO,
+		"chip":   chip,
+	}).Info("Initializing DHT22/AM2302 sensor on GPIO character device")
+
+	s := &CdevSensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		chip:       chip,
+		offset:     cfg.Pin,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		scheduler:  DefaultScheduler,
+		sleep:      time.Sleep,
+	}
+	s.capture = s.captureFrame
+	return s, nil
+}
+
+// captureFrame sends the start signal and records the sensor's response edges.
+func (s *CdevSensor) captureFrame() ([]gpiocdev.Edge, error) {
+	line, err := gpiocdev.RequestOutput(s.chip, s.offset, gpioConsumer, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = line.Close() }()
+
+	s.sleep(dhtStartSignal)
+	if err := line.WatchEdges(); err != nil {
+		return nil, err
+	}
+	return line.ReadEdges(dhtMaxEdges, dhtEdgeIdle, dhtCaptureTimeout)
+}
+
+// ReadData reads humidity and temperature, retrying up to max_retries times.
+// Captures go through the scheduler, which enforces the sensor's minimum
+// interval between reads.
+// Returns an error if all attempts fail.
+func (s *CdevSensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var edges []gpiocdev.Edge
+		err = s.scheduler.Do(ctx, s.gpio, func() error {
+			var err error
+			edges, err = s.capture()
+			return err
+		})
+		if err == nil {
+			humidity, temperature, err = DecodeDHT22(edges)
+		}
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"gpio":    s.gpio,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"gpio":   s.gpio,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return 0, 0, err
+	}
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"gpio":        s.gpio,
+		"humidity":    humidity,
+		"temperature": temperature,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	return humidity, temperature, nil
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *CdevSensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *CdevSensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *CdevSensor) GPIO() string {
+	return s.gpio
+}
@@ -0,0 +1,145 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/gpiocdev"
+)
+
+// newTestCdev returns a CdevSensor whose captures come from frames and whose sleeps are recorded
+func newTestCdev(t *testing.T, unit string, frames ...[]gpiocdev.Edge) (*CdevSensor, *[]time.Duration) {
+	t.Helper()
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	s, err := NewCdev(&config.SensorConfig{
+		Name:            "test-sensor",
+		GPIO:            "GPIO4",
+		Pin:             4,
+		MaxRetries:      3,
+		TemperatureUnit: unit,
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewCdev() returned unexpected error: %v", err)
+	}
+
+	var sleeps []time.Duration
+	s.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	s.scheduler = NewScheduler(dhtMinInterval)
+	s.scheduler.sleep = func(_ context.Context, d time.Duration) error {
+		s.sleep(d)
+		return nil
+	}
+	s.capture = func() ([]gpiocdev.Edge, error) {
+		if len(frames) == 0 {
+			return nil, errors.New("no more frames")
+		}
+		frame := frames[0]
+		frames = frames[1:]
+		return frame, nil
+	}
+	return s, &sleeps
+}
+
+func TestCdevSensor_ImplementsReader(t *testing.T) {
+	var _ Reader = (*CdevSensor)(nil)
+}
+
+func TestNewCdev_Defaults(t *testing.T) {
+	s, _ := newTestCdev(t, "celsius")
+	if s.chip != gpiocdev.DefaultChip {
+		t.Errorf("chip = %q, want %q", s.chip, gpiocdev.DefaultChip)
+	}
+	if s.offset != 4 {
+		t.Errorf("offset = %d, want 4", s.offset)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+func TestCdevSensor_ReadData(t *testing.T) {
+	s, _ := newTestCdev(t, "celsius", loadEdges(t, "dht22_65.2rh_23.4c.edges"))
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 65.2 || temperature != 23.4 {
+		t.Errorf("ReadData() = %v, %v; want 65.2, 23.4", humidity, temperature)
+	}
+}
+
+// Sensors read in Celsius whatever their temperature_unit, which the exporter applies
+func TestCdevSensor_ReadsCelsius(t *testing.T) {
+	s, _ := newTestCdev(t, "fahrenheit", loadEdges(t, "dht22_38.7rh_-12.5c.edges"))
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != -12.5 {
+		t.Errorf("temperature = %v, want -12.5", temperature)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+// A bad frame is retried after the DHT minimum read interval
+func TestCdevSensor_Retry(t *testing.T) {
+	s, sleeps := newTestCdev(t, "celsius",
+		loadEdges(t, "dht22_bad_checksum.edges"),
+		loadEdges(t, "dht22_65.2rh_23.4c.edges"),
+	)
+
+	humidity, _, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 65.2 {
+		t.Errorf("humidity = %v, want 65.2", humidity)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] <= time.Second {
+		t.Errorf("sleeps = %v, want one wait of about %v", *sleeps, dhtMinInterval)
+	}
+}
+
+func TestCdevSensor_AllAttemptsFail(t *testing.T) {
+	bad := loadEdges(t, "dht22_bad_checksum.edges")
+	s, _ := newTestCdev(t, "celsius", bad, bad, bad, loadEdges(t, "dht22_65.2rh_23.4c.edges"))
+
+	if _, _, err := s.ReadData(context.Background()); !errors.Is(err, ErrChecksum) {
+		t.Errorf("ReadData() error = %v, want ErrChecksum after max_retries attempts", err)
+	}
+}
+
+func TestOpen_UnknownBackend(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	_, err := Open(&config.SensorConfig{Name: "test", Backend: "bitbang"}, logger)
+	if err == nil {
+		t.Error("Open() expected error for unknown backend, got nil")
+	}
+}
+
+func TestOpen_GPIOCdev(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	r, err := Open(&config.SensorConfig{Name: "test", GPIO: "GPIO4", Pin: 4, Backend: BackendGPIOCdev}, logger)
+	if err != nil {
+		t.Fatalf("Open() returned unexpected error: %v", err)
+	}
+	if _, ok := r.(*CdevSensor); !ok {
+		t.Errorf("Open() returned %T, want *CdevSensor", r)
+	}
+}
@@ -0,0 +1,292 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// Health states of a sensor.
+const (
+	// StateHealthy is the state of a sensor whose last read succeeded.
+	StateHealthy = "healthy"
+	// StateDegraded is the state of a sensor failing some consecutive reads.
+	StateDegraded = "degraded"
+	// StateFailed is the state of a sensor failing too many consecutive reads.
+	// It is not read until its backoff has elapsed.
+	StateFailed = "failed"
+	// StateProbing is the state of a failed sensor whose backoff has elapsed.
+	// The next read probes it for recovery.
+	StateProbing = "probing"
+)
+
+// States lists every health state.
+var States = []string{StateHealthy, StateDegraded, StateFailed, StateProbing}
+
+// ErrBackoff is returned, without reading the sensor, while a failed sensor backs off.
+var ErrBackoff = errors.New("sensor failed, waiting before the next probe")
+
+// StateReporter is implemented by readers tracking the health of their sensor.
+type StateReporter interface {
+	// State returns one of States.
+	State() string
+}
+
+// Health wraps a Reader with a health state machine. Once the sensor fails
+// too many consecutive reads it is only read by probes, spaced by an
+// exponential backoff, so that a disconnected sensor is not retried on every
+// scrape; a successful probe makes it healthy again.
+// With a power switch, the sensor is also power cycled after consecutive failures.
+// Health implements MultiReader, StateReporter and PowerCycleReporter.
+type Health struct {
+	reader MultiReader
+	raw    Reader
+	cfg    config.HealthConfig
+	logger *log.Logger
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	backoff   time.Duration
+	nextProbe time.Time
+	// probing is set while a probe read runs, so that concurrent reads do not probe too.
+	probing bool
+
+	power         PowerSwitch
+	powerCfg      config.PowerConfig
+	cycleFailures int
+	powerCycles   uint64
+	// powered is closed while the sensor is powered, and replaced during a power cycle.
+	powered chan struct{}
+
+	// timeNow and sleep are replaced in tests.
+	timeNow func() time.Time
+	sleep   func(time.Duration)
+}
+
+// NewHealth wraps r with the health thresholds of cfg.
+// Zero thresholds take their default value.
+func NewHealth(r Reader, cfg config.HealthConfig, logger *log.Logger) *Health {
+	if cfg.DegradedAfter <= 0 {
+		cfg.DegradedAfter = config.DefaultHealthDegradedAfter
+	}
+	if cfg.FailedAfter < cfg.DegradedAfter {
+		cfg.FailedAfter = max(config.DefaultHealthFailedAfter, cfg.DegradedAfter)
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = config.DefaultHealthBackoff
+	}
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = config.DefaultHealthBackoffMax
+	}
+	cfg.BackoffMax = max(cfg.BackoffMax, cfg.Backoff)
+
+	powered := make(chan struct{})
+	close(powered)
+	return &Health{
+		reader:  Extend(r),
+		raw:     r,
+		cfg:     cfg,
+		logger:  logger,
+		state:   StateHealthy,
+		powered: powered,
+		timeNow: time.Now,
+		sleep:   time.Sleep,
+	}
+}
+
+// SetPowerSwitch makes the sensor power cycled through p after cfg.CycleAfter
+// consecutive failed reads. It must be called before the first read.
+func (h *Health) SetPowerSwitch(p PowerSwitch, cfg config.PowerConfig) {
+	cfg.CycleAfter = max(cfg.CycleAfter, 1)
+	h.power = p
+	h.powerCfg = cfg
+}
+
+// Read reads the sensor unless it is failed and backing off, in which case
+// ErrBackoff is returned. During a power cycle, Read waits for the sensor to
+// be powered again. Reads abandoned because ctx is done do not count as failures.
+func (h *Health) Read(ctx context.Context) (Reading, error) {
+	h.mu.Lock()
+	powered := h.powered
+	h.mu.Unlock()
+	select {
+	case <-powered:
+	case <-ctx.Done():
+		return Reading{}, ctx.Err()
+	}
+
+	h.mu.Lock()
+	if h.state == StateFailed && !h.timeNow().Before(h.nextProbe) {
+		h.transition(StateProbing)
+	}
+	probe := h.state == StateProbing
+	if h.state == StateFailed || probe && h.probing {
+		next := h.nextProbe
+		h.mu.Unlock()
+		return Reading{}, fmt.Errorf("%w (next probe at %s)", ErrBackoff, next.Format(time.RFC3339))
+	}
+	h.probing = probe
+	h.mu.Unlock()
+
+	reading, err := h.reader.Read(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.probing = false
+	if err != nil && ctx.Err() != nil {
+		return reading, err
+	}
+	h.record(err)
+	return reading, err
+}
+
+// record updates the state with the outcome of a read.
+func (h *Health) record(err error) {
+	if err == nil {
+		h.failures = 0
+		h.cycleFailures = 0
+		h.backoff = 0
+		h.transition(StateHealthy)
+		return
+	}
+
+	h.failures++
+	if h.power != nil {
+		h.cycleFailures++
+		if h.cycleFailures >= h.powerCfg.CycleAfter {
+			h.cycleFailures = 0
+			h.powerCycles++
+			h.powered = make(chan struct{})
+			go h.powerCycle(h.powered)
+		}
+	}
+	switch {
+	case h.state == StateProbing:
+		h.backoff = min(2*h.backoff, h.cfg.BackoffMax)
+		h.nextProbe = h.timeNow().Add(h.backoff)
+		h.transition(StateFailed)
+	case h.failures >= h.cfg.FailedAfter:
+		h.backoff = h.cfg.Backoff
+		h.nextProbe = h.timeNow().Add(h.backoff)
+		h.transition(StateFailed)
+	case h.failures >= h.cfg.DegradedAfter:
+		h.transition(StateDegraded)
+	}
+}
+
+// powerCycle switches the sensor off for the off time, then on, and closes
+// powered once it has warmed up. A failed sensor is probed right after.
+func (h *Health) powerCycle(powered chan struct{}) {
+	entry := h.logger.WithFields(log.Fields{
+		"sensor": h.raw.Name(),
+		"gpio":   h.raw.GPIO(),
+		"power":  fmt.Sprintf("GPIO%d", h.powerCfg.GPIO),
+	})
+	entry.WithField("off_time", h.powerCfg.OffTime).Warn("Power cycling sensor")
+
+	if err := h.power.SetPower(false); err != nil {
+		entry.WithError(err).Error("Failed to power off sensor")
+	} else {
+		h.sleep(h.powerCfg.OffTime)
+	}
+	// Always try to restore power, even if switching it off failed
+	if err := h.power.SetPower(true); err != nil {
+		entry.WithError(err).Error("Failed to power on sensor")
+	}
+	h.sleep(h.powerCfg.WarmUp)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state == StateFailed {
+		h.nextProbe = h.timeNow()
+	}
+	close(powered)
+	entry.Info("Sensor power restored")
+}
+
+// PowerCycles returns the number of power cycles so far, and false if the
+// sensor has no power switch.
+func (h *Health) PowerCycles() (uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.powerCycles, h.power != nil
+}
+
+// transition moves to state, logging the change.
+func (h *Health) transition(state string) {
+	if state == h.state {
+		return
+	}
+	entry := h.logger.WithFields(log.Fields{
+		"sensor":   h.raw.Name(),
+		"gpio":     h.raw.GPIO(),
+		"from":     h.state,
+		"to":       state,
+		"failures": h.failures,
+	})
+	h.state = state
+
+	switch state {
+	case StateFailed:
+		entry.WithField("next_probe", h.nextProbe).Warn("Sensor failed, backing off")
+	case StateDegraded:
+		entry.Warn("Sensor degraded")
+	case StateProbing:
+		entry.Info("Probing failed sensor")
+	default:
+		entry.Info("Sensor healthy")
+	}
+}
+
+// State returns the current health state.
+func (h *Health) State() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state == StateFailed && !h.timeNow().Before(h.nextProbe) {
+		return StateProbing
+	}
+	return h.state
+}
+
+// ReadData reads the sensor like Read and returns its humidity and temperature.
+func (h *Health) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	reading, err := h.Read(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	temperature, _ = reading.Value(QuantityTemperature)
+	humidity, _ = reading.Value(QuantityHumidity)
+	return humidity, temperature, nil
+}
+
+// Quantities returns the quantities of the wrapped sensor.
+func (h *Health) Quantities() []Quantity {
+	return h.reader.Quantities()
+}
+
+// HasHumidity reports whether the wrapped sensor measures humidity.
+func (h *Health) HasHumidity() bool {
+	return HasHumidity(h.raw)
+}
+
+// TemperatureUnit returns the temperature unit symbol of the wrapped sensor.
+func (h *Health) TemperatureUnit() string {
+	return h.raw.TemperatureUnit()
+}
+
+// Name returns the sensor name.
+func (h *Health) Name() string {
+	return h.raw.Name()
+}
+
+// GPIO returns the GPIO pin identifier.
+func (h *Health) GPIO() string {
+	return h.raw.GPIO()
+}
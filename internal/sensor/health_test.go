@@ -0,0 +1,219 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// newTestHealth returns a Health around a mock sensor on a fake clock.
+func newTestHealth(cfg config.HealthConfig) (*Health, *mockSensor, *time.Time) {
+	mock := &mockSensor{name: "attic", gpio: "GPIO4", humidity: 40, temperature: 20, unit: CelsiusSymbol}
+	h := NewHealth(mock, cfg, getSilentLogger())
+	now := time.Unix(1700000000, 0)
+	h.timeNow = func() time.Time { return now }
+	return h, mock, &now
+}
+
+// countingSensor is a mock sensor counting its reads.
+type countingSensor struct {
+	mockSensor
+	reads int
+}
+
+func (m *countingSensor) ReadData(ctx context.Context) (float64, float64, error) {
+	m.reads++
+	return m.mockSensor.ReadData(ctx)
+}
+
+func TestHealth_Transitions(t *testing.T) {
+	h, mock, now := newTestHealth(config.HealthConfig{DegradedAfter: 2, FailedAfter: 3, Backoff: time.Minute, BackoffMax: 3 * time.Minute})
+	ctx := context.Background()
+
+	mock.err = errors.New("checksum mismatch")
+	wantStates := []string{StateHealthy, StateDegraded, StateFailed}
+	for i, want := range wantStates {
+		if _, err := h.Read(ctx); err == nil {
+			t.Fatalf("Read() %d returned no error", i+1)
+		}
+		if got := h.State(); got != want {
+			t.Errorf("State() after %d failures = %q, want %q", i+1, got, want)
+		}
+	}
+
+	// Reads are skipped during the backoff
+	mock.err = nil
+	if _, err := h.Read(ctx); !errors.Is(err, ErrBackoff) {
+		t.Errorf("Read() during backoff error = %v, want ErrBackoff", err)
+	}
+
+	*now = now.Add(time.Minute)
+	if got := h.State(); got != StateProbing {
+		t.Errorf("State() after the backoff = %q, want %q", got, StateProbing)
+	}
+	reading, err := h.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read() probe returned unexpected error: %v", err)
+	}
+	if v, _ := reading.Value(QuantityTemperature); v != 20 {
+		t.Errorf("temperature = %v, want 20", v)
+	}
+	if got := h.State(); got != StateHealthy {
+		t.Errorf("State() after a successful probe = %q, want %q", got, StateHealthy)
+	}
+}
+
+func TestHealth_Backoff(t *testing.T) {
+	inner := &countingSensor{mockSensor: mockSensor{name: "attic", gpio: "GPIO4", unit: CelsiusSymbol, err: errors.New("no response")}}
+	h := NewHealth(inner, config.HealthConfig{DegradedAfter: 1, FailedAfter: 1, Backoff: time.Minute, BackoffMax: 3 * time.Minute}, getSilentLogger())
+	now := time.Unix(1700000000, 0)
+	h.timeNow = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, _ = h.Read(ctx)
+	if h.State() != StateFailed {
+		t.Fatalf("State() = %q, want %q", h.State(), StateFailed)
+	}
+
+	// Failed probes double the backoff up to the maximum
+	for _, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		reads := inner.reads
+		now = now.Add(backoff - time.Second)
+		_, _ = h.Read(ctx)
+		if inner.reads != reads {
+			t.Fatalf("sensor read %v into a %v backoff", backoff-time.Second, backoff)
+		}
+		now = now.Add(time.Second)
+		_, _ = h.Read(ctx)
+		if inner.reads != reads+1 {
+			t.Fatalf("sensor not probed after a %v backoff", backoff)
+		}
+		if h.State() != StateFailed {
+			t.Errorf("State() after a failed probe = %q, want %q", h.State(), StateFailed)
+		}
+	}
+}
+
+// A read abandoned by the caller says nothing about the sensor
+func TestHealth_CancelledReadNotCounted(t *testing.T) {
+	h, mock, _ := newTestHealth(config.HealthConfig{DegradedAfter: 1, FailedAfter: 1, Backoff: time.Minute})
+	mock.err = errors.New("checksum mismatch")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.Read(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Read() error = %v, want Canceled", err)
+	}
+	if got := h.State(); got != StateHealthy {
+		t.Errorf("State() = %q, want %q", got, StateHealthy)
+	}
+}
+
+func TestHealth_Defaults(t *testing.T) {
+	h := NewHealth(&mockSensor{}, config.HealthConfig{}, getSilentLogger())
+	if h.cfg.DegradedAfter != config.DefaultHealthDegradedAfter || h.cfg.FailedAfter != config.DefaultHealthFailedAfter ||
+		h.cfg.Backoff != config.DefaultHealthBackoff || h.cfg.BackoffMax != config.DefaultHealthBackoffMax {
+		t.Errorf("cfg = %+v, want defaults", h.cfg)
+	}
+	if !HasHumidity(h) {
+		t.Error("HasHumidity() = false, want true for a humidity sensor")
+	}
+	if HasHumidity(NewHealth(&temperatureOnlySensor{}, config.HealthConfig{}, getSilentLogger())) {
+		t.Error("HasHumidity() = true, want false for a temperature only sensor")
+	}
+}
+
+// fakePower is a PowerSwitch recording the power levels set.
+type fakePower struct {
+	mu     sync.Mutex
+	levels []bool
+	err    error
+}
+
+func (p *fakePower) SetPower(on bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.levels = append(p.levels, on)
+	return p.err
+}
+
+func (p *fakePower) Levels() []bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]bool(nil), p.levels...)
+}
+
+func TestHealth_PowerCycle(t *testing.T) {
+	h, mock, _ := newTestHealth(config.HealthConfig{DegradedAfter: 1, FailedAfter: 2, Backoff: time.Hour})
+	power := &fakePower{}
+	h.SetPowerSwitch(power, config.PowerConfig{GPIO: 22, CycleAfter: 2, OffTime: 5 * time.Second, WarmUp: 2 * time.Second})
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var sleeps []time.Duration
+	h.sleep = func(d time.Duration) {
+		mu.Lock()
+		sleeps = append(sleeps, d)
+		mu.Unlock()
+		<-release
+	}
+
+	mock.err = errors.New("no response")
+	ctx := context.Background()
+	_, _ = h.Read(ctx)
+	if cycles, ok := h.PowerCycles(); cycles != 0 || !ok {
+		t.Fatalf("PowerCycles() = (%d, %v) after one failure, want (0, true)", cycles, ok)
+	}
+	_, _ = h.Read(ctx)
+	if cycles, _ := h.PowerCycles(); cycles != 1 {
+		t.Fatalf("PowerCycles() = %d after two failures, want 1", cycles)
+	}
+
+	// Reads wait for the power to be back
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := h.Read(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Read() during the power cycle error = %v, want DeadlineExceeded", err)
+	}
+
+	mock.err = nil
+	done := make(chan error)
+	go func() {
+		_, err := h.Read(ctx)
+		done <- err
+	}()
+	release <- struct{}{}
+	release <- struct{}{}
+
+	// The failed sensor is probed once powered, without waiting for its backoff
+	if err := <-done; err != nil {
+		t.Fatalf("Read() after the power cycle returned unexpected error: %v", err)
+	}
+	if got := h.State(); got != StateHealthy {
+		t.Errorf("State() = %q, want %q", got, StateHealthy)
+	}
+
+	if levels := power.Levels(); len(levels) != 2 || levels[0] || !levels[1] {
+		t.Errorf("power levels = %v, want [false true]", levels)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sleeps) != 2 || sleeps[0] != 5*time.Second || sleeps[1] != 2*time.Second {
+		t.Errorf("sleeps = %v, want [5s 2s]", sleeps)
+	}
+}
+
+func TestHealth_NoPowerSwitch(t *testing.T) {
+	h, mock, _ := newTestHealth(config.HealthConfig{})
+	mock.err = errors.New("no response")
+	for range 10 {
+		_, _ = h.Read(context.Background())
+	}
+	if cycles, ok := h.PowerCycles(); cycles != 0 || ok {
+		t.Errorf("PowerCycles() = (%d, %v), want (0, false)", cycles, ok)
+	}
+}
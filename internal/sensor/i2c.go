@@ -0,0 +1,50 @@
+package sensor
+
+import (
+	"fmt"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/i2c"
+)
+
+// i2cConn is a device on an I2C bus; *i2c.Device implements it.
+type i2cConn interface {
+	// Tx writes w, then reads len(r) bytes into r.
+	Tx(w, r []byte) error
+	Close() error
+}
+
+// openI2C opens the device configured by i2c_bus and i2c_address, using
+// defaultAddr when no address is set.
+func openI2C(cfg *config.SensorConfig, defaultAddr int) (i2cConn, string, error) {
+	bus := cfg.I2CBus
+	if bus == "" {
+		bus = i2c.DefaultBus
+	}
+	addr := cfg.I2CAddress
+	if addr == 0 {
+		addr = defaultAddr
+	}
+	dev, err := i2c.Open(bus, addr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open I2C device for sensor '%s': %w", cfg.Name, err)
+	}
+	return dev, fmt.Sprintf("%s@0x%02x", bus, addr), nil
+}
+
+// crc8 is the CRC-8 used by Sensirion and Aosong sensors: polynomial 0x31,
+// initial value 0xFF, no reflection and no final XOR.
+func crc8(data []byte) byte {
+	crc := byte(0xFF)
+	for _, b := range data {
+		crc ^= b
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x31
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
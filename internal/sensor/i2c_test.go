@@ -0,0 +1,83 @@
+package sensor
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// fakeI2C is an in-memory I2C device. Reads following a single byte write
+// are answered from regs, other reads from the responses queue.
+type fakeI2C struct {
+	regs      map[byte][]byte
+	responses [][]byte
+	writes    [][]byte
+	err       error
+	closed    bool
+}
+
+func (f *fakeI2C) Tx(w, r []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	if len(w) > 0 {
+		f.writes = append(f.writes, append([]byte(nil), w...))
+	}
+	if len(r) == 0 {
+		return nil
+	}
+	if len(w) == 1 {
+		if data, ok := f.regs[w[0]]; ok {
+			copy(r, data)
+			return nil
+		}
+	}
+	if len(f.responses) == 0 {
+		return errors.New("no response")
+	}
+	copy(r, f.responses[0])
+	f.responses = f.responses[1:]
+	return nil
+}
+
+func (f *fakeI2C) Close() error {
+	f.closed = true
+	return nil
+}
+
+// testI2CConfig returns a sensor configuration for I2C tests
+func testI2CConfig(unit string) *config.SensorConfig {
+	return &config.SensorConfig{
+		Name:            "test-sensor",
+		GPIO:            "GPIO0",
+		MaxRetries:      3,
+		TemperatureUnit: unit,
+	}
+}
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// The CRC example of the SHT3x-DIS datasheet, section 4.12
+func TestCRC8(t *testing.T) {
+	if got := crc8([]byte{0xBE, 0xEF}); got != 0x92 {
+		t.Errorf("crc8(0xBEEF) = 0x%02x, want 0x92", got)
+	}
+}
+
+func TestOpen_I2CWithoutBus(t *testing.T) {
+	for _, backend := range []string{BackendBME280, BackendSHT3x, BackendAHT20} {
+		_, err := Open(&config.SensorConfig{Name: "test", Backend: backend, I2CBus: "/nonexistent/i2c-9"}, getSilentLogger())
+		if err == nil {
+			t.Errorf("Open(%s) expected error for missing bus, got nil", backend)
+		}
+	}
+}
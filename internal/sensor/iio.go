@@ -0,0 +1,212 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	// iioDevicesDir is where the kernel exposes IIO devices.
+	iioDevicesDir = "/sys/bus/iio/devices"
+
+	// iioTemperatureFile holds the temperature in millidegrees Celsius.
+	iioTemperatureFile = "in_temp_input"
+
+	// iioHumidityFile holds the relative humidity in milli-percent.
+	iioHumidityFile = "in_humidityrelative_input"
+
+	// iioRetryDelay is the wait before retrying a failed read. The dht11
+	// driver caches a reading for 2s, so retrying sooner would not reach the sensor.
+	iioRetryDelay = dhtMinInterval
+)
+
+// IIOSensor implements the Reader interface for DHT11/DHT22 sensors handled by
+// the kernel dht11 IIO driver (the "dht11" device tree overlay).
+// The kernel does the timing-critical decoding; readings come from sysfs.
+type IIOSensor struct {
+	name       string
+	gpio       string
+	device     string
+	maxRetries int
+	logger     *log.Logger
+
+	mu sync.Mutex
+
+	// readFile and sleep are replaced in tests.
+	readFile func(string) ([]byte, error)
+	sleep    func(context.Context, time.Duration) error
+}
+
+// NewIIO creates a reader for the IIO device selected by iio_device.
+// Returns an error if the device cannot be found.
+func NewIIO(cfg *config.SensorConfig, logger *log.Logger) (*IIOSensor, error) {
+	return newIIO(cfg, logger, iioDevicesDir)
+}
+
+// newIIO is NewIIO with the IIO devices directory as a parameter.
+func newIIO(cfg *config.SensorConfig, logger *log.Logger, devicesDir string) (*IIOSensor, error) {
+	if cfg.IIODevice == "" {
+		return nil, fmt.Errorf("sensor '%s' uses the iio backend but has no iio_device", cfg.Name)
+	}
+	device, err := findIIODevice(devicesDir, cfg.IIODevice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find IIO device for sensor '%s': %w", cfg.Name, err)
+	}
+
+	logger.WithFields(log.Fields{
+		"sensor": cfg.Name,
+		"gpio":   cfg.GPIO,
+		"device": device,
+	}).Info("Initializing sensor on kernel IIO device")
+
+	return &IIOSensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		device:     device,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		readFile:   os.ReadFile,
+		sleep:      sleepContext,
+	}, nil
+}
+
+// findIIODevice resolves selector to an IIO device directory. A selector
+// containing a slash is a path; otherwise it is matched against the device
+// directory names (e.g. "iio:device0") and the contents of their name files.
+func findIIODevice(devicesDir, selector string) (string, error) {
+	if strings.Contains(selector, "/") {
+		if _, err := os.Stat(filepath.Join(selector, iioTemperatureFile)); err != nil {
+			return "", fmt.Errorf("'%s' is not an IIO temperature device: %w", selector, err)
+		}
+		return selector, nil
+	}
+
+	entries, err := os.ReadDir(devicesDir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		device := filepath.Join(devicesDir, entry.Name())
+		if entry.Name() == selector {
+			return device, nil
+		}
+		name, err := os.ReadFile(filepath.Join(device, "name"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(name)) == selector {
+			return device, nil
+		}
+	}
+	return "", fmt.Errorf("no IIO device named '%s' in %s", selector, devicesDir)
+}
+
+// ReadData reads humidity and temperature from sysfs, retrying up to
+// max_retries times when the kernel reports a failed sensor transfer.
+// Returns an error if all attempts fail or the device cannot be read.
+func (s *IIOSensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if attempt > 1 {
+			if err = s.sleep(ctx, iioRetryDelay); err != nil {
+				break
+			}
+		}
+
+		humidity, temperature, err = s.read()
+		if err == nil || !isTransientIIOError(err) {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"gpio":    s.gpio,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"gpio":   s.gpio,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return 0, 0, err
+	}
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"gpio":        s.gpio,
+		"humidity":    humidity,
+		"temperature": temperature,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	return humidity, temperature, nil
+}
+
+// read reads one humidity and temperature pair in Celsius.
+func (s *IIOSensor) read() (humidity, temperature float64, err error) {
+	humidity, err = s.readMilli(iioHumidityFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	temperature, err = s.readMilli(iioTemperatureFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	return humidity, temperature, nil
+}
+
+// readMilli reads a sysfs attribute holding an integer in milli-units.
+func (s *IIOSensor) readMilli(file string) (float64, error) {
+	data, err := s.readFile(filepath.Join(s.device, file))
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %w", file, err)
+	}
+	return float64(value) / 1000, nil
+}
+
+// isTransientIIOError reports whether err is one the dht11 driver returns
+// when a transfer with the sensor fails and the read should be retried.
+func isTransientIIOError(err error) bool {
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *IIOSensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *IIOSensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *IIOSensor) GPIO() string {
+	return s.gpio
+}
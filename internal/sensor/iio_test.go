@@ -0,0 +1,248 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// writeIIODevice creates a fake sysfs IIO device directory under root
+func writeIIODevice(t *testing.T, root, dir, name, temperature, humidity string) string {
+	t.Helper()
+	device := filepath.Join(root, dir)
+	files := map[string]string{
+		"name":             name + "\n",
+		iioTemperatureFile: temperature + "\n",
+		iioHumidityFile:    humidity + "\n",
+	}
+	if err := os.MkdirAll(device, 0o755); err != nil {
+		t.Fatalf("Failed to create device directory: %v", err)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(device, file), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+	return device
+}
+
+// newTestIIO returns an IIOSensor on a fake sysfs tree whose sleeps are recorded
+func newTestIIO(t *testing.T, root, selector, unit string) (*IIOSensor, *[]time.Duration) {
+	t.Helper()
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	s, err := newIIO(&config.SensorConfig{
+		Name:            "test-sensor",
+		GPIO:            "GPIO4",
+		MaxRetries:      3,
+		TemperatureUnit: unit,
+		IIODevice:       selector,
+	}, logger, root)
+	if err != nil {
+		t.Fatalf("newIIO() returned unexpected error: %v", err)
+	}
+
+	var sleeps []time.Duration
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return s, &sleeps
+}
+
+func TestIIOSensor_ImplementsReader(t *testing.T) {
+	var _ Reader = (*IIOSensor)(nil)
+}
+
+func TestIIOSensor_SelectDevice(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "mcp3008", "0", "0")
+	device := writeIIODevice(t, root, "iio:device1", "dht11@4", "23400", "65200")
+
+	tests := []struct {
+		name     string
+		selector string
+	}{
+		{"by name", "dht11@4"},
+		{"by directory", "iio:device1"},
+		{"by path", device},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestIIO(t, root, tt.selector, "celsius")
+			if s.device != device {
+				t.Errorf("device = %q, want %q", s.device, device)
+			}
+		})
+	}
+}
+
+func TestNewIIO_Errors(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "23400", "65200")
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name     string
+		selector string
+	}{
+		{"missing selector", ""},
+		{"unknown name", "dht11@17"},
+		{"path without readings", root},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newIIO(&config.SensorConfig{Name: "test", IIODevice: tt.selector}, logger, root)
+			if err == nil {
+				t.Error("newIIO() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestIIOSensor_ReadData(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "23400", "65200")
+	s, _ := newTestIIO(t, root, "dht11@4", "celsius")
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 65.2 || temperature != 23.4 {
+		t.Errorf("ReadData() = %v, %v; want 65.2, 23.4", humidity, temperature)
+	}
+}
+
+// Sensors read in Celsius whatever their temperature_unit, which the exporter applies
+func TestIIOSensor_ReadsCelsius(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "-12500", "38700")
+	s, _ := newTestIIO(t, root, "dht11@4", "fahrenheit")
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != -12.5 {
+		t.Errorf("temperature = %v, want -12.5", temperature)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+// The dht11 driver returns EIO or ETIMEDOUT when a transfer fails; those are retried
+func TestIIOSensor_RetryTransientErrors(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "23400", "65200")
+	s, sleeps := newTestIIO(t, root, "dht11@4", "celsius")
+
+	failures := []error{syscall.EIO, syscall.ETIMEDOUT}
+	s.readFile = func(path string) ([]byte, error) {
+		if len(failures) > 0 {
+			err := failures[0]
+			failures = failures[1:]
+			return nil, &fs.PathError{Op: "read", Path: path, Err: err}
+		}
+		return os.ReadFile(path)
+	}
+
+	humidity, _, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 65.2 {
+		t.Errorf("humidity = %v, want 65.2", humidity)
+	}
+	if len(*sleeps) != 2 || (*sleeps)[0] != iioRetryDelay {
+		t.Errorf("sleeps = %v, want two waits of %v", *sleeps, iioRetryDelay)
+	}
+}
+
+func TestIIOSensor_AllAttemptsFail(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "23400", "65200")
+	s, sleeps := newTestIIO(t, root, "dht11@4", "celsius")
+
+	s.readFile = func(path string) ([]byte, error) {
+		return nil, &fs.PathError{Op: "read", Path: path, Err: syscall.EIO}
+	}
+
+	if _, _, err := s.ReadData(context.Background()); !errors.Is(err, syscall.EIO) {
+		t.Errorf("ReadData() error = %v, want EIO", err)
+	}
+	if len(*sleeps) != 2 {
+		t.Errorf("sleeps = %d, want 2 for 3 attempts", len(*sleeps))
+	}
+}
+
+// Retries stop at the deadline of the read instead of using every attempt
+func TestIIOSensor_RetryDeadline(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "23400", "65200")
+	s, _ := newTestIIO(t, root, "dht11@4", "celsius")
+	s.maxRetries = 10
+	s.sleep = sleepContext
+
+	reads := 0
+	s.readFile = func(path string) ([]byte, error) {
+		reads++
+		return nil, &fs.PathError{Op: "read", Path: path, Err: syscall.EIO}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := s.ReadData(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadData() error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReadData() took %v, want the retry wait cut at the deadline", elapsed)
+	}
+	if reads != 1 {
+		t.Errorf("reads = %d, want 1 before the deadline", reads)
+	}
+}
+
+// Errors other than failed transfers, such as a removed device, are not retried
+func TestIIOSensor_PermanentError(t *testing.T) {
+	root := t.TempDir()
+	device := writeIIODevice(t, root, "iio:device0", "dht11@4", "23400", "65200")
+	s, sleeps := newTestIIO(t, root, "dht11@4", "celsius")
+
+	if err := os.RemoveAll(device); err != nil {
+		t.Fatalf("Failed to remove device: %v", err)
+	}
+
+	if _, _, err := s.ReadData(context.Background()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadData() error = %v, want ErrNotExist", err)
+	}
+	if len(*sleeps) != 0 {
+		t.Errorf("sleeps = %v, want no retry", *sleeps)
+	}
+}
+
+func TestIIOSensor_InvalidValue(t *testing.T) {
+	root := t.TempDir()
+	writeIIODevice(t, root, "iio:device0", "dht11@4", "warm", "65200")
+	s, _ := newTestIIO(t, root, "dht11@4", "celsius")
+
+	if _, _, err := s.ReadData(context.Background()); err == nil {
+		t.Error("ReadData() expected error for non-numeric value, got nil")
+	}
+}
@@ -0,0 +1,249 @@
+package sensor
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/modbus"
+)
+
+// modbusConn reads registers from Modbus slaves, see modbus.Client.
+type modbusConn interface {
+	ReadRegisters(slave, function byte, address, count uint16) ([]uint16, error)
+}
+
+// modbusBus is a Modbus client shared by the sensors of a serial bus or TCP gateway.
+type modbusBus struct {
+	client   *modbus.Client
+	settings string
+}
+
+var (
+	modbusBusesMu sync.Mutex
+	// modbusBuses holds the open clients by serial device or TCP address, so
+	// that transmitters on the same RS485 bus are never queried concurrently.
+	modbusBuses = make(map[string]*modbusBus)
+)
+
+// openModbus returns the client of the bus or gateway configured in m,
+// opening it on first use. Sensors sharing a bus must use the same settings.
+func openModbus(name string, m *config.ModbusConfig) (modbusConn, string, error) {
+	location, settings := m.Address, fmt.Sprintf("tcp timeout=%s", m.Timeout)
+	if m.Device != "" {
+		location, settings = m.Device, fmt.Sprintf("rtu baud_rate=%d parity=%s timeout=%s", m.BaudRate, m.Parity, m.Timeout)
+	}
+
+	modbusBusesMu.Lock()
+	defer modbusBusesMu.Unlock()
+
+	if bus, ok := modbusBuses[location]; ok {
+		if bus.settings != settings {
+			return nil, "", fmt.Errorf("sensor '%s' uses %s with settings (%s) differing from another sensor (%s)", name, location, settings, bus.settings)
+		}
+		return bus.client, location, nil
+	}
+
+	var client *modbus.Client
+	if m.Device != "" {
+		var err error
+		client, err = modbus.OpenRTU(m.Device, m.BaudRate, m.Parity, m.Timeout)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open Modbus RTU bus for sensor '%s': %w", name, err)
+		}
+	} else {
+		client = modbus.NewTCP(m.Address, m.Timeout)
+	}
+	modbusBuses[location] = &modbusBus{client: client, settings: settings}
+	return client, location, nil
+}
+
+// modbusRegisterCount returns the number of registers holding a value of dataType.
+func modbusRegisterCount(dataType string) int {
+	switch dataType {
+	case "int32", "uint32", "float32":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// decodeModbusValue decodes a value of dataType from registers sent in byteOrder,
+// where "ABCD" is big endian with A the most significant byte.
+func decodeModbusValue(regs []uint16, dataType, byteOrder string) float64 {
+	b := make([]byte, 0, 4)
+	for _, r := range regs {
+		b = binary.BigEndian.AppendUint16(b, r)
+	}
+
+	switch {
+	case len(b) == 2 && (byteOrder == "DCBA" || byteOrder == "BADC"):
+		b[0], b[1] = b[1], b[0]
+	case len(b) == 4 && byteOrder == "DCBA":
+		b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	case len(b) == 4 && byteOrder == "BADC":
+		b[0], b[1], b[2], b[3] = b[1], b[0], b[3], b[2]
+	case len(b) == 4 && byteOrder == "CDAB":
+		b[0], b[1], b[2], b[3] = b[2], b[3], b[0], b[1]
+	}
+
+	switch dataType {
+	case "uint16":
+		return float64(binary.BigEndian.Uint16(b))
+	case "int32":
+		return float64(int32(binary.BigEndian.Uint32(b)))
+	case "uint32":
+		return float64(binary.BigEndian.Uint32(b))
+	case "float32":
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	default:
+		return float64(int16(binary.BigEndian.Uint16(b)))
+	}
+}
+
+// ModbusSensor implements the Reader interface for temperature and humidity
+// transmitters on Modbus RTU (RS485) or Modbus TCP, such as the XY-MD02.
+type ModbusSensor struct {
+	name       string
+	gpio       string
+	location   string
+	maxRetries int
+	logger     *log.Logger
+
+	cfg  config.ModbusConfig
+	conn modbusConn
+}
+
+// NewModbus creates a sensor reading the transmitter configured by the modbus_* settings.
+// No request is made until the first read.
+// Returns an error if the serial device cannot be opened.
+func NewModbus(cfg *config.SensorConfig, logger *log.Logger) (*ModbusSensor, error) {
+	conn, location, err := openModbus(cfg.Name, &cfg.Modbus)
+	if err != nil {
+		return nil, err
+	}
+	return newModbus(cfg, logger, conn, location), nil
+}
+
+// newModbus creates a sensor reading through conn.
+func newModbus(cfg *config.SensorConfig, logger *log.Logger, conn modbusConn, location string) *ModbusSensor {
+	logger.WithFields(log.Fields{
+		"sensor":   cfg.Name,
+		"device":   location,
+		"slave_id": cfg.Modbus.SlaveID,
+	}).Info("Initializing Modbus sensor")
+
+	return &ModbusSensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		location:   location,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		cfg:        cfg.Modbus,
+		conn:       conn,
+	}
+}
+
+// read reads the registers at address, as a block with the humidity
+// registers when they are close enough for a single request.
+func (s *ModbusSensor) read() (temperature, humidity float64, err error) {
+	m := &s.cfg
+	width := modbusRegisterCount(m.DataType)
+	slave, function := byte(m.SlaveID), byte(m.Function)
+
+	start, end := m.TemperatureRegister, m.TemperatureRegister+width
+	if m.HumidityRegister >= 0 {
+		start, end = min(start, m.HumidityRegister), max(end, m.HumidityRegister+width)
+	}
+
+	var tRegs, hRegs []uint16
+	if end-start <= modbus.MaxRegisters {
+		regs, err := s.conn.ReadRegisters(slave, function, uint16(start), uint16(end-start))
+		if err != nil {
+			return 0, 0, err
+		}
+		tRegs = regs[m.TemperatureRegister-start:][:width]
+		if m.HumidityRegister >= 0 {
+			hRegs = regs[m.HumidityRegister-start:][:width]
+		}
+	} else {
+		if tRegs, err = s.conn.ReadRegisters(slave, function, uint16(m.TemperatureRegister), uint16(width)); err != nil {
+			return 0, 0, err
+		}
+		if hRegs, err = s.conn.ReadRegisters(slave, function, uint16(m.HumidityRegister), uint16(width)); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	temperature = decodeModbusValue(tRegs, m.DataType, m.ByteOrder) * m.TemperatureScale
+	if hRegs != nil {
+		humidity = decodeModbusValue(hRegs, m.DataType, m.ByteOrder) * m.HumidityScale
+	}
+	return temperature, humidity, nil
+}
+
+// ReadData reads humidity and temperature, retrying up to max_retries times.
+// Returns an error if all attempts fail.
+func (s *ModbusSensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		temperature, humidity, err = s.read()
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"device":  s.location,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"device": s.location,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return 0, 0, err
+	}
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"device":      s.location,
+		"humidity":    humidity,
+		"temperature": temperature,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	return humidity, temperature, nil
+}
+
+// HasHumidity reports whether a humidity register is configured.
+func (s *ModbusSensor) HasHumidity() bool {
+	return s.cfg.HumidityRegister >= 0
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *ModbusSensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *ModbusSensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *ModbusSensor) GPIO() string {
+	return s.gpio
+}
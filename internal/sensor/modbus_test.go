@@ -0,0 +1,206 @@
+package sensor
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/modbus"
+	"github.com/guivin/dht-prometheus-exporter/internal/modbus/modbustest"
+)
+
+// testModbusConfig returns the configuration of an XY-MD02 style transmitter
+// at address, with temperature and humidity in tenths in input registers 1 and 2.
+func testModbusConfig(address string) *config.SensorConfig {
+	return &config.SensorConfig{
+		Name:            "warehouse",
+		GPIO:            "GPIO0",
+		MaxRetries:      2,
+		TemperatureUnit: "celsius",
+		Backend:         BackendModbus,
+		Modbus: config.ModbusConfig{
+			Address:             address,
+			SlaveID:             1,
+			Function:            modbus.FuncReadInputRegisters,
+			TemperatureRegister: 1,
+			HumidityRegister:    2,
+			TemperatureScale:    0.1,
+			HumidityScale:       0.1,
+			DataType:            "int16",
+			ByteOrder:           "ABCD",
+			Timeout:             time.Second,
+		},
+	}
+}
+
+func TestDecodeModbusValue(t *testing.T) {
+	f := math.Float32bits(21.5)
+	hi, lo := uint16(f>>16), uint16(f)
+	swap := func(r uint16) uint16 { return r<<8 | r>>8 }
+
+	tests := []struct {
+		name      string
+		regs      []uint16
+		dataType  string
+		byteOrder string
+		want      float64
+	}{
+		{"int16", []uint16{0xFF38}, "int16", "ABCD", -200},
+		{"uint16", []uint16{0xFF38}, "uint16", "ABCD", 65336},
+		{"int16 swapped", []uint16{0x38FF}, "int16", "BADC", -200},
+		{"int16 little endian", []uint16{0x38FF}, "int16", "DCBA", -200},
+		{"int32", []uint16{0xFFFF, 0xFF38}, "int32", "ABCD", -200},
+		{"uint32 word swapped", []uint16{0x0002, 0x0001}, "uint32", "CDAB", 0x00010002},
+		{"float32", []uint16{hi, lo}, "float32", "ABCD", 21.5},
+		{"float32 word swapped", []uint16{lo, hi}, "float32", "CDAB", 21.5},
+		{"float32 byte swapped", []uint16{swap(hi), swap(lo)}, "float32", "BADC", 21.5},
+		{"float32 little endian", []uint16{swap(lo), swap(hi)}, "float32", "DCBA", 21.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeModbusValue(tt.regs, tt.dataType, tt.byteOrder); got != tt.want {
+				t.Errorf("decodeModbusValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModbus_ReadData(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	srv.SetInputRegisters(1, 1, 215, 402)
+
+	cfg := testModbusConfig(srv.Addr)
+	s := newModbus(cfg, getSilentLogger(), modbus.NewTCP(srv.Addr, time.Second), srv.Addr)
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if math.Abs(temperature-21.5) > 1e-9 || math.Abs(humidity-40.2) > 1e-9 {
+		t.Errorf("ReadData() = (%v, %v), want (40.2, 21.5)", humidity, temperature)
+	}
+	// Adjacent registers are read in one request
+	if n := srv.Requests(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+	if !s.HasHumidity() {
+		t.Error("HasHumidity() = false, want true")
+	}
+}
+
+// Sensors read in Celsius whatever their temperature_unit, which the exporter applies
+func TestModbus_ReadsCelsius(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	srv.SetInputRegisters(1, 1, 0xFF9C, 500) // -10.0°C
+
+	cfg := testModbusConfig(srv.Addr)
+	cfg.TemperatureUnit = "fahrenheit"
+	s := newModbus(cfg, getSilentLogger(), modbus.NewTCP(srv.Addr, time.Second), srv.Addr)
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if math.Abs(temperature+10) > 1e-9 {
+		t.Errorf("temperature = %v, want -10", temperature)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+func TestModbus_DistantRegisters(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	f := math.Float32bits(55.25)
+	srv.SetHoldingRegisters(3, 0, 0x41AC, 0x0000) // 21.5 as float32
+	srv.SetHoldingRegisters(3, 500, uint16(f>>16), uint16(f))
+
+	cfg := testModbusConfig(srv.Addr)
+	cfg.Modbus.SlaveID = 3
+	cfg.Modbus.Function = modbus.FuncReadHoldingRegisters
+	cfg.Modbus.TemperatureRegister = 0
+	cfg.Modbus.HumidityRegister = 500
+	cfg.Modbus.TemperatureScale = 1
+	cfg.Modbus.HumidityScale = 1
+	cfg.Modbus.DataType = "float32"
+	s := newModbus(cfg, getSilentLogger(), modbus.NewTCP(srv.Addr, time.Second), srv.Addr)
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != 21.5 || humidity != 55.25 {
+		t.Errorf("ReadData() = (%v, %v), want (55.25, 21.5)", humidity, temperature)
+	}
+	if n := srv.Requests(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestModbus_TemperatureOnly(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	srv.SetInputRegisters(1, 1, 215)
+
+	cfg := testModbusConfig(srv.Addr)
+	cfg.Modbus.HumidityRegister = -1
+	s := newModbus(cfg, getSilentLogger(), modbus.NewTCP(srv.Addr, time.Second), srv.Addr)
+
+	if _, _, err := s.ReadData(context.Background()); err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if s.HasHumidity() {
+		t.Error("HasHumidity() = true, want false")
+	}
+}
+
+func TestModbus_ReadFailure(t *testing.T) {
+	srv := modbustest.NewServer()
+	defer srv.Close()
+	srv.SetInputRegisters(2, 1, 215, 402) // another slave
+
+	cfg := testModbusConfig(srv.Addr)
+	s := newModbus(cfg, getSilentLogger(), modbus.NewTCP(srv.Addr, time.Second), srv.Addr)
+
+	if _, _, err := s.ReadData(context.Background()); err == nil {
+		t.Fatal("ReadData() expected error for a missing slave, got nil")
+	}
+	if n := srv.Requests(); n != cfg.MaxRetries {
+		t.Errorf("requests = %d, want %d", n, cfg.MaxRetries)
+	}
+}
+
+func TestOpenModbus_SharedBus(t *testing.T) {
+	t.Cleanup(func() {
+		modbusBusesMu.Lock()
+		delete(modbusBuses, "192.0.2.1:502")
+		modbusBusesMu.Unlock()
+	})
+
+	a := testModbusConfig("192.0.2.1:502")
+	b := testModbusConfig("192.0.2.1:502")
+	b.Modbus.SlaveID = 2
+
+	connA, _, err := openModbus(a.Name, &a.Modbus)
+	if err != nil {
+		t.Fatalf("openModbus() returned unexpected error: %v", err)
+	}
+	connB, _, err := openModbus(b.Name, &b.Modbus)
+	if err != nil {
+		t.Fatalf("openModbus() returned unexpected error: %v", err)
+	}
+	if connA != connB {
+		t.Error("sensors behind the same gateway do not share a client")
+	}
+
+	b.Modbus.Timeout = 5 * time.Second
+	if _, _, err := openModbus(b.Name, &b.Modbus); err == nil {
+		t.Error("openModbus() expected error for differing settings, got nil")
+	}
+}
@@ -0,0 +1,51 @@
+package sensor
+
+import (
+	"fmt"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/gpiocdev"
+)
+
+// PowerSwitch switches the power supply of a sensor.
+type PowerSwitch interface {
+	// SetPower powers the sensor on or off.
+	SetPower(on bool) error
+}
+
+// PowerCycleReporter is implemented by readers able to power cycle their sensor.
+type PowerCycleReporter interface {
+	// PowerCycles returns the number of power cycles so far, and false if
+	// the sensor has no power switch.
+	PowerCycles() (uint64, bool)
+}
+
+// gpioPower is a PowerSwitch driving a GPIO line high to power the sensor,
+// e.g. through a transistor on its VCC.
+type gpioPower struct {
+	line *gpiocdev.Line
+}
+
+// OpenPowerSwitch requests the power_gpio line of cfg on its gpio_chip as an
+// output driven high, so the sensor is powered. The line stays requested for
+// the life of the process.
+func OpenPowerSwitch(cfg *config.SensorConfig) (PowerSwitch, error) {
+	chip := cfg.GPIOChip
+	if chip == "" {
+		chip = gpiocdev.DefaultChip
+	}
+	line, err := gpiocdev.RequestOutput(chip, cfg.Power.GPIO, gpioConsumer, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request power GPIO%d of sensor '%s': %w", cfg.Power.GPIO, cfg.Name, err)
+	}
+	return &gpioPower{line: line}, nil
+}
+
+// SetPower drives the line high to power the sensor on, low to power it off.
+func (p *gpioPower) SetPower(on bool) error {
+	value := 0
+	if on {
+		value = 1
+	}
+	return p.line.SetValue(value)
+}
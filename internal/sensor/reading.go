@@ -0,0 +1,113 @@
+package sensor
+
+import (
+	"context"
+	"time"
+)
+
+// Names of the quantities measured by sensors.
+const (
+	// QuantityTemperature is the temperature in the sensor's TemperatureUnit.
+	QuantityTemperature = "temperature"
+	// QuantityHumidity is the relative humidity in percent.
+	QuantityHumidity = "humidity"
+	// QuantityPressure is the barometric pressure in pascals.
+	QuantityPressure = "pressure"
+)
+
+// Unit symbols of the quantities measured by sensors, besides CelsiusSymbol and FahrenheitSymbol.
+const (
+	// UnitPercent is used for relative humidity.
+	UnitPercent = "%"
+	// UnitPascal is used for pressure.
+	UnitPascal = "Pa"
+)
+
+// Quantity describes something a sensor measures.
+type Quantity struct {
+	// Name identifies the quantity, e.g. QuantityTemperature.
+	Name string
+	// Unit is the unit symbol of the values, e.g. CelsiusSymbol or UnitPascal.
+	Unit string
+}
+
+// Measurement is the value of a quantity in a reading.
+type Measurement struct {
+	Quantity
+	Value float64
+}
+
+// Reading is the result of reading a sensor once.
+type Reading struct {
+	Time         time.Time
+	Measurements []Measurement
+}
+
+// Value returns the value of the named quantity and whether the reading has it.
+func (r Reading) Value(name string) (float64, bool) {
+	for _, m := range r.Measurements {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+	return 0, false
+}
+
+// MultiReader is a Reader measuring any set of quantities, such as pressure,
+// CO2 or light in addition to temperature and humidity.
+// Use Extend to get a MultiReader for any Reader.
+type MultiReader interface {
+	Reader
+
+	// Quantities lists the quantities returned by Read. It does not change
+	// over the reader's lifetime, so metrics can be described up front.
+	Quantities() []Quantity
+
+	// Read measures every quantity at once.
+	// Retries stop once ctx is done, in which case the context's error is returned.
+	// Returns an error if the sensor read fails.
+	Read(ctx context.Context) (Reading, error)
+}
+
+// Extend returns r as a MultiReader. Readers only implementing Reader are
+// adapted to measure temperature and, unless HasHumidity reports otherwise, humidity.
+func Extend(r Reader) MultiReader {
+	if m, ok := r.(MultiReader); ok {
+		return m
+	}
+	return &readerAdapter{Reader: r, timeNow: time.Now}
+}
+
+// readerAdapter implements MultiReader on top of ReadData.
+type readerAdapter struct {
+	Reader
+	timeNow func() time.Time
+}
+
+// Quantities returns temperature and, if measured, humidity.
+func (a *readerAdapter) Quantities() []Quantity {
+	quantities := []Quantity{{Name: QuantityTemperature, Unit: a.TemperatureUnit()}}
+	if HasHumidity(a.Reader) {
+		quantities = append(quantities, Quantity{Name: QuantityHumidity, Unit: UnitPercent})
+	}
+	return quantities
+}
+
+// Read calls ReadData and wraps its values in a Reading.
+func (a *readerAdapter) Read(ctx context.Context) (Reading, error) {
+	humidity, temperature, err := a.ReadData(ctx)
+	if err != nil {
+		return Reading{}, err
+	}
+	reading := Reading{
+		Time: a.timeNow(),
+		Measurements: []Measurement{
+			{Quantity: Quantity{Name: QuantityTemperature, Unit: a.TemperatureUnit()}, Value: temperature},
+		},
+	}
+	if HasHumidity(a.Reader) {
+		reading.Measurements = append(reading.Measurements,
+			Measurement{Quantity: Quantity{Name: QuantityHumidity, Unit: UnitPercent}, Value: humidity})
+	}
+	return reading, nil
+}
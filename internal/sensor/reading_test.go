@@ -0,0 +1,87 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// temperatureOnlySensor is a mock sensor without a humidity sensor
+type temperatureOnlySensor struct {
+	mockSensor
+}
+
+func (m *temperatureOnlySensor) HasHumidity() bool {
+	return false
+}
+
+func TestReading_Value(t *testing.T) {
+	r := Reading{Measurements: []Measurement{
+		{Quantity: Quantity{Name: QuantityTemperature, Unit: CelsiusSymbol}, Value: 21.5},
+		{Quantity: Quantity{Name: QuantityPressure, Unit: UnitPascal}, Value: 101325},
+	}}
+
+	if v, ok := r.Value(QuantityPressure); !ok || v != 101325 {
+		t.Errorf("Value(pressure) = %v, %v; want 101325, true", v, ok)
+	}
+	if _, ok := r.Value(QuantityHumidity); ok {
+		t.Error("Value(humidity) ok = true, want false")
+	}
+}
+
+func TestExtend_Adapter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := Extend(&mockSensor{name: "test", humidity: 45.2, temperature: 21.5, unit: CelsiusSymbol})
+	m.(*readerAdapter).timeNow = func() time.Time { return now }
+
+	want := []Quantity{{QuantityTemperature, CelsiusSymbol}, {QuantityHumidity, UnitPercent}}
+	if got := m.Quantities(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Quantities() = %v, want %v", got, want)
+	}
+
+	reading, err := m.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	if !reading.Time.Equal(now) {
+		t.Errorf("Time = %v, want %v", reading.Time, now)
+	}
+	if len(reading.Measurements) != 2 ||
+		reading.Measurements[0] != (Measurement{want[0], 21.5}) ||
+		reading.Measurements[1] != (Measurement{want[1], 45.2}) {
+		t.Errorf("Measurements = %+v, want temperature 21.5 C and humidity 45.2 %%", reading.Measurements)
+	}
+}
+
+func TestExtend_TemperatureOnly(t *testing.T) {
+	m := Extend(&temperatureOnlySensor{mockSensor{name: "tank", temperature: 12.5, unit: CelsiusSymbol}})
+
+	if got := m.Quantities(); len(got) != 1 || got[0].Name != QuantityTemperature {
+		t.Errorf("Quantities() = %v, want only temperature", got)
+	}
+	reading, err := m.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	if _, ok := reading.Value(QuantityHumidity); ok || len(reading.Measurements) != 1 {
+		t.Errorf("Measurements = %+v, want only temperature", reading.Measurements)
+	}
+}
+
+func TestExtend_Error(t *testing.T) {
+	readErr := errors.New("sensor timeout")
+	m := Extend(&mockSensor{name: "test", err: readErr, unit: CelsiusSymbol})
+
+	if _, err := m.Read(context.Background()); !errors.Is(err, readErr) {
+		t.Errorf("Read() error = %v, want %v", err, readErr)
+	}
+}
+
+// Readers already implementing MultiReader are returned as is
+func TestExtend_MultiReader(t *testing.T) {
+	s := &BME280Sensor{name: "attic"}
+	if m := Extend(s); m != MultiReader(s) {
+		t.Errorf("Extend() = %T, want the BME280Sensor itself", m)
+	}
+}
@@ -0,0 +1,170 @@
+package sensor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// queueWaitBuckets are the upper bounds of the queue wait histogram, in seconds.
+// A DHT22 read with its retries takes up to a few seconds.
+var queueWaitBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultScheduler is the scheduler of the GPIO bit-banging backends.
+var DefaultScheduler = NewScheduler(dhtMinInterval)
+
+// pinState is the read history of a pin.
+type pinState struct {
+	mu       sync.Mutex
+	lastRead time.Time
+
+	// Queue wait histogram, guarded by Scheduler.mu
+	waitCount   uint64
+	waitSum     float64
+	waitBuckets []uint64
+}
+
+// Scheduler runs timing-sensitive hardware reads one at a time host-wide, so
+// that concurrent scrapes of several sensors do not disturb each other's
+// bit-banging, and spaces reads of the same pin by a minimum interval.
+// It implements prometheus.Collector, exposing how long reads waited for
+// other sensors' reads.
+type Scheduler struct {
+	minInterval time.Duration
+
+	// slot is held while a read runs.
+	slot chan struct{}
+
+	mu   sync.Mutex
+	pins map[string]*pinState
+
+	waitMetric *prometheus.Desc
+
+	// timeNow and sleep are replaced in tests.
+	timeNow func() time.Time
+	sleep   func(context.Context, time.Duration) error
+}
+
+// NewScheduler creates a Scheduler spacing reads of a pin by minInterval.
+func NewScheduler(minInterval time.Duration) *Scheduler {
+	return &Scheduler{
+		minInterval: minInterval,
+		slot:        make(chan struct{}, 1),
+		pins:        make(map[string]*pinState),
+		waitMetric:  newWaitMetric(""),
+		timeNow:     time.Now,
+		sleep:       sleepContext,
+	}
+}
+
+// newWaitMetric returns the descriptor of the queue wait histogram, named
+// after namespace.
+func newWaitMetric(namespace string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		config.MetricName(namespace, "read_queue_wait_seconds"),
+		"Time sensor reads waited for reads of other sensors to complete",
+		[]string{"gpio"}, nil,
+	)
+}
+
+// SetNamespace sets the namespace prefixing the name of the queue wait
+// histogram, config.DefaultMetricsNamespace by default.
+// Must be called before s is registered.
+func (s *Scheduler) SetNamespace(namespace string) {
+	s.waitMetric = newWaitMetric(namespace)
+}
+
+func (s *Scheduler) pin(name string) *pinState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pins[name]
+	if !ok {
+		p = &pinState{waitBuckets: make([]uint64, len(queueWaitBuckets))}
+		s.pins[name] = p
+	}
+	return p
+}
+
+// Do runs read once the minimum interval since the previous read of pin has
+// elapsed and no other read is running, and returns its error.
+// The interval is waited for before queueing, so waiting for a pin does not
+// hold up reads of other pins. If ctx is done before read starts, read is not
+// run and the context's error is returned; a running read is not interrupted.
+func (s *Scheduler) Do(ctx context.Context, pin string, read func() error) error {
+	p := s.pin(pin)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.lastRead.IsZero() {
+		if wait := s.minInterval - s.timeNow().Sub(p.lastRead); wait > 0 {
+			if err := s.sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+	}
+
+	queued := s.timeNow()
+	select {
+	case s.slot <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.observe(p, s.timeNow().Sub(queued))
+
+	err := read()
+	p.lastRead = s.timeNow()
+	<-s.slot
+	return err
+}
+
+// observe records a queue wait of pin p.
+func (s *Scheduler) observe(p *pinState, wait time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seconds := wait.Seconds()
+	p.waitCount++
+	p.waitSum += seconds
+	if i := sort.SearchFloat64s(queueWaitBuckets, seconds); i < len(queueWaitBuckets) {
+		p.waitBuckets[i]++
+	}
+}
+
+// Describe sends the descriptor of the queue wait histogram.
+func (s *Scheduler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.waitMetric
+}
+
+// Collect sends the queue wait histogram of every pin read so far.
+func (s *Scheduler) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, p := range s.pins {
+		if p.waitCount == 0 {
+			continue
+		}
+		buckets := make(map[float64]uint64, len(queueWaitBuckets))
+		var cumulative uint64
+		for i, bound := range queueWaitBuckets {
+			cumulative += p.waitBuckets[i]
+			buckets[bound] = cumulative
+		}
+		ch <- prometheus.MustNewConstHistogram(s.waitMetric, p.waitCount, p.waitSum, buckets, name)
+	}
+}
+
+// sleepContext waits for d, returning early with the context's error if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,172 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestScheduler_Serializes(t *testing.T) {
+	s := NewScheduler(0)
+	var running, peak atomic.Int32
+
+	var wg sync.WaitGroup
+	for _, pin := range []string{"GPIO4", "GPIO17", "GPIO27", "GPIO22"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 3 {
+				err := s.Do(context.Background(), pin, func() error {
+					n := running.Add(1)
+					if n > peak.Load() {
+						peak.Store(n)
+					}
+					time.Sleep(2 * time.Millisecond)
+					running.Add(-1)
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Do() returned unexpected error: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p != 1 {
+		t.Errorf("peak concurrent reads = %d, want 1", p)
+	}
+}
+
+func TestScheduler_MinInterval(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var sleeps []time.Duration
+	s := NewScheduler(2 * time.Second)
+	s.timeNow = func() time.Time { return now }
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+	read := func() error {
+		now = now.Add(500 * time.Millisecond)
+		return nil
+	}
+
+	_ = s.Do(context.Background(), "GPIO4", read)
+	_ = s.Do(context.Background(), "GPIO17", read) // another pin does not wait
+	if len(sleeps) != 0 {
+		t.Fatalf("sleeps = %v, want none before the first read of each pin", sleeps)
+	}
+
+	_ = s.Do(context.Background(), "GPIO4", read)
+	if len(sleeps) != 1 || sleeps[0] != 1500*time.Millisecond {
+		t.Errorf("sleeps = %v, want [1.5s] since GPIO4 was read 0.5s ago", sleeps)
+	}
+
+	now = now.Add(time.Minute)
+	_ = s.Do(context.Background(), "GPIO4", read)
+	if len(sleeps) != 1 {
+		t.Errorf("sleeps = %v, want no wait once the interval elapsed", sleeps)
+	}
+}
+
+func TestScheduler_Cancelled(t *testing.T) {
+	s := NewScheduler(time.Minute)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), "GPIO4", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	defer close(release)
+
+	ran := false
+	read := func() error {
+		ran = true
+		return nil
+	}
+
+	// Waiting for another pin's read
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Do(ctx, "GPIO17", read); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want DeadlineExceeded while queued", err)
+	}
+
+	// Waiting for the minimum interval of the pin
+	s.pin("GPIO27").lastRead = time.Now()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Do(ctx, "GPIO27", read); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want DeadlineExceeded during the interval", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() took %v, want the interval wait cut at the deadline", elapsed)
+	}
+
+	if ran {
+		t.Error("read ran after the context was done")
+	}
+}
+
+func TestScheduler_QueueWaitMetric(t *testing.T) {
+	s := NewScheduler(0)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), "GPIO4", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), "GPIO17", func() error { return nil })
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(s); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	if len(families) != 1 || families[0].GetName() != "dht_read_queue_wait_seconds" {
+		t.Fatalf("families = %v, want dht_read_queue_wait_seconds", families)
+	}
+
+	waits := make(map[string]float64)
+	for _, m := range families[0].GetMetric() {
+		if m.GetHistogram().GetSampleCount() != 1 {
+			t.Errorf("sample count = %d, want 1", m.GetHistogram().GetSampleCount())
+		}
+		waits[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleSum()
+	}
+	if waits["GPIO17"] < 0.015 {
+		t.Errorf("GPIO17 wait = %vs, want at least the 20ms GPIO4 held the scheduler", waits["GPIO17"])
+	}
+	if waits["GPIO4"] > 0.015 {
+		t.Errorf("GPIO4 wait = %vs, want about 0", waits["GPIO4"])
+	}
+}
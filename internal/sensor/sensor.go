@@ -1,7 +1,10 @@
 package sensor
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/MichaelS11/go-dht"
 	log "github.com/sirupsen/logrus"
@@ -14,16 +17,41 @@ const (
 	CelsiusSymbol = "C"
 	// FahrenheitSymbol is the symbol for Fahrenheit temperature unit
 	FahrenheitSymbol = "F"
+	// KelvinSymbol is the symbol for Kelvin temperature unit
+	KelvinSymbol = "K"
+)
+
+const (
+	// BackendPeriph reads DHT sensors with github.com/MichaelS11/go-dht (periph.io).
+	BackendPeriph = "periph"
+	// BackendGPIOCdev reads DHT sensors through the Linux GPIO character device.
+	BackendGPIOCdev = "gpiocdev"
+	// BackendIIO reads DHT11/DHT22 sensors handled by the kernel dht11 IIO driver.
+	BackendIIO = "iio"
+	// BackendDS18B20 reads DS18B20 1-Wire temperature probes.
+	BackendDS18B20 = "ds18b20"
+	// BackendBME280 reads Bosch BME280 sensors over I2C.
+	BackendBME280 = "bme280"
+	// BackendSHT3x reads Sensirion SHT30/SHT31/SHT35 sensors over I2C.
+	BackendSHT3x = "sht3x"
+	// BackendAHT20 reads Aosong AHT20 sensors over I2C.
+	BackendAHT20 = "aht20"
+	// BackendExec reads sensors through an external command.
+	BackendExec = "exec"
+	// BackendModbus reads temperature and humidity transmitters over Modbus RTU or TCP.
+	BackendModbus = "modbus"
 )
 
 // Reader defines the interface for reading sensor data.
 // This interface allows for easy mocking in tests.
 type Reader interface {
 	// ReadData reads humidity and temperature from the sensor.
+	// Retries stop once ctx is done, in which case the context's error is returned.
 	// Returns an error if the sensor read fails.
-	ReadData() (humidity, temperature float64, err error)
+	ReadData(ctx context.Context) (humidity, temperature float64, err error)
 
-	// TemperatureUnit returns the temperature unit symbol ("C" or "F").
+	// TemperatureUnit returns the temperature unit symbol of the values
+	// read ("C", "F" or "K"). Local sensors read in Celsius.
 	TemperatureUnit() string
 
 	// Name returns the sensor name.
@@ -33,21 +61,185 @@ type Reader interface {
 	GPIO() string
 }
 
+// HumidityReporter is implemented by readers that may lack a humidity sensor.
+// Readers not implementing it are assumed to measure humidity.
+type HumidityReporter interface {
+	// HasHumidity reports whether the humidity returned by ReadData is a measurement.
+	HasHumidity() bool
+}
+
+// HasHumidity reports whether r measures humidity.
+func HasHumidity(r Reader) bool {
+	if h, ok := r.(HumidityReporter); ok {
+		return h.HasHumidity()
+	}
+	return true
+}
+
 // DHT22Sensor implements the Reader interface for DHT22/AM2302 sensors.
 type DHT22Sensor struct {
-	name              string
-	gpio              string
-	maxRetries        int
-	temperatureSymbol string
-	client            *dht.DHT
-	logger            *log.Logger
+	name       string
+	gpio       string
+	maxRetries int
+	client     *dht.DHT
+	scheduler  *Scheduler
+	logger     *log.Logger
 }
 
+var (
+	hostInitMu   sync.Mutex
+	hostInitDone bool
+)
+
 // HostInit initializes the DHT host. Must be called once before creating sensors.
 func HostInit() error {
 	return dht.HostInit()
 }
 
+// initHost initializes the periph host on first use. A failed
+// initialization is attempted again on the next call.
+func initHost(logger *log.Logger) error {
+	hostInitMu.Lock()
+	defer hostInitMu.Unlock()
+	if hostInitDone {
+		return nil
+	}
+	logger.Info("Initializing DHT22/AM2302 host")
+	if err := HostInit(); err != nil {
+		return err
+	}
+	hostInitDone = true
+	return nil
+}
+
+// Model returns the sensor model of cfg: its model setting, or the model read
+// by its backend. The model of IIO sensors is the name of their device, e.g.
+// "dht11" for "dht11@4"; it is empty for exec and modbus sensors.
+func Model(cfg *config.SensorConfig) string {
+	if cfg.Model != "" {
+		return cfg.Model
+	}
+	switch cfg.Backend {
+	case "", BackendPeriph, BackendGPIOCdev:
+		return "DHT22"
+	case BackendIIO:
+		if name, _, ok := strings.Cut(cfg.IIODevice, "@"); ok && !strings.Contains(name, "/") {
+			return name
+		}
+		return ""
+	case BackendDS18B20:
+		return "DS18B20"
+	case BackendBME280:
+		return "BME280"
+	case BackendSHT3x:
+		return "SHT3x"
+	case BackendAHT20:
+		return "AHT20"
+	default:
+		return ""
+	}
+}
+
+// Open creates a Reader for cfg using the configured backend.
+// The periph host is initialized on first use.
+// Returns an error if the backend is unknown or the sensor cannot be initialized.
+func Open(cfg *config.SensorConfig, logger *log.Logger) (Reader, error) {
+	switch cfg.Backend {
+	case "", BackendPeriph:
+		if err := initHost(logger); err != nil {
+			return nil, fmt.Errorf("failed to initialize DHT host: %w", err)
+		}
+		s, err := New(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendGPIOCdev:
+		s, err := NewCdev(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendDS18B20:
+		s, err := NewDS18B20(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendBME280:
+		s, err := NewBME280(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendSHT3x:
+		s, err := NewSHT3x(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendAHT20:
+		s, err := NewAHT20(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendExec:
+		s, err := NewExec(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendModbus:
+		s, err := NewModbus(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendIIO:
+		s, err := NewIIO(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown backend '%s' for sensor '%s'", cfg.Backend, cfg.Name)
+	}
+}
+
+// Discover expands sensor entries that stand for several devices into one
+// entry per device found. Currently this is a ds18b20 entry without w1_device,
+// which yields every DS18B20 probe on the 1-Wire bus. Other entries are kept as is.
+func Discover(sensors []config.SensorConfig, logger *log.Logger) ([]config.SensorConfig, error) {
+	return discover(sensors, w1DevicesDir, logger)
+}
+
+// discover is Discover with the 1-Wire devices directory as a parameter.
+func discover(sensors []config.SensorConfig, w1Dir string, logger *log.Logger) ([]config.SensorConfig, error) {
+	expanded := make([]config.SensorConfig, 0, len(sensors))
+	for _, s := range sensors {
+		if s.Backend != BackendDS18B20 || s.W1Device != "" {
+			expanded = append(expanded, s)
+			continue
+		}
+		probes, err := discoverDS18B20(s, w1Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover DS18B20 probes for sensor '%s': %w", s.Name, err)
+		}
+		if len(probes) == 0 {
+			return nil, fmt.Errorf("no DS18B20 probes found in %s for sensor '%s'", w1Dir, s.Name)
+		}
+		for _, p := range probes {
+			logger.WithFields(log.Fields{
+				"sensor": p.Name,
+				"device": p.W1Device,
+			}).Info("Discovered DS18B20 probe")
+		}
+		expanded = append(expanded, probes...)
+	}
+	return expanded, nil
+}
+
 // New creates a new DHT22 sensor reader.
 // Returns an error if the sensor cannot be initialized.
 func New(cfg *config.SensorConfig, logger *log.Logger) (*DHT22Sensor, error) {
@@ -56,36 +248,50 @@ func New(cfg *config.SensorConfig, logger *log.Logger) (*DHT22Sensor, error) {
 		"gpio":   cfg.GPIO,
 	}).Info("Initializing DHT22/AM2302 sensor")
 
-	var client *dht.DHT
-	var temperatureSymbol string
-	var err error
-
-	if cfg.TemperatureUnit == "celsius" {
-		client, err = dht.NewDHT(cfg.GPIO, dht.Celsius, "")
-		temperatureSymbol = CelsiusSymbol
-	} else {
-		client, err = dht.NewDHT(cfg.GPIO, dht.Fahrenheit, "")
-		temperatureSymbol = FahrenheitSymbol
-	}
-
+	// Read in Celsius; temperature_unit is applied by the exporter
+	client, err := dht.NewDHT(cfg.GPIO, dht.Celsius, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create DHT client for sensor '%s': %w", cfg.Name, err)
 	}
 
 	return &DHT22Sensor{
-		name:              cfg.Name,
-		gpio:              cfg.GPIO,
-		maxRetries:        cfg.MaxRetries,
-		temperatureSymbol: temperatureSymbol,
-		client:            client,
-		logger:            logger,
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		maxRetries: cfg.MaxRetries,
+		client:     client,
+		scheduler:  DefaultScheduler,
+		logger:     logger,
 	}, nil
 }
 
 // ReadData reads humidity and temperature from the sensor with retry logic.
-// Returns an error if all retry attempts fail.
-func (s *DHT22Sensor) ReadData() (humidity, temperature float64, err error) {
-	humidity, temperature, err = s.client.ReadRetry(s.maxRetries)
+// Each attempt goes through the scheduler, so that it does not overlap with
+// reads of other sensors. Returns an error if all retry attempts fail.
+func (s *DHT22Sensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		// go-dht also waits between reads and backs off after errors; that wait
+		// holds the scheduler, but the scheduler's own wait has usually covered it.
+		err = s.scheduler.Do(ctx, s.gpio, func() error {
+			var err error
+			humidity, temperature, err = s.client.Read()
+			return err
+		})
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"gpio":    s.gpio,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
 	if err != nil {
 		s.logger.WithFields(log.Fields{
 			"sensor": s.name,
@@ -100,7 +306,7 @@ func (s *DHT22Sensor) ReadData() (humidity, temperature float64, err error) {
 		"gpio":        s.gpio,
 		"humidity":    humidity,
 		"temperature": temperature,
-		"unit":        s.temperatureSymbol,
+		"unit":        CelsiusSymbol,
 	}).Info("Sensor data retrieved")
 
 	return humidity, temperature, nil
@@ -108,7 +314,7 @@ func (s *DHT22Sensor) ReadData() (humidity, temperature float64, err error) {
 
 // TemperatureUnit returns the temperature unit symbol for this sensor.
 func (s *DHT22Sensor) TemperatureUnit() string {
-	return s.temperatureSymbol
+	return CelsiusSymbol
 }
 
 // Name returns the sensor name.
@@ -0,0 +1,158 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// SHT3x commands and timings, see the Sensirion SHT3x-DIS datasheet section 4.
+const (
+	sht3xDefaultAddress = 0x44
+
+	// sht3xMeasureHigh starts a single shot, high repeatability measurement
+	// without clock stretching.
+	sht3xMeasureHigh = 0x2400
+
+	// sht3xMeasureTime is the maximum high repeatability measurement duration.
+	sht3xMeasureTime = 16 * time.Millisecond
+)
+
+// ErrCRC is returned when a sensor response fails its CRC check.
+var ErrCRC = errors.New("sensor CRC check failed")
+
+// sht3xConvert converts raw SHT3x signals to degrees Celsius and percent
+// relative humidity (datasheet section 4.13).
+func sht3xConvert(rawT, rawRH uint16) (temperature, humidity float64) {
+	temperature = -45 + 175*float64(rawT)/65535
+	humidity = 100 * float64(rawRH) / 65535
+	return temperature, humidity
+}
+
+// SHT3xSensor implements the Reader interface for Sensirion SHT30, SHT31
+// and SHT35 temperature and humidity sensors on I2C.
+type SHT3xSensor struct {
+	name       string
+	gpio       string
+	location   string
+	maxRetries int
+	logger     *log.Logger
+
+	mu  sync.Mutex
+	dev i2cConn
+
+	// sleep is replaced in tests.
+	sleep func(time.Duration)
+}
+
+// NewSHT3x opens the SHT3x configured by i2c_bus and i2c_address (default 0x44).
+// Returns an error if the I2C device cannot be opened.
+func NewSHT3x(cfg *config.SensorConfig, logger *log.Logger) (*SHT3xSensor, error) {
+	dev, location, err := openI2C(cfg, sht3xDefaultAddress)
+	if err != nil {
+		return nil, err
+	}
+	return newSHT3x(cfg, logger, dev, location), nil
+}
+
+// newSHT3x creates an SHT3x reader on an open device.
+func newSHT3x(cfg *config.SensorConfig, logger *log.Logger, dev i2cConn, location string) *SHT3xSensor {
+	logger.WithFields(log.Fields{
+		"sensor": cfg.Name,
+		"device": location,
+	}).Info("Initializing SHT3x sensor")
+
+	return &SHT3xSensor{
+		name:       cfg.Name,
+		gpio:       cfg.GPIO,
+		location:   location,
+		maxRetries: cfg.MaxRetries,
+		logger:     logger,
+		dev:        dev,
+		sleep:      time.Sleep,
+	}
+}
+
+// measure performs a single shot measurement and returns the raw signals.
+func (s *SHT3xSensor) measure() (rawT, rawRH uint16, err error) {
+	if err := s.dev.Tx([]byte{sht3xMeasureHigh >> 8, sht3xMeasureHigh & 0xFF}, nil); err != nil {
+		return 0, 0, err
+	}
+	s.sleep(sht3xMeasureTime)
+
+	data := make([]byte, 6)
+	if err := s.dev.Tx(nil, data); err != nil {
+		return 0, 0, err
+	}
+	if crc8(data[0:2]) != data[2] || crc8(data[3:5]) != data[5] {
+		return 0, 0, ErrCRC
+	}
+	return uint16(data[0])<<8 | uint16(data[1]), uint16(data[3])<<8 | uint16(data[4]), nil
+}
+
+// ReadData reads humidity and temperature, retrying up to max_retries times.
+// Returns an error if all attempts fail.
+func (s *SHT3xSensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rawT, rawRH uint16
+	attempts := max(s.maxRetries, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		rawT, rawRH, err = s.measure()
+		if err == nil {
+			break
+		}
+
+		s.logger.WithFields(log.Fields{
+			"sensor":  s.name,
+			"device":  s.location,
+			"attempt": attempt,
+			"error":   err,
+		}).Debug("Sensor read attempt failed")
+	}
+
+	if err != nil {
+		s.logger.WithFields(log.Fields{
+			"sensor": s.name,
+			"device": s.location,
+			"error":  err,
+		}).Error("Failed to read sensor data")
+		return 0, 0, err
+	}
+
+	temperature, humidity = sht3xConvert(rawT, rawRH)
+
+	s.logger.WithFields(log.Fields{
+		"sensor":      s.name,
+		"device":      s.location,
+		"humidity":    humidity,
+		"temperature": temperature,
+		"unit":        CelsiusSymbol,
+	}).Info("Sensor data retrieved")
+
+	return humidity, temperature, nil
+}
+
+// TemperatureUnit returns the temperature unit symbol for this sensor.
+func (s *SHT3xSensor) TemperatureUnit() string {
+	return CelsiusSymbol
+}
+
+// Name returns the sensor name.
+func (s *SHT3xSensor) Name() string {
+	return s.name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *SHT3xSensor) GPIO() string {
+	return s.gpio
+}
@@ -0,0 +1,103 @@
+package sensor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sht3xResponse encodes raw signals as the 6-byte SHT3x measurement response
+func sht3xResponse(rawT, rawRH uint16) []byte {
+	data := []byte{byte(rawT >> 8), byte(rawT), 0, byte(rawRH >> 8), byte(rawRH), 0}
+	data[2] = crc8(data[0:2])
+	data[5] = crc8(data[3:5])
+	return data
+}
+
+func newTestSHT3x(unit string, responses ...[]byte) (*SHT3xSensor, *fakeI2C) {
+	dev := &fakeI2C{responses: responses}
+	s := newSHT3x(testI2CConfig(unit), getSilentLogger(), dev, "/dev/i2c-1@0x44")
+	s.sleep = func(time.Duration) {}
+	return s, dev
+}
+
+func TestSHT3xSensor_ImplementsReader(t *testing.T) {
+	var _ Reader = (*SHT3xSensor)(nil)
+}
+
+// Conversion formulas of the SHT3x-DIS datasheet, section 4.13
+func TestSHT3xConvert(t *testing.T) {
+	tests := []struct {
+		rawT, rawRH           uint16
+		temperature, humidity float64
+	}{
+		{0x0000, 0x0000, -45, 0},
+		{0xFFFF, 0xFFFF, 130, 100},
+		{0x6666, 0xCCCC, 25, 80},
+	}
+
+	for _, tt := range tests {
+		temperature, humidity := sht3xConvert(tt.rawT, tt.rawRH)
+		if temperature != tt.temperature || humidity != tt.humidity {
+			t.Errorf("sht3xConvert(0x%04x, 0x%04x) = %v, %v; want %v, %v",
+				tt.rawT, tt.rawRH, temperature, humidity, tt.temperature, tt.humidity)
+		}
+	}
+}
+
+func TestSHT3xSensor_ReadData(t *testing.T) {
+	s, dev := newTestSHT3x("celsius", sht3xResponse(0x6666, 0xCCCC))
+
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 80 || temperature != 25 {
+		t.Errorf("ReadData() = %v, %v; want 80, 25", humidity, temperature)
+	}
+	if len(dev.writes) != 1 || dev.writes[0][0] != 0x24 || dev.writes[0][1] != 0x00 {
+		t.Errorf("writes = %x, want single shot high repeatability command 2400", dev.writes)
+	}
+}
+
+// Sensors read in Celsius whatever their temperature_unit, which the exporter applies
+func TestSHT3xSensor_ReadsCelsius(t *testing.T) {
+	s, _ := newTestSHT3x("fahrenheit", sht3xResponse(0x6666, 0xCCCC))
+
+	_, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if temperature != 25 {
+		t.Errorf("temperature = %v, want 25", temperature)
+	}
+	if s.TemperatureUnit() != CelsiusSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), CelsiusSymbol)
+	}
+}
+
+// A response with a bad CRC is retried
+func TestSHT3xSensor_CRCRetry(t *testing.T) {
+	bad := sht3xResponse(0x6666, 0xCCCC)
+	bad[5] ^= 0xFF
+	s, _ := newTestSHT3x("celsius", bad, sht3xResponse(0x6666, 0xCCCC))
+
+	humidity, _, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 80 {
+		t.Errorf("humidity = %v, want 80", humidity)
+	}
+}
+
+func TestSHT3xSensor_AllAttemptsFail(t *testing.T) {
+	bad := sht3xResponse(0x6666, 0xCCCC)
+	bad[2] ^= 0xFF
+	s, _ := newTestSHT3x("celsius", bad, bad, bad, sht3xResponse(0x6666, 0xCCCC))
+
+	if _, _, err := s.ReadData(context.Background()); !errors.Is(err, ErrCRC) {
+		t.Errorf("ReadData() error = %v, want ErrCRC", err)
+	}
+}
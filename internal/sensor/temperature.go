@@ -0,0 +1,51 @@
+package sensor
+
+// TemperatureSymbol returns the unit symbol of a temperature_unit setting:
+// "celsius", "kelvin", or Fahrenheit for anything else.
+func TemperatureSymbol(unit string) string {
+	switch unit {
+	case "celsius":
+		return CelsiusSymbol
+	case "kelvin":
+		return KelvinSymbol
+	default:
+		return FahrenheitSymbol
+	}
+}
+
+// ConvertTemperature converts a temperature from the unit of symbol from to
+// the unit of symbol to. Unknown symbols are treated as Celsius.
+func ConvertTemperature(value float64, from, to string) float64 {
+	if from == to {
+		return value
+	}
+	celsius := value
+	switch from {
+	case FahrenheitSymbol:
+		celsius = (value - 32) * 5 / 9
+	case KelvinSymbol:
+		celsius = value - 273.15
+	}
+	switch to {
+	case FahrenheitSymbol:
+		return celsius*9/5 + 32
+	case KelvinSymbol:
+		return celsius + 273.15
+	}
+	return celsius
+}
+
+// ConvertReading returns reading with its temperature converted to the unit
+// of symbol. The measurements of reading are not modified.
+func ConvertReading(reading Reading, symbol string) Reading {
+	measurements := make([]Measurement, len(reading.Measurements))
+	for i, m := range reading.Measurements {
+		if m.Name == QuantityTemperature {
+			m.Value = ConvertTemperature(m.Value, m.Unit, symbol)
+			m.Unit = symbol
+		}
+		measurements[i] = m
+	}
+	reading.Measurements = measurements
+	return reading
+}
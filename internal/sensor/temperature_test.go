@@ -0,0 +1,63 @@
+package sensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTemperatureSymbol(t *testing.T) {
+	tests := []struct {
+		unit string
+		want string
+	}{
+		{"celsius", CelsiusSymbol},
+		{"fahrenheit", FahrenheitSymbol},
+		{"kelvin", KelvinSymbol},
+		{"", FahrenheitSymbol},
+	}
+
+	for _, tt := range tests {
+		if got := TemperatureSymbol(tt.unit); got != tt.want {
+			t.Errorf("TemperatureSymbol(%q) = %q, want %q", tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestConvertTemperature(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{20, CelsiusSymbol, CelsiusSymbol, 20},
+		{20, CelsiusSymbol, FahrenheitSymbol, 68},
+		{20, CelsiusSymbol, KelvinSymbol, 293.15},
+		{-40, FahrenheitSymbol, CelsiusSymbol, -40},
+		{212, FahrenheitSymbol, KelvinSymbol, 373.15},
+		{273.15, KelvinSymbol, FahrenheitSymbol, 32},
+	}
+
+	for _, tt := range tests {
+		if got := ConvertTemperature(tt.value, tt.from, tt.to); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ConvertTemperature(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertReading(t *testing.T) {
+	reading := Reading{Measurements: []Measurement{
+		{Quantity: Quantity{Name: QuantityTemperature, Unit: CelsiusSymbol}, Value: 20},
+		{Quantity: Quantity{Name: QuantityHumidity, Unit: UnitPercent}, Value: 40},
+	}}
+
+	converted := ConvertReading(reading, FahrenheitSymbol)
+	if m := converted.Measurements[0]; m.Value != 68 || m.Unit != FahrenheitSymbol {
+		t.Errorf("temperature = %v %s, want 68 F", m.Value, m.Unit)
+	}
+	if m := converted.Measurements[1]; m.Value != 40 || m.Unit != UnitPercent {
+		t.Errorf("humidity = %v %s, want 40 %%", m.Value, m.Unit)
+	}
+	if reading.Measurements[0].Value != 20 {
+		t.Error("ConvertReading() modified the original reading")
+	}
+}
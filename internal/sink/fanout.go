@@ -0,0 +1,199 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// queue is the bounded buffer and worker state for a single sink.
+type queue struct {
+	name    string
+	sink    Sink
+	policy  string
+	ch      chan Reading
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// Fanout distributes each reading to several sinks.
+// Every sink has its own bounded queue drained by a dedicated goroutine, so a
+// slow or unreachable output cannot delay the poll loop or the other sinks.
+// Fanout itself implements Sink and prometheus.Collector.
+type Fanout struct {
+	logger *log.Logger
+	queues []*queue
+
+	mu       sync.RWMutex
+	closed   bool
+	stopping chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	queuedMetric  *prometheus.Desc
+	writtenMetric *prometheus.Desc
+	droppedMetric *prometheus.Desc
+	failedMetric  *prometheus.Desc
+}
+
+// NewFanout creates an empty Fanout whose metric names are prefixed with
+// namespace. Sinks are added with Add before Start.
+func NewFanout(namespace string, logger *log.Logger) *Fanout {
+	return &Fanout{
+		logger:   logger,
+		stopping: make(chan struct{}),
+		queuedMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "sink_queued_readings"),
+			"Number of readings waiting in the sink queue",
+			[]string{"sink"}, nil,
+		),
+		writtenMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "sink_writes_total"),
+			"Total number of readings successfully written to the sink",
+			[]string{"sink"}, nil,
+		),
+		droppedMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "sink_dropped_readings_total"),
+			"Total number of readings dropped because the sink queue was full",
+			[]string{"sink"}, nil,
+		),
+		failedMetric: prometheus.NewDesc(
+			config.MetricName(namespace, "sink_write_failures_total"),
+			"Total number of readings the sink failed to write",
+			[]string{"sink"}, nil,
+		),
+	}
+}
+
+// Add registers a sink with a queue of the given size and full-queue policy.
+// Must be called before Start.
+func (f *Fanout) Add(name string, s Sink, queueSize int, policy string) {
+	f.queues = append(f.queues, &queue{
+		name:   name,
+		sink:   s,
+		policy: policy,
+		ch:     make(chan Reading, queueSize),
+	})
+}
+
+// Len returns the number of sinks added to the Fanout.
+func (f *Fanout) Len() int {
+	return len(f.queues)
+}
+
+// Start starts every sink and its queue worker.
+// Returns an error if any sink fails to start.
+func (f *Fanout) Start() error {
+	for _, q := range f.queues {
+		if err := q.sink.Start(); err != nil {
+			return fmt.Errorf("failed to start sink '%s': %w", q.name, err)
+		}
+	}
+	for _, q := range f.queues {
+		f.wg.Add(1)
+		go f.drain(q)
+	}
+	return nil
+}
+
+// drain writes queued readings to the sink until the queue is closed.
+func (f *Fanout) drain(q *queue) {
+	defer f.wg.Done()
+	for r := range q.ch {
+		if err := q.sink.Write(r); err != nil {
+			q.failed.Add(1)
+			f.logger.WithFields(log.Fields{
+				"sink":   q.name,
+				"sensor": r.Sensor,
+				"error":  err,
+			}).Warn("Failed to write reading to sink")
+			continue
+		}
+		q.written.Add(1)
+	}
+}
+
+// Write enqueues r for every sink.
+// With the drop policy a full queue discards the reading; with the block policy
+// Write waits for room until the Fanout is closed.
+func (f *Fanout) Write(r Reading) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return errors.New("fanout is closed")
+	}
+
+	for _, q := range f.queues {
+		if q.policy == config.SinkPolicyBlock {
+			select {
+			case q.ch <- r:
+			case <-f.stopping:
+				q.dropped.Add(1)
+			}
+			continue
+		}
+
+		select {
+		case q.ch <- r:
+		default:
+			q.dropped.Add(1)
+			f.logger.WithFields(log.Fields{
+				"sink":   q.name,
+				"sensor": r.Sensor,
+			}).Debug("Sink queue full, dropping reading")
+		}
+	}
+	return nil
+}
+
+// Close stops accepting readings, waits for the queues to drain and closes every sink.
+func (f *Fanout) Close() error {
+	// Release writers blocked on a full queue before taking the write lock.
+	f.stopOnce.Do(func() { close(f.stopping) })
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	for _, q := range f.queues {
+		close(q.ch)
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+
+	var errs []error
+	for _, q := range f.queues {
+		if err := q.sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close sink '%s': %w", q.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Describe sends the descriptors of the sink metrics to the provided channel.
+func (f *Fanout) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.queuedMetric
+	ch <- f.writtenMetric
+	ch <- f.droppedMetric
+	ch <- f.failedMetric
+}
+
+// Collect sends the current queue length and write counters for every sink.
+func (f *Fanout) Collect(ch chan<- prometheus.Metric) {
+	for _, q := range f.queues {
+		ch <- prometheus.MustNewConstMetric(f.queuedMetric, prometheus.GaugeValue, float64(len(q.ch)), q.name)
+		ch <- prometheus.MustNewConstMetric(f.writtenMetric, prometheus.CounterValue, float64(q.written.Load()), q.name)
+		ch <- prometheus.MustNewConstMetric(f.droppedMetric, prometheus.CounterValue, float64(q.dropped.Load()), q.name)
+		ch <- prometheus.MustNewConstMetric(f.failedMetric, prometheus.CounterValue, float64(q.failed.Load()), q.name)
+	}
+}
@@ -0,0 +1,264 @@
+package sink
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// fakeSink records readings and can be made to block or fail
+type fakeSink struct {
+	mu       sync.Mutex
+	readings []Reading
+	started  bool
+	closed   bool
+	startErr error
+	writeErr error
+	release  chan struct{} // when set, Write waits until it is closed
+}
+
+func (s *fakeSink) Start() error {
+	s.started = true
+	return s.startErr
+}
+
+func (s *fakeSink) Write(r Reading) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readings = append(s.readings, r)
+	return s.writeErr
+}
+
+func (s *fakeSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *fakeSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.readings)
+}
+
+// collectCounters gathers the fanout metrics keyed by "<metric>/<sink>"
+func collectCounters(t *testing.T, f *Fanout) map[string]float64 {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(f)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			value := m.GetGauge().GetValue()
+			if mf.GetType() == dto.MetricType_COUNTER {
+				value = m.GetCounter().GetValue()
+			}
+			values[mf.GetName()+"/"+m.GetLabel()[0].GetValue()] = value
+		}
+	}
+	return values
+}
+
+func TestFanout_DeliversToAllSinks(t *testing.T) {
+	a, b := &fakeSink{}, &fakeSink{}
+	f := NewFanout("", getSilentLogger())
+	f.Add("a", a, 10, config.SinkPolicyDrop)
+	f.Add("b", b, 10, config.SinkPolicyBlock)
+
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	if !a.started || !b.started {
+		t.Error("Start() did not start every sink")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := f.Write(testReading()); err != nil {
+			t.Fatalf("Write() returned unexpected error: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+
+	// Close drains the queues before closing the sinks
+	if a.count() != 3 || b.count() != 3 {
+		t.Errorf("sinks received %d and %d readings, want 3 each", a.count(), b.count())
+	}
+	if !a.closed || !b.closed {
+		t.Error("Close() did not close every sink")
+	}
+
+	counters := collectCounters(t, f)
+	if counters["dht_sink_writes_total/a"] != 3 {
+		t.Errorf("dht_sink_writes_total{sink=a} = %v, want 3", counters["dht_sink_writes_total/a"])
+	}
+}
+
+func TestFanout_StartError(t *testing.T) {
+	f := NewFanout("", getSilentLogger())
+	f.Add("broken", &fakeSink{startErr: errors.New("no route")}, 10, config.SinkPolicyDrop)
+
+	if err := f.Start(); err == nil {
+		t.Error("Start() expected error, got nil")
+	}
+}
+
+// A stuck sink with the drop policy must not block the writer or the other sinks
+func TestFanout_DropPolicy(t *testing.T) {
+	stuck := &fakeSink{release: make(chan struct{})}
+	healthy := &fakeSink{}
+	f := NewFanout("", getSilentLogger())
+	f.Add("stuck", stuck, 2, config.SinkPolicyDrop)
+	f.Add("healthy", healthy, 10, config.SinkPolicyDrop)
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+
+	// One reading is held by the worker, two fill the queue, the rest are dropped
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 6; i++ {
+			f.Write(testReading())
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write() blocked with drop policy")
+	}
+
+	counters := collectCounters(t, f)
+	if counters["dht_sink_dropped_readings_total/stuck"] != 3 {
+		t.Errorf("dht_sink_dropped_readings_total{sink=stuck} = %v, want 3", counters["dht_sink_dropped_readings_total/stuck"])
+	}
+	if counters["dht_sink_queued_readings/stuck"] != 2 {
+		t.Errorf("dht_sink_queued_readings{sink=stuck} = %v, want 2", counters["dht_sink_queued_readings/stuck"])
+	}
+	if counters["dht_sink_dropped_readings_total/healthy"] != 0 {
+		t.Errorf("dht_sink_dropped_readings_total{sink=healthy} = %v, want 0", counters["dht_sink_dropped_readings_total/healthy"])
+	}
+
+	close(stuck.release)
+	f.Close()
+	if healthy.count() != 6 {
+		t.Errorf("healthy sink received %d readings, want 6", healthy.count())
+	}
+}
+
+func TestFanout_BlockPolicy(t *testing.T) {
+	stuck := &fakeSink{release: make(chan struct{})}
+	f := NewFanout("", getSilentLogger())
+	f.Add("stuck", stuck, 1, config.SinkPolicyBlock)
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+
+	f.Write(testReading()) // picked up by the worker
+	time.Sleep(20 * time.Millisecond)
+	f.Write(testReading()) // fills the queue
+
+	blocked := make(chan struct{})
+	go func() {
+		f.Write(testReading())
+		close(blocked)
+	}()
+
+	select {
+	case <-blocked:
+		t.Fatal("Write() did not block on a full queue with block policy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(stuck.release)
+	select {
+	case <-blocked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write() still blocked after the sink caught up")
+	}
+
+	f.Close()
+	if stuck.count() != 3 {
+		t.Errorf("sink received %d readings, want 3", stuck.count())
+	}
+}
+
+// Close must release writers blocked on a full queue
+func TestFanout_CloseUnblocksWriters(t *testing.T) {
+	stuck := &fakeSink{release: make(chan struct{})}
+	f := NewFanout("", getSilentLogger())
+	f.Add("stuck", stuck, 1, config.SinkPolicyBlock)
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+
+	f.Write(testReading())
+	time.Sleep(20 * time.Millisecond)
+	f.Write(testReading())
+
+	blocked := make(chan struct{})
+	go func() {
+		f.Write(testReading())
+		close(blocked)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		f.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-blocked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() did not release blocked writer")
+	}
+
+	close(stuck.release)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() did not return")
+	}
+
+	if err := f.Write(testReading()); err == nil {
+		t.Error("Write() after Close() expected error, got nil")
+	}
+}
+
+func TestFanout_CountsFailures(t *testing.T) {
+	failing := &fakeSink{writeErr: errors.New("connection refused")}
+	f := NewFanout("", getSilentLogger())
+	f.Add("failing", failing, 10, config.SinkPolicyDrop)
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+
+	f.Write(testReading())
+	f.Write(testReading())
+	f.Close()
+
+	counters := collectCounters(t, f)
+	if counters["dht_sink_write_failures_total/failing"] != 2 {
+		t.Errorf("dht_sink_write_failures_total{sink=failing} = %v, want 2", counters["dht_sink_write_failures_total/failing"])
+	}
+	if counters["dht_sink_writes_total/failing"] != 0 {
+		t.Errorf("dht_sink_writes_total{sink=failing} = %v, want 0", counters["dht_sink_writes_total/failing"])
+	}
+}
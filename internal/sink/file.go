@@ -0,0 +1,480 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	// FormatCSV writes one comma-separated line per reading with a header row.
+	FormatCSV = "csv"
+	// FormatJSONLines writes one JSON object per line.
+	FormatJSONLines = "jsonl"
+
+	// defaultSyncInterval is how often buffered lines are fsynced when sync_interval is not set.
+	defaultSyncInterval = 10 * time.Second
+
+	// rotatedTimeFormat is inserted into rotated file names; it sorts chronologically.
+	rotatedTimeFormat = "20060102T150405"
+)
+
+// csvHeader lists the columns written by the CSV format.
+var csvHeader = []string{"timestamp", "sensor", "gpio", "temperature", "unit", "humidity", "status"}
+
+// fileRecord is the JSON Lines representation of a reading.
+// Values are null for failed readings, and humidity for sensors without one.
+type fileRecord struct {
+	Timestamp   string   `json:"timestamp"`
+	Sensor      string   `json:"sensor"`
+	GPIO        string   `json:"gpio"`
+	Temperature *float64 `json:"temperature"`
+	Unit        string   `json:"unit"`
+	Humidity    *float64 `json:"humidity"`
+	Status      string   `json:"status"`
+}
+
+// File archives every reading, including failed ones, to a local file.
+// Lines are buffered and fsynced every sync interval to limit SD card wear.
+// The file is rotated by size and/or age, rotated files can be gzipped and
+// only the newest MaxFiles rotated files are kept.
+type File struct {
+	path           string
+	format         string
+	maxSize        int64
+	rotateInterval time.Duration
+	compress       bool
+	maxFiles       int
+	syncInterval   time.Duration
+	logger         *log.Logger
+	timeNow        func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	buf      *bufio.Writer
+	size     int64
+	openedAt time.Time
+	dirty    bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFile creates a file sink.
+// Returns an error if the path is missing or the format is unknown.
+func NewFile(cfg *config.SinkConfig, logger *log.Logger) (*File, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("file sink requires a path")
+	}
+
+	format := cfg.Format
+	switch format {
+	case "":
+		format = FormatCSV
+	case FormatCSV, FormatJSONLines:
+	default:
+		return nil, fmt.Errorf("unknown file format '%s' (want %s or %s)", cfg.Format, FormatCSV, FormatJSONLines)
+	}
+	if cfg.MaxFiles < 0 {
+		return nil, fmt.Errorf("file sink max_files must not be negative")
+	}
+
+	syncInterval := cfg.SyncInterval
+	if syncInterval == 0 {
+		syncInterval = defaultSyncInterval
+	}
+
+	logger.WithFields(log.Fields{
+		"path":   cfg.Path,
+		"format": format,
+	}).Info("Initializing file sink")
+
+	return &File{
+		path:           cfg.Path,
+		format:         format,
+		maxSize:        int64(cfg.MaxSizeMB) * 1024 * 1024,
+		rotateInterval: cfg.RotateInterval,
+		compress:       cfg.Compress,
+		maxFiles:       cfg.MaxFiles,
+		syncInterval:   syncInterval,
+		logger:         logger,
+		timeNow:        time.Now,
+	}, nil
+}
+
+// Start opens the file for appending and starts the periodic sync.
+// Returns an error if the file cannot be opened.
+func (f *File) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+	go f.syncLoop()
+	return nil
+}
+
+// syncLoop flushes and fsyncs buffered lines every sync interval.
+func (f *File) syncLoop() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			if err := f.sync(); err != nil {
+				f.logger.WithFields(log.Fields{
+					"path":  f.path,
+					"error": err,
+				}).Warn("Failed to sync file sink")
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// Write appends r to the file, rotating it first if it is due.
+func (f *File) Write(r Reading) error {
+	line, err := f.encode(r)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return fmt.Errorf("file sink %s not started", f.path)
+	}
+
+	if f.rotationDue(int64(len(line))) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.buf.Write(line)
+	f.size += int64(n)
+	f.dirty = true
+	if err != nil {
+		return fmt.Errorf("failed to write to %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Close flushes, fsyncs and closes the file.
+func (f *File) Close() error {
+	if f.stop != nil {
+		close(f.stop)
+		<-f.done
+		f.stop = nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closeFile()
+}
+
+// encode renders r as a single line in the configured format.
+func (f *File) encode(r Reading) ([]byte, error) {
+	status := "ok"
+	if r.Err != nil {
+		status = "error"
+	}
+	timestamp := r.Time.UTC().Format(time.RFC3339Nano)
+
+	if f.format == FormatJSONLines {
+		rec := fileRecord{
+			Timestamp: timestamp,
+			Sensor:    r.Sensor,
+			GPIO:      r.GPIO,
+			Unit:      r.Unit,
+			Status:    status,
+		}
+		if r.Err == nil {
+			rec.Temperature = &r.Temperature
+			if !math.IsNaN(r.Humidity) {
+				rec.Humidity = &r.Humidity
+			}
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode reading: %w", err)
+		}
+		return append(line, '\n'), nil
+	}
+
+	temperature, humidity := "", ""
+	if r.Err == nil {
+		temperature = strconv.FormatFloat(r.Temperature, 'f', -1, 64)
+		if !math.IsNaN(r.Humidity) {
+			humidity = strconv.FormatFloat(r.Humidity, 'f', -1, 64)
+		}
+	}
+	return encodeCSV([]string{timestamp, r.Sensor, r.GPIO, temperature, r.Unit, humidity, status})
+}
+
+// encodeCSV renders a single CSV record, quoting fields as needed.
+func encodeCSV(record []string) ([]byte, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if err := w.Write(record); err != nil {
+		return nil, fmt.Errorf("failed to encode reading: %w", err)
+	}
+	w.Flush()
+	return []byte(b.String()), w.Error()
+}
+
+// open opens the active file for appending, writing the CSV header to new files.
+// Must be called with f.mu held.
+func (f *File) open() error {
+	if dir := filepath.Dir(f.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", f.path, err)
+		}
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	f.file = file
+	f.buf = bufio.NewWriter(file)
+	f.size = info.Size()
+	f.openedAt = f.timeNow()
+	f.dirty = false
+
+	if f.size == 0 && f.format == FormatCSV {
+		header, err := encodeCSV(csvHeader)
+		if err != nil {
+			return err
+		}
+		n, err := f.buf.Write(header)
+		f.size += int64(n)
+		f.dirty = true
+		if err != nil {
+			return fmt.Errorf("failed to write header to %s: %w", f.path, err)
+		}
+	}
+	return nil
+}
+
+// sync flushes buffered lines and fsyncs the file if anything was written.
+// Must be called with f.mu held.
+func (f *File) sync() error {
+	if f.file == nil || !f.dirty {
+		return nil
+	}
+	if err := f.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush %s: %w", f.path, err)
+	}
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("failed to fsync %s: %w", f.path, err)
+	}
+	f.dirty = false
+	return nil
+}
+
+// closeFile syncs and closes the active file.
+// Must be called with f.mu held.
+func (f *File) closeFile() error {
+	if f.file == nil {
+		return nil
+	}
+	syncErr := f.sync()
+	closeErr := f.file.Close()
+	f.file = nil
+	f.buf = nil
+	return errors.Join(syncErr, closeErr)
+}
+
+// rotationDue reports whether writing n more bytes requires rotating first.
+// Must be called with f.mu held.
+func (f *File) rotationDue(n int64) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		return true
+	}
+	if f.rotateInterval > 0 && f.timeNow().Sub(f.openedAt) >= f.rotateInterval {
+		return true
+	}
+	return false
+}
+
+// rotate closes the active file, renames it with a timestamp suffix,
+// optionally compresses it, prunes old files and opens a fresh file.
+// Must be called with f.mu held.
+func (f *File) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+
+	rotated := f.rotatedName(f.timeNow())
+	if err := os.Rename(f.path, rotated); err != nil {
+		// Keep appending to the current file rather than losing readings.
+		if openErr := f.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+	}
+	f.logger.WithField("file", rotated).Info("Rotated reading log")
+
+	if f.compress {
+		if err := gzipFile(rotated); err != nil {
+			f.logger.WithFields(log.Fields{
+				"file":  rotated,
+				"error": err,
+			}).Warn("Failed to compress rotated file")
+		}
+	}
+	if f.maxFiles > 0 {
+		f.prune()
+	}
+
+	return f.open()
+}
+
+// rotatedName returns the name of a rotated file, e.g. "readings-20260101T120000.csv".
+// A counter is appended if a file rotated in the same second already exists.
+func (f *File) rotatedName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	stamp := t.UTC().Format(rotatedTimeFormat)
+
+	name := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, stamp, i, ext)
+	}
+	return name
+}
+
+// rotatedFile is a rotated file found on disk, ordered by the timestamp and
+// counter of its name.
+type rotatedFile struct {
+	path  string
+	stamp string
+	index int
+}
+
+// rotatedFiles returns the files named by rotatedName for the path of f,
+// compressed or not, from the oldest to the newest.
+func (f *File) rotatedFiles() ([]rotatedFile, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(filepath.Base(f.path), ext)
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(base) + `-(\d{8}T\d{6})(?:\.(\d+))?` + regexp.QuoteMeta(ext) + `(?:\.gz)?$`)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var rotated []rotatedFile
+	for _, e := range entries {
+		m := pattern.FindStringSubmatch(e.Name())
+		if m == nil || e.IsDir() {
+			continue
+		}
+		index := 0
+		if m[2] != "" {
+			if index, err = strconv.Atoi(m[2]); err != nil {
+				continue
+			}
+		}
+		rotated = append(rotated, rotatedFile{path: filepath.Join(dir, e.Name()), stamp: m[1], index: index})
+	}
+
+	// The timestamp sorts chronologically; files rotated in the same second
+	// are ordered by their counter, the unsuffixed one first.
+	sort.Slice(rotated, func(i, j int) bool {
+		if rotated[i].stamp != rotated[j].stamp {
+			return rotated[i].stamp < rotated[j].stamp
+		}
+		return rotated[i].index < rotated[j].index
+	})
+	return rotated, nil
+}
+
+// prune removes the oldest rotated files beyond maxFiles.
+// Must be called with f.mu held.
+func (f *File) prune() {
+	rotated, err := f.rotatedFiles()
+	if err != nil {
+		f.logger.WithError(err).Warn("Failed to list rotated files")
+		return
+	}
+	if len(rotated) <= f.maxFiles {
+		return
+	}
+
+	for _, old := range rotated[:len(rotated)-f.maxFiles] {
+		if err := os.Remove(old.path); err != nil {
+			f.logger.WithFields(log.Fields{
+				"file":  old.path,
+				"error": err,
+			}).Warn("Failed to remove old rotated file")
+			continue
+		}
+		f.logger.WithField("file", old.path).Debug("Removed old rotated file")
+	}
+}
+
+// gzipFile compresses path to path.gz and removes the original.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, copyErr := io.Copy(zw, src)
+	closeErr := zw.Close()
+	syncErr := dst.Sync()
+	dstErr := dst.Close()
+	if err := errors.Join(copyErr, closeErr, syncErr, dstErr); err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
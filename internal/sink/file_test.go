@@ -0,0 +1,353 @@
+package sink
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// startFile creates and starts a file sink in a temp directory
+func startFile(t *testing.T, cfg config.SinkConfig) (*File, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(dir, "readings.csv")
+	}
+	f, err := NewFile(&cfg, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewFile() returned unexpected error: %v", err)
+	}
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	return f, filepath.Dir(cfg.Path)
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestNewFile_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SinkConfig
+	}{
+		{"missing path", config.SinkConfig{}},
+		{"unknown format", config.SinkConfig{Path: "x.log", Format: "xml"}},
+		{"negative max files", config.SinkConfig{Path: "x.log", MaxFiles: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFile(&tt.cfg, getSilentLogger()); err == nil {
+				t.Errorf("NewFile() expected error for %s, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestFile_CSV(t *testing.T) {
+	f, dir := startFile(t, config.SinkConfig{})
+
+	failed := testReading()
+	failed.Err = errors.New("checksum error")
+
+	if err := f.Write(testReading()); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+	if err := f.Write(failed); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+
+	want := []string{
+		"timestamp,sensor,gpio,temperature,unit,humidity,status",
+		"2023-11-14T22:13:20Z,living-room,GPIO4,21.5,C,45.2,ok",
+		"2023-11-14T22:13:20Z,living-room,GPIO4,,C,,error",
+	}
+	got := readLines(t, filepath.Join(dir, "readings.csv"))
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("file content =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// Appending to an existing file must not repeat the CSV header
+func TestFile_CSVAppend(t *testing.T) {
+	f, dir := startFile(t, config.SinkConfig{})
+	f.Write(testReading())
+	f.Close()
+
+	f2, err := NewFile(&config.SinkConfig{Path: filepath.Join(dir, "readings.csv")}, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewFile() returned unexpected error: %v", err)
+	}
+	if err := f2.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	f2.Write(testReading())
+	f2.Close()
+
+	lines := readLines(t, filepath.Join(dir, "readings.csv"))
+	if len(lines) != 3 {
+		t.Errorf("file has %d lines, want 3 (header and two readings)", len(lines))
+	}
+}
+
+func TestFile_JSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "readings.jsonl")
+	f, _ := startFile(t, config.SinkConfig{Path: path, Format: FormatJSONLines})
+
+	failed := testReading()
+	failed.Err = errors.New("timeout")
+	f.Write(testReading())
+	f.Write(failed)
+	f.Close()
+
+	lines := readLines(t, path)
+	if len(lines) != 2 {
+		t.Fatalf("file has %d lines, want 2", len(lines))
+	}
+
+	var ok map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &ok); err != nil {
+		t.Fatalf("Failed to parse line %q: %v", lines[0], err)
+	}
+	if ok["temperature"] != 21.5 || ok["humidity"] != 45.2 || ok["status"] != "ok" || ok["gpio"] != "GPIO4" {
+		t.Errorf("unexpected record: %v", ok)
+	}
+
+	var bad map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &bad); err != nil {
+		t.Fatalf("Failed to parse line %q: %v", lines[1], err)
+	}
+	if bad["temperature"] != nil || bad["humidity"] != nil || bad["status"] != "error" {
+		t.Errorf("unexpected record for failed reading: %v", bad)
+	}
+}
+
+func TestFile_TemperatureOnly(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSONLines} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "readings."+format)
+			f, _ := startFile(t, config.SinkConfig{Path: path, Format: format})
+
+			r := testReading()
+			r.Humidity = math.NaN()
+			if err := f.Write(r); err != nil {
+				t.Fatalf("Write() returned unexpected error: %v", err)
+			}
+			f.Close()
+
+			lines := readLines(t, path)
+			line := lines[len(lines)-1]
+			want := "2023-11-14T22:13:20Z,living-room,GPIO4,21.5,C,,ok"
+			if format == FormatJSONLines {
+				want = `{"timestamp":"2023-11-14T22:13:20Z","sensor":"living-room","gpio":"GPIO4","temperature":21.5,"unit":"C","humidity":null,"status":"ok"}`
+			}
+			if line != want {
+				t.Errorf("line = %q, want %q", line, want)
+			}
+		})
+	}
+}
+
+func TestFile_PeriodicSync(t *testing.T) {
+	f, dir := startFile(t, config.SinkConfig{SyncInterval: 10 * time.Millisecond})
+	defer f.Close()
+
+	f.Write(testReading())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, _ := os.ReadFile(filepath.Join(dir, "readings.csv"))
+		if strings.Contains(string(data), "living-room") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reading was not synced to disk")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFile_SizeRotationWithCompressionAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "readings.jsonl")
+	f, err := NewFile(&config.SinkConfig{
+		Path:      path,
+		Format:    FormatJSONLines,
+		MaxSizeMB: 1,
+		Compress:  true,
+		MaxFiles:  2,
+	}, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewFile() returned unexpected error: %v", err)
+	}
+	// Shrink the limit so a couple of lines trigger a rotation
+	f.maxSize = 200
+
+	now := time.Unix(1700000000, 0)
+	f.timeNow = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+
+	for i := 0; i < 12; i++ {
+		if err := f.Write(testReading()); err != nil {
+			t.Fatalf("Write() returned unexpected error: %v", err)
+		}
+	}
+	f.Close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "readings-*.jsonl.gz"))
+	if len(rotated) != 2 {
+		t.Fatalf("found %d rotated files %v, want 2", len(rotated), rotated)
+	}
+	if plain, _ := filepath.Glob(filepath.Join(dir, "readings-*.jsonl")); len(plain) != 0 {
+		t.Errorf("found uncompressed rotated files %v", plain)
+	}
+
+	// Rotated files must be valid gzip containing complete lines
+	file, err := os.Open(rotated[0])
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", rotated[0], err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Failed to read gzip %s: %v", rotated[0], err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Failed to decompress %s: %v", rotated[0], err)
+	}
+	if !strings.HasSuffix(string(data), "}\n") || !strings.Contains(string(data), "living-room") {
+		t.Errorf("unexpected rotated content %q", data)
+	}
+
+	// The active file stays below the limit
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat active file: %v", err)
+	}
+	if info.Size() > 200 {
+		t.Errorf("active file size = %d, want <= 200", info.Size())
+	}
+}
+
+func TestFile_TimeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "readings.csv")
+	f, err := NewFile(&config.SinkConfig{Path: path, RotateInterval: time.Hour}, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewFile() returned unexpected error: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	f.timeNow = func() time.Time { return now }
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+
+	f.Write(testReading())
+	now = now.Add(30 * time.Minute)
+	f.Write(testReading())
+	now = now.Add(30 * time.Minute)
+	f.Write(testReading())
+	f.Close()
+
+	rotatedPath := filepath.Join(dir, "readings-20260101T130000.csv")
+	rotated := readLines(t, rotatedPath)
+	if len(rotated) != 3 {
+		t.Errorf("rotated file has %d lines, want 3 (header and two readings)", len(rotated))
+	}
+	active := readLines(t, path)
+	if len(active) != 2 || active[0] != strings.Join(csvHeader, ",") {
+		t.Errorf("active file = %v, want header and one reading", active)
+	}
+}
+
+// touch creates empty files named names in dir.
+func touch(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o640); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+// listDir returns the sorted names of the files in dir.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestFile_PruneWithoutExtension(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFile(&config.SinkConfig{Path: filepath.Join(dir, "readings"), MaxFiles: 1}, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewFile() returned unexpected error: %v", err)
+	}
+	touch(t, dir,
+		"readings-20260101T120000",
+		"readings-20260101T130000.gz",
+		"readings-backup.csv",
+		"readings-old",
+		"readings-20260101T110000.csv",
+	)
+
+	f.prune()
+
+	want := []string{"readings-20260101T110000.csv", "readings-20260101T130000.gz", "readings-backup.csv", "readings-old"}
+	if got := listDir(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("files after prune = %v, want %v", got, want)
+	}
+}
+
+func TestFile_PruneSameSecondRotations(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFile(&config.SinkConfig{Path: filepath.Join(dir, "readings.csv"), MaxFiles: 2}, getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewFile() returned unexpected error: %v", err)
+	}
+	touch(t, dir,
+		"readings-20260101T120000.csv",
+		"readings-20260101T120000.1.csv",
+		"readings-20260101T120000.2.csv.gz",
+		"readings-20260101T115959.10.csv",
+	)
+
+	f.prune()
+
+	want := []string{"readings-20260101T120000.1.csv", "readings-20260101T120000.2.csv.gz"}
+	if got := listDir(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("files after prune = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+const (
+	graphiteDialTimeout  = 5 * time.Second
+	graphiteWriteTimeout = 5 * time.Second
+	graphiteMinBackoff   = time.Second
+	graphiteMaxBackoff   = time.Minute
+)
+
+// Graphite sends readings to a Carbon server using the plaintext protocol
+// ("<path> <value> <timestamp>\n") over TCP.
+// The connection is established lazily and re-established with exponential
+// backoff after a failure.
+type Graphite struct {
+	address  string
+	prefix   string
+	hostname string
+	template *Template
+	logger   *log.Logger
+
+	mu       sync.Mutex
+	conn     net.Conn
+	backoff  time.Duration
+	nextDial time.Time
+	dialer   net.Dialer
+	timeNow  func() time.Time
+}
+
+// NewGraphite creates a Graphite plaintext sink.
+// Returns an error if the address is missing or the template is invalid.
+func NewGraphite(cfg *config.SinkConfig, hostname string, logger *log.Logger) (*Graphite, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("graphite sink requires an address")
+	}
+	tmpl, err := ParseTemplate(cfg.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid graphite template: %w", err)
+	}
+
+	logger.WithField("address", cfg.Address).Info("Initializing Graphite sink")
+
+	return &Graphite{
+		address:  cfg.Address,
+		prefix:   cfg.Prefix,
+		hostname: hostname,
+		template: tmpl,
+		logger:   logger,
+		dialer:   net.Dialer{Timeout: graphiteDialTimeout},
+		timeNow:  time.Now,
+	}, nil
+}
+
+// Start attempts the initial connection to Carbon.
+// A failure is only logged since the connection is retried on each write.
+func (g *Graphite) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.connect(); err != nil {
+		g.logger.WithError(err).Warn("Graphite unavailable, will retry on next write")
+	}
+	return nil
+}
+
+// Write sends the temperature and humidity of r to Carbon.
+// Failed readings are skipped. If the connection was dropped the write is
+// retried once on a fresh connection.
+func (g *Graphite) Write(r Reading) error {
+	if r.Err != nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	ts := strconv.FormatInt(r.Time.Unix(), 10)
+	for _, s := range g.template.samples(r, g.prefix, g.hostname) {
+		buf.WriteString(s.path)
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(s.value, 'f', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(ts)
+		buf.WriteByte('\n')
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reused := g.conn != nil
+	err := g.send(buf.Bytes())
+	if err != nil && reused {
+		// The server may have closed an idle connection; try once more.
+		err = g.send(buf.Bytes())
+	}
+	return err
+}
+
+// send writes payload on the current connection, dialing if necessary.
+// Must be called with g.mu held.
+func (g *Graphite) send(payload []byte) error {
+	if g.conn == nil {
+		if err := g.connect(); err != nil {
+			return err
+		}
+	}
+
+	if err := g.conn.SetWriteDeadline(g.timeNow().Add(graphiteWriteTimeout)); err != nil {
+		g.disconnect()
+		return fmt.Errorf("failed to set graphite write deadline: %w", err)
+	}
+	if _, err := g.conn.Write(payload); err != nil {
+		g.logger.WithFields(log.Fields{
+			"address": g.address,
+			"error":   err,
+		}).Warn("Graphite write failed, dropping connection")
+		g.disconnect()
+		return fmt.Errorf("failed to write to graphite %s: %w", g.address, err)
+	}
+	return nil
+}
+
+// connect dials the Carbon server unless still backing off from a previous failure.
+// Must be called with g.mu held.
+func (g *Graphite) connect() error {
+	now := g.timeNow()
+	if now.Before(g.nextDial) {
+		return fmt.Errorf("graphite %s unavailable, next reconnect in %s", g.address, g.nextDial.Sub(now).Round(time.Second))
+	}
+
+	conn, err := g.dialer.Dial("tcp", g.address)
+	if err != nil {
+		if g.backoff == 0 {
+			g.backoff = graphiteMinBackoff
+		} else {
+			g.backoff = min(2*g.backoff, graphiteMaxBackoff)
+		}
+		g.nextDial = now.Add(g.backoff)
+		return fmt.Errorf("failed to connect to graphite %s: %w", g.address, err)
+	}
+
+	g.logger.WithField("address", g.address).Debug("Connected to Graphite")
+	g.conn = conn
+	g.backoff = 0
+	g.nextDial = time.Time{}
+	return nil
+}
+
+// disconnect closes and forgets the current connection.
+// Must be called with g.mu held.
+func (g *Graphite) disconnect() {
+	if g.conn != nil {
+		_ = g.conn.Close()
+		g.conn = nil
+	}
+}
+
+// Close closes the connection to the Carbon server.
+func (g *Graphite) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.disconnect()
+	return nil
+}
@@ -0,0 +1,192 @@
+package sink
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// startCarbon starts a TCP listener that forwards every received line to the returned channel
+func startCarbon(t *testing.T) (net.Listener, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return ln, lines
+}
+
+func receiveLine(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for line")
+		return ""
+	}
+}
+
+func TestGraphite_Write(t *testing.T) {
+	ln, lines := startCarbon(t)
+	defer ln.Close()
+
+	g, err := NewGraphite(&config.SinkConfig{Address: ln.Addr().String(), Prefix: "dht"}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewGraphite() returned unexpected error: %v", err)
+	}
+	defer g.Close()
+
+	if err := g.Write(testReading()); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"dht.pi.living-room.temperature 21.5 1700000000",
+		"dht.pi.living-room.humidity 45.2 1700000000",
+	}
+	for _, want := range expected {
+		if got := receiveLine(t, lines); got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestGraphite_TemperatureOnly(t *testing.T) {
+	ln, lines := startCarbon(t)
+	defer ln.Close()
+
+	g, err := NewGraphite(&config.SinkConfig{Address: ln.Addr().String(), Prefix: "dht"}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewGraphite() returned unexpected error: %v", err)
+	}
+	defer g.Close()
+
+	r := testReading()
+	r.Humidity = math.NaN()
+	if err := g.Write(r); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+	if err := g.Write(testReading()); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+
+	// The humidity line of the first reading is skipped
+	expected := []string{
+		"dht.pi.living-room.temperature 21.5 1700000000",
+		"dht.pi.living-room.temperature 21.5 1700000000",
+		"dht.pi.living-room.humidity 45.2 1700000000",
+	}
+	for _, want := range expected {
+		if got := receiveLine(t, lines); got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestGraphite_SkipsFailedReadings(t *testing.T) {
+	g, err := NewGraphite(&config.SinkConfig{Address: "127.0.0.1:1"}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewGraphite() returned unexpected error: %v", err)
+	}
+	defer g.Close()
+
+	r := testReading()
+	r.Err = errors.New("checksum error")
+
+	// No connection is attempted for a failed reading, so this must not error
+	if err := g.Write(r); err != nil {
+		t.Errorf("Write() returned unexpected error for failed reading: %v", err)
+	}
+}
+
+func TestGraphite_Reconnect(t *testing.T) {
+	ln, lines := startCarbon(t)
+	addr := ln.Addr().String()
+
+	g, err := NewGraphite(&config.SinkConfig{Address: addr}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewGraphite() returned unexpected error: %v", err)
+	}
+	defer g.Close()
+
+	if err := g.Write(testReading()); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+	receiveLine(t, lines)
+	receiveLine(t, lines)
+
+	// Simulate a server restart by dropping the active connection
+	g.mu.Lock()
+	g.conn.Close()
+	g.mu.Unlock()
+
+	if err := g.Write(testReading()); err != nil {
+		t.Fatalf("Write() after connection loss returned unexpected error: %v", err)
+	}
+	if got := receiveLine(t, lines); got != "pi.living-room.temperature 21.5 1700000000" {
+		t.Errorf("received %q after reconnect", got)
+	}
+}
+
+func TestGraphite_Backoff(t *testing.T) {
+	// Reserve a port and close it so connections are refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	g, err := NewGraphite(&config.SinkConfig{Address: addr}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewGraphite() returned unexpected error: %v", err)
+	}
+	defer g.Close()
+
+	now := time.Unix(1700000000, 0)
+	g.timeNow = func() time.Time { return now }
+
+	if err := g.Write(testReading()); err == nil {
+		t.Fatal("Write() expected error for refused connection, got nil")
+	}
+	if g.backoff != graphiteMinBackoff {
+		t.Errorf("backoff = %v, want %v", g.backoff, graphiteMinBackoff)
+	}
+
+	// Within the backoff window no dial is attempted
+	if err := g.Write(testReading()); err == nil {
+		t.Error("Write() expected error during backoff, got nil")
+	}
+	if g.backoff != graphiteMinBackoff {
+		t.Errorf("backoff changed during backoff window: %v", g.backoff)
+	}
+
+	// After the window the dial is retried and the backoff doubles
+	now = now.Add(graphiteMinBackoff)
+	_ = g.Write(testReading())
+	if g.backoff != 2*graphiteMinBackoff {
+		t.Errorf("backoff = %v, want %v", g.backoff, 2*graphiteMinBackoff)
+	}
+}
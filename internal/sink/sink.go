@@ -0,0 +1,59 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// Reading is the result of polling a single sensor once.
+// Err is set when the read failed, in which case the values are zero.
+// Humidity is NaN for sensors without a humidity sensor.
+type Reading struct {
+	Time        time.Time
+	Sensor      string
+	GPIO        string
+	Temperature float64
+	Humidity    float64
+	Unit        string
+	Err         error
+}
+
+// Sink receives sensor readings on each poll, outside of the Prometheus registry.
+// New outputs only need to implement this interface and be added to New;
+// buffering and isolation from slow outputs is handled by Fanout.
+type Sink interface {
+	// Start prepares the output (e.g. opens connections) before the first Write.
+	Start() error
+
+	// Write delivers a single reading to the output.
+	Write(r Reading) error
+
+	// Close releases any resources held by the sink.
+	Close() error
+}
+
+// New creates the sink described by cfg.
+// Returns an error if the sink type is unknown or its configuration is invalid.
+func New(cfg *config.SinkConfig, logger *log.Logger) (Sink, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get hostname, using empty string")
+		hostname = ""
+	}
+
+	switch cfg.Type {
+	case "graphite":
+		return NewGraphite(cfg, hostname, logger)
+	case "statsd":
+		return NewStatsD(cfg, hostname, logger)
+	case "file":
+		return NewFile(cfg, logger)
+	default:
+		return nil, fmt.Errorf("unknown sink type '%s'", cfg.Type)
+	}
+}
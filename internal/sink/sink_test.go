@@ -0,0 +1,48 @@
+package sink
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// testReading returns a successful reading with fixed values
+func testReading() Reading {
+	return Reading{
+		Time:        time.Unix(1700000000, 0),
+		Sensor:      "living-room",
+		GPIO:        "GPIO4",
+		Temperature: 21.5,
+		Humidity:    45.2,
+		Unit:        "C",
+	}
+}
+
+func TestNew_UnknownType(t *testing.T) {
+	_, err := New(&config.SinkConfig{Type: "carrier-pigeon"}, getSilentLogger())
+	if err == nil {
+		t.Error("New() expected error for unknown sink type, got nil")
+	}
+}
+
+func TestNew_MissingAddress(t *testing.T) {
+	for _, typ := range []string{"graphite", "statsd"} {
+		t.Run(typ, func(t *testing.T) {
+			_, err := New(&config.SinkConfig{Type: typ}, getSilentLogger())
+			if err == nil {
+				t.Errorf("New(%q) expected error for missing address, got nil", typ)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// StatsD sends readings as gauges ("<path>:<value>|g") over UDP.
+// Each reading is sent as a single datagram.
+type StatsD struct {
+	address  string
+	prefix   string
+	hostname string
+	template *Template
+	logger   *log.Logger
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewStatsD creates a StatsD gauge sink.
+// Returns an error if the address is missing or the template is invalid.
+func NewStatsD(cfg *config.SinkConfig, hostname string, logger *log.Logger) (*StatsD, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("statsd sink requires an address")
+	}
+	tmpl, err := ParseTemplate(cfg.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statsd template: %w", err)
+	}
+
+	logger.WithField("address", cfg.Address).Info("Initializing StatsD sink")
+
+	return &StatsD{
+		address:  cfg.Address,
+		prefix:   cfg.Prefix,
+		hostname: hostname,
+		template: tmpl,
+		logger:   logger,
+	}, nil
+}
+
+// Start opens the UDP socket.
+// Returns an error if the address cannot be resolved.
+func (s *StatsD) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// UDP "dialing" only resolves the address; nothing is sent yet.
+	conn, err := net.Dial("udp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to resolve statsd address %s: %w", s.address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// Write sends the temperature and humidity of r as gauges.
+// Failed readings are skipped.
+func (s *StatsD) Write(r Reading) error {
+	if r.Err != nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, smp := range s.template.samples(r, s.prefix, s.hostname) {
+		// A signed gauge value is interpreted by StatsD as a delta, so negative
+		// temperatures must reset the gauge to zero first.
+		if smp.value < 0 {
+			buf.WriteString(smp.path)
+			buf.WriteString(":0|g\n")
+		}
+		buf.WriteString(smp.path)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.FormatFloat(smp.value, 'f', -1, 64))
+		buf.WriteString("|g\n")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return fmt.Errorf("statsd sink %s not started", s.address)
+	}
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write to statsd %s: %w", s.address, err)
+	}
+	return nil
+}
+
+// Close closes the UDP socket.
+func (s *StatsD) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// readDatagram reads a single UDP datagram from conn
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read datagram: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsD_Write(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD(&config.SinkConfig{
+		Address:  conn.LocalAddr().String(),
+		Prefix:   "dht",
+		Template: "{prefix}.{dht_name}.{metric}",
+	}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewStatsD() returned unexpected error: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Write(testReading()); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+
+	want := "dht.living-room.temperature:21.5|g\ndht.living-room.humidity:45.2|g\n"
+	if got := readDatagram(t, conn); got != want {
+		t.Errorf("datagram = %q, want %q", got, want)
+	}
+}
+
+// Negative gauges would be interpreted as deltas, so they must be reset to zero first
+func TestStatsD_NegativeGauge(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD(&config.SinkConfig{
+		Address:  conn.LocalAddr().String(),
+		Template: "{dht_name}.{metric}",
+	}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewStatsD() returned unexpected error: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	r := testReading()
+	r.Temperature = -18.5
+	if err := s.Write(r); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+
+	want := "living-room.temperature:0|g\nliving-room.temperature:-18.5|g\nliving-room.humidity:45.2|g\n"
+	if got := readDatagram(t, conn); got != want {
+		t.Errorf("datagram = %q, want %q", got, want)
+	}
+}
+
+func TestStatsD_SkipsFailedReadings(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD(&config.SinkConfig{Address: conn.LocalAddr().String()}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewStatsD() returned unexpected error: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	r := testReading()
+	r.Err = errors.New("timeout")
+	if err := s.Write(r); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	buf := make([]byte, 1500)
+	if n, _, err := conn.ReadFrom(buf); err == nil {
+		t.Errorf("received unexpected datagram %q for failed reading", buf[:n])
+	}
+}
+
+func TestStatsD_WriteBeforeStart(t *testing.T) {
+	s, err := NewStatsD(&config.SinkConfig{Address: "127.0.0.1:8125"}, "pi", getSilentLogger())
+	if err != nil {
+		t.Fatalf("NewStatsD() returned unexpected error: %v", err)
+	}
+
+	if err := s.Write(testReading()); err == nil {
+		t.Error("Write() before Start() expected error, got nil")
+	}
+}
@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultTemplate is the metric path template used when none is configured.
+const DefaultTemplate = "{prefix}.{hostname}.{dht_name}.{metric}"
+
+// templateFields lists the placeholders a metric path template may reference.
+var templateFields = map[string]bool{
+	"prefix":   true,
+	"hostname": true,
+	"dht_name": true,
+	"gpio":     true,
+	"metric":   true,
+	"unit":     true,
+}
+
+// Template renders dotted metric paths such as "dht.pi.kitchen.temperature"
+// from a pattern containing {placeholder} fields.
+type Template struct {
+	// parts alternates between literal text (even indexes) and field names (odd indexes).
+	parts []string
+}
+
+// ParseTemplate parses a metric path template.
+// Returns an error for unbalanced braces or unknown placeholders.
+func ParseTemplate(pattern string) (*Template, error) {
+	if pattern == "" {
+		pattern = DefaultTemplate
+	}
+
+	var parts []string
+	rest := pattern
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return nil, fmt.Errorf("unbalanced '}' in template %q", pattern)
+			}
+			parts = append(parts, rest)
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in template %q", pattern)
+		}
+		field := rest[start+1 : start+end]
+		if !templateFields[field] {
+			return nil, fmt.Errorf("unknown placeholder {%s} in template %q", field, pattern)
+		}
+		parts = append(parts, rest[:start], field)
+		rest = rest[start+end+1:]
+	}
+
+	return &Template{parts: parts}, nil
+}
+
+// Execute renders the template with the given field values.
+// Values other than prefix are sanitized so they form a single path segment,
+// and empty segments are removed so that an unset prefix leaves no leading dot.
+func (t *Template) Execute(values map[string]string) string {
+	var b strings.Builder
+	for i, part := range t.parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		value := values[part]
+		if part != "prefix" {
+			value = sanitizeSegment(value)
+		}
+		b.WriteString(value)
+	}
+
+	segments := strings.Split(b.String(), ".")
+	kept := segments[:0]
+	for _, s := range segments {
+		if s != "" {
+			kept = append(kept, s)
+		}
+	}
+	return strings.Join(kept, ".")
+}
+
+// sanitizeSegment replaces characters that are meaningful to Graphite and
+// StatsD (path separators, whitespace and protocol delimiters) with underscores.
+func sanitizeSegment(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ' ', '\t', '\n', '/', ':', '|', '@':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// sample is a single named value rendered from a reading.
+type sample struct {
+	path  string
+	value float64
+}
+
+// samples renders the metric paths for the temperature and humidity of r.
+// Humidity is left out for sensors that do not measure it.
+func (t *Template) samples(r Reading, prefix, hostname string) []sample {
+	values := map[string]string{
+		"prefix":   prefix,
+		"hostname": hostname,
+		"dht_name": r.Sensor,
+		"gpio":     r.GPIO,
+		"unit":     r.Unit,
+	}
+
+	values["metric"] = "temperature"
+	temperature := sample{path: t.Execute(values), value: r.Temperature}
+
+	if math.IsNaN(r.Humidity) {
+		return []sample{temperature}
+	}
+
+	values["metric"] = "humidity"
+	values["unit"] = "percent"
+	humidity := sample{path: t.Execute(values), value: r.Humidity}
+
+	return []sample{temperature, humidity}
+}
@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"testing"
+)
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	tests := []string{
+		"{prefix}.{unknown}",
+		"{prefix.{hostname}",
+		"prefix}.hostname",
+	}
+
+	for _, pattern := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			if _, err := ParseTemplate(pattern); err == nil {
+				t.Errorf("ParseTemplate(%q) expected error, got nil", pattern)
+			}
+		})
+	}
+}
+
+func TestTemplate_Execute(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		values   map[string]string
+		expected string
+	}{
+		{
+			name:     "default template",
+			pattern:  "",
+			values:   map[string]string{"prefix": "dht", "hostname": "pi", "dht_name": "kitchen", "metric": "temperature"},
+			expected: "dht.pi.kitchen.temperature",
+		},
+		{
+			name:     "dotted prefix is kept",
+			pattern:  "{prefix}.{dht_name}.{metric}",
+			values:   map[string]string{"prefix": "facilities.dht", "dht_name": "kitchen", "metric": "humidity"},
+			expected: "facilities.dht.kitchen.humidity",
+		},
+		{
+			name:     "values are sanitized",
+			pattern:  "{hostname}.{dht_name}",
+			values:   map[string]string{"hostname": "pi.local", "dht_name": "server room/rack 1"},
+			expected: "pi_local.server_room_rack_1",
+		},
+		{
+			name:     "empty prefix leaves no leading dot",
+			pattern:  "{prefix}.{hostname}.{metric}",
+			values:   map[string]string{"hostname": "pi", "metric": "temperature"},
+			expected: "pi.temperature",
+		},
+		{
+			name:     "literal text",
+			pattern:  "sensors.{gpio}.{metric}_{unit}",
+			values:   map[string]string{"gpio": "GPIO4", "metric": "temperature", "unit": "C"},
+			expected: "sensors.GPIO4.temperature_C",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.pattern)
+			if err != nil {
+				t.Fatalf("ParseTemplate(%q) returned unexpected error: %v", tt.pattern, err)
+			}
+			if got := tmpl.Execute(tt.values); got != tt.expected {
+				t.Errorf("Execute() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+)
+
+const (
+	// DefaultRetryBackoff is the wait before the first initialization retry of a sensor.
+	DefaultRetryBackoff = 5 * time.Second
+	// DefaultRetryBackoffMax bounds the wait between initialization retries,
+	// which doubles after every failure.
+	DefaultRetryBackoffMax = 5 * time.Minute
+)
+
+// ErrInit wraps the errors of sensors failing to initialize.
+var ErrInit = errors.New("failed to initialize sensor")
+
+// OpenFunc opens a sensor. It is called again after it fails.
+type OpenFunc func() (sensor.Reader, error)
+
+// Sensor is a configured sensor that may not be initialized yet.
+// It implements sensor.MultiReader, with temperatures converted to the
+// temperature_unit of the sensor; until the sensor is initialized, reads
+// fail with its initialization error.
+type Sensor struct {
+	cfg               *config.SensorConfig
+	open              OpenFunc
+	temperatureSymbol string
+	collectorOpts     collector.Options
+	initializedMetric *prometheus.Desc
+	infoMetric        *prometheus.Desc
+
+	mu        sync.Mutex
+	reader    sensor.MultiReader
+	raw       sensor.Reader
+	collector *collector.Collector
+	err       error
+	attempts  int
+}
+
+// Read reads the sensor once initialized.
+func (s *Sensor) Read(ctx context.Context) (sensor.Reading, error) {
+	reader, err := s.current()
+	if reader == nil {
+		return sensor.Reading{}, fmt.Errorf("sensor not initialized: %w", err)
+	}
+	reading, err := reader.Read(ctx)
+	return sensor.ConvertReading(reading, s.temperatureSymbol), err
+}
+
+// ReadData reads humidity and temperature once initialized.
+func (s *Sensor) ReadData(ctx context.Context) (humidity, temperature float64, err error) {
+	reader, initErr := s.current()
+	if reader == nil {
+		return 0, 0, fmt.Errorf("sensor not initialized: %w", initErr)
+	}
+	humidity, temperature, err = reader.ReadData(ctx)
+	return humidity, sensor.ConvertTemperature(temperature, reader.TemperatureUnit(), s.temperatureSymbol), err
+}
+
+// current returns the initialized reader, or nil and the initialization error.
+func (s *Sensor) current() (sensor.MultiReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reader, s.err
+}
+
+// Quantities returns the quantities of the initialized sensor, or none.
+func (s *Sensor) Quantities() []sensor.Quantity {
+	if reader, _ := s.current(); reader != nil {
+		return reader.Quantities()
+	}
+	return nil
+}
+
+// HasHumidity reports whether the initialized sensor measures humidity.
+// Sensors not initialized yet are assumed to.
+func (s *Sensor) HasHumidity() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.raw == nil || sensor.HasHumidity(s.raw)
+}
+
+// TemperatureUnit returns the configured temperature unit symbol.
+func (s *Sensor) TemperatureUnit() string {
+	return s.temperatureSymbol
+}
+
+// Name returns the sensor name.
+func (s *Sensor) Name() string {
+	return s.cfg.Name
+}
+
+// GPIO returns the GPIO pin identifier.
+func (s *Sensor) GPIO() string {
+	return s.cfg.GPIO
+}
+
+// Err returns the last initialization error, or nil once the sensor is initialized.
+func (s *Sensor) Err() error {
+	_, err := s.current()
+	return err
+}
+
+// Manager initializes sensors and retries those failing to initialize in the
+// background, so that one broken sensor does not take down the metrics of the
+// others. It implements prometheus.Collector, exposing whether each sensor
+// is initialized and its model and backend.
+type Manager struct {
+	logger        *log.Logger
+	hostname      string
+	collectorOpts collector.Options
+
+	mu      sync.Mutex
+	sensors []*Sensor
+	labels  map[string]bool
+
+	wg sync.WaitGroup
+
+	// backoff and backoffMax are replaced in tests.
+	backoff    time.Duration
+	backoffMax time.Duration
+}
+
+// New creates an empty Manager. The collectors of its sensors are created
+// with opts and the temperature_unit, labels and stale policy of each sensor.
+func New(opts collector.Options, logger *log.Logger) *Manager {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get hostname, using empty string")
+	}
+	return &Manager{
+		logger:        logger,
+		hostname:      hostname,
+		collectorOpts: opts,
+		labels:        make(map[string]bool),
+		backoff:       DefaultRetryBackoff,
+		backoffMax:    DefaultRetryBackoffMax,
+	}
+}
+
+// Add initializes the sensor of cfg with open. If that fails, the sensor is
+// still added and the error, wrapping ErrInit, is returned; Start retries it
+// in the background.
+// A sensor whose metrics would have the same labels as those of a previous
+// one is not added, and a nil Sensor is returned with the error.
+// Sensors must be added before the Manager is registered.
+func (m *Manager) Add(cfg *config.SensorConfig, open OpenFunc) (*Sensor, error) {
+	symbol := sensor.TemperatureSymbol(cfg.TemperatureUnit)
+	opts := m.collectorOpts.WithLabels(cfg.Labels)
+	opts.TemperatureUnit = symbol
+	opts.StalePolicy = cfg.StalePolicy
+	opts.StaleAfter = cfg.StaleAfter
+	labels := opts.SensorLabels(cfg.Name, m.hostname, cfg.GPIO)
+
+	key := labelsKey(labels)
+	m.mu.Lock()
+	if m.labels[key] {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("duplicate sensor '%s' on %s: another sensor has the same metric labels", cfg.Name, cfg.GPIO)
+	}
+	m.labels[key] = true
+	m.mu.Unlock()
+
+	s := &Sensor{
+		cfg:               cfg,
+		open:              open,
+		temperatureSymbol: symbol,
+		collectorOpts:     opts,
+		initializedMetric: prometheus.NewDesc(
+			opts.MetricName("sensor_initialized"),
+			"Whether the sensor is initialized (1) or its initialization failed and is retried (0), with the error",
+			[]string{"error"}, labels,
+		),
+		infoMetric: prometheus.NewDesc(
+			opts.MetricName("sensor_info"),
+			"Model, backend and calibration of the sensor, always 1",
+			nil, infoLabels(cfg, labels),
+		),
+	}
+
+	m.mu.Lock()
+	m.sensors = append(m.sensors, s)
+	m.mu.Unlock()
+
+	if err := m.initialize(s); err != nil {
+		return s, fmt.Errorf("%w '%s': %w", ErrInit, cfg.Name, err)
+	}
+	return s, nil
+}
+
+// initialize opens s once.
+func (m *Manager) initialize(s *Sensor) error {
+	r, err := s.open()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if err != nil {
+		s.err = err
+		m.logger.WithFields(log.Fields{
+			"sensor":   s.cfg.Name,
+			"gpio":     s.cfg.GPIO,
+			"attempts": s.attempts,
+			"error":    err,
+		}).Error("Failed to initialize sensor")
+		return err
+	}
+
+	s.raw = r
+	s.reader = sensor.Extend(r)
+	s.collector = collector.New(r, s.collectorOpts, m.logger)
+	s.err = nil
+	if s.attempts > 1 {
+		m.logger.WithFields(log.Fields{
+			"sensor":   s.cfg.Name,
+			"gpio":     s.cfg.GPIO,
+			"attempts": s.attempts,
+		}).Info("Sensor initialized")
+	}
+	return nil
+}
+
+// Start retries the initialization of the sensors that failed, each with an
+// exponential backoff, until they succeed or ctx is done.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sensors {
+		if s.Err() == nil {
+			continue
+		}
+		m.wg.Add(1)
+		go m.retry(ctx, s)
+	}
+}
+
+// retry initializes s until it succeeds or ctx is done.
+func (m *Manager) retry(ctx context.Context, s *Sensor) {
+	defer m.wg.Done()
+
+	backoff := m.backoff
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if m.initialize(s) == nil {
+			return
+		}
+		backoff = min(2*backoff, m.backoffMax)
+	}
+}
+
+// Wait waits for the retries started by Start to stop.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Readers returns every added sensor, initialized or not.
+func (m *Manager) Readers() []sensor.Reader {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	readers := make([]sensor.Reader, len(m.sensors))
+	for i, s := range m.sensors {
+		readers[i] = s
+	}
+	return readers
+}
+
+// Collectors returns the collectors of the initialized sensors.
+func (m *Manager) Collectors() []*collector.Collector {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collectors := make([]*collector.Collector, 0, len(m.sensors))
+	for _, s := range m.sensors {
+		s.mu.Lock()
+		if s.collector != nil {
+			collectors = append(collectors, s.collector)
+		}
+		s.mu.Unlock()
+	}
+	return collectors
+}
+
+// Pending returns the names of the sensors not initialized yet.
+func (m *Manager) Pending() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []string
+	for _, s := range m.sensors {
+		if s.Err() != nil {
+			pending = append(pending, s.cfg.Name)
+		}
+	}
+	return pending
+}
+
+// Describe sends the descriptors of the initialization and info metrics of the sensors.
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sensors {
+		ch <- s.initializedMetric
+		ch <- s.infoMetric
+	}
+}
+
+// Collect sends whether each sensor is initialized, with its last
+// initialization error, and the info of each sensor.
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sensors {
+		value, msg := 1.0, ""
+		if err := s.Err(); err != nil {
+			value, msg = 0, err.Error()
+		}
+		ch <- prometheus.MustNewConstMetric(s.initializedMetric, prometheus.GaugeValue, value, msg)
+		ch <- prometheus.MustNewConstMetric(s.infoMetric, prometheus.GaugeValue, 1)
+	}
+}
+
+// infoLabels returns the labels of the info metric of the sensor of cfg,
+// whose metrics have labels. The calibration labels are the register scales
+// of modbus sensors, the only calibration the exporter applies; they are
+// empty for other sensors, and humidity_scale without a humidity register.
+func infoLabels(cfg *config.SensorConfig, labels prometheus.Labels) prometheus.Labels {
+	info := maps.Clone(labels)
+	info["model"] = sensor.Model(cfg)
+	info["backend"] = cfg.Backend
+	if cfg.Backend == "" {
+		info["backend"] = sensor.BackendPeriph
+	}
+	info["temperature_scale"], info["humidity_scale"] = "", ""
+	if cfg.Backend == "modbus" {
+		info["temperature_scale"] = strconv.FormatFloat(cfg.Modbus.TemperatureScale, 'g', -1, 64)
+		if cfg.Modbus.HumidityRegister >= 0 {
+			info["humidity_scale"] = strconv.FormatFloat(cfg.Modbus.HumidityScale, 'g', -1, 64)
+		}
+	}
+	return info
+}
+
+// labelsKey returns a key identifying the series of labels.
+func labelsKey(labels prometheus.Labels) string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		fmt.Fprintf(&b, "%s=%q,", name, labels[name])
+	}
+	return b.String()
+}
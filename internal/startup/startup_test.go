@@ -0,0 +1,298 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+)
+
+// mockSensor is a mock implementation of sensor.Reader for testing
+type mockSensor struct {
+	name        string
+	gpio        string
+	humidity    float64
+	temperature float64
+	unit        string
+}
+
+func (m *mockSensor) ReadData(ctx context.Context) (float64, float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	return m.humidity, m.temperature, nil
+}
+
+func (m *mockSensor) TemperatureUnit() string {
+	return m.unit
+}
+
+func (m *mockSensor) Name() string {
+	return m.name
+}
+
+func (m *mockSensor) GPIO() string {
+	return m.gpio
+}
+
+// flakyOpener fails to open its sensor until it is fixed.
+type flakyOpener struct {
+	mu     sync.Mutex
+	err    error
+	calls  int
+	sensor *mockSensor
+}
+
+func (o *flakyOpener) open() (sensor.Reader, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls++
+	if o.err != nil {
+		return nil, o.err
+	}
+	return o.sensor, nil
+}
+
+func (o *flakyOpener) fix() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.err = nil
+}
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func newTestManager() *Manager {
+	m := New(collector.Options{}, getSilentLogger())
+	m.hostname = "testhost"
+	m.backoff = time.Millisecond
+	m.backoffMax = 4 * time.Millisecond
+	return m
+}
+
+func TestAdd_Initialized(t *testing.T) {
+	m := newTestManager()
+	cfg := &config.SensorConfig{Name: "attic", GPIO: "GPIO4", TemperatureUnit: "celsius"}
+	opener := &flakyOpener{sensor: &mockSensor{name: "attic", gpio: "GPIO4", humidity: 40, temperature: 20, unit: sensor.CelsiusSymbol}}
+
+	s, err := m.Add(cfg, opener.open)
+	if err != nil {
+		t.Fatalf("Add() returned unexpected error: %v", err)
+	}
+	humidity, temperature, err := s.ReadData(context.Background())
+	if err != nil {
+		t.Fatalf("ReadData() returned unexpected error: %v", err)
+	}
+	if humidity != 40 || temperature != 20 {
+		t.Errorf("ReadData() = (%v, %v), want (40, 20)", humidity, temperature)
+	}
+	if got := len(m.Collectors()); got != 1 {
+		t.Errorf("len(Collectors()) = %d, want 1", got)
+	}
+	if pending := m.Pending(); len(pending) != 0 {
+		t.Errorf("Pending() = %v, want none", pending)
+	}
+}
+
+// Reads are converted from the unit of the sensor to its temperature_unit
+func TestSensor_TemperatureUnit(t *testing.T) {
+	m := newTestManager()
+	cfg := &config.SensorConfig{Name: "attic", GPIO: "GPIO4", TemperatureUnit: "kelvin"}
+	opener := &flakyOpener{sensor: &mockSensor{name: "attic", gpio: "GPIO4", humidity: 40, temperature: 20, unit: sensor.CelsiusSymbol}}
+
+	s, err := m.Add(cfg, opener.open)
+	if err != nil {
+		t.Fatalf("Add() returned unexpected error: %v", err)
+	}
+	if s.TemperatureUnit() != sensor.KelvinSymbol {
+		t.Errorf("TemperatureUnit() = %q, want %q", s.TemperatureUnit(), sensor.KelvinSymbol)
+	}
+	reading, err := s.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	if v, _ := reading.Value(sensor.QuantityTemperature); math.Abs(v-293.15) > 1e-9 {
+		t.Errorf("temperature = %v, want 293.15", v)
+	}
+	if _, temperature, _ := s.ReadData(context.Background()); math.Abs(temperature-293.15) > 1e-9 {
+		t.Errorf("ReadData() temperature = %v, want 293.15", temperature)
+	}
+}
+
+func TestAdd_Duplicate(t *testing.T) {
+	m := newTestManager()
+	cfg := &config.SensorConfig{Name: "attic", GPIO: "GPIO4"}
+	opener := &flakyOpener{sensor: &mockSensor{name: "attic", gpio: "GPIO4"}}
+
+	if _, err := m.Add(cfg, opener.open); err != nil {
+		t.Fatalf("Add() returned unexpected error: %v", err)
+	}
+	s, err := m.Add(cfg, opener.open)
+	if err == nil || errors.Is(err, ErrInit) {
+		t.Errorf("Add() duplicate error = %v, want a duplicate error", err)
+	}
+	if s != nil {
+		t.Error("Add() returned a sensor for a duplicate")
+	}
+	if got := len(m.Readers()); got != 1 {
+		t.Errorf("len(Readers()) = %d, want 1", got)
+	}
+}
+
+// Sensors are duplicates when their metrics would have the same labels
+func TestAdd_DuplicateLabels(t *testing.T) {
+	m := New(collector.Options{DropLabels: []string{config.LabelGPIO}}, getSilentLogger())
+	open := (&flakyOpener{sensor: &mockSensor{name: "probe"}}).open
+
+	if _, err := m.Add(&config.SensorConfig{Name: "probe", GPIO: "GPIO4", Labels: map[string]string{"room": "attic"}}, open); err != nil {
+		t.Fatalf("Add() returned unexpected error: %v", err)
+	}
+	if _, err := m.Add(&config.SensorConfig{Name: "probe", GPIO: "GPIO17", Labels: map[string]string{"room": "cellar"}}, open); err != nil {
+		t.Errorf("Add() with other labels returned unexpected error: %v", err)
+	}
+	if _, err := m.Add(&config.SensorConfig{Name: "probe", GPIO: "GPIO27", Labels: map[string]string{"room": "attic"}}, open); err == nil {
+		t.Error("Add() expected error for a sensor differing only by its dropped gpio label, got nil")
+	}
+}
+
+func TestManager_Retry(t *testing.T) {
+	m := newTestManager()
+	cfg := &config.SensorConfig{Name: "attic", GPIO: "GPIO4", TemperatureUnit: "fahrenheit"}
+	opener := &flakyOpener{
+		err:    errors.New("no such device"),
+		sensor: &mockSensor{name: "attic", gpio: "GPIO4", humidity: 40, temperature: 68, unit: sensor.FahrenheitSymbol},
+	}
+
+	s, err := m.Add(cfg, opener.open)
+	if !errors.Is(err, ErrInit) {
+		t.Fatalf("Add() error = %v, want ErrInit", err)
+	}
+
+	// The sensor is served, failing, until it is initialized
+	if _, err := s.Read(context.Background()); err == nil || !strings.Contains(err.Error(), "no such device") {
+		t.Errorf("Read() error = %v, want the initialization error", err)
+	}
+	if s.Name() != "attic" || s.GPIO() != "GPIO4" || s.TemperatureUnit() != sensor.FahrenheitSymbol {
+		t.Errorf("Name(), GPIO(), TemperatureUnit() = %q, %q, %q, want attic, GPIO4, %s", s.Name(), s.GPIO(), s.TemperatureUnit(), sensor.FahrenheitSymbol)
+	}
+	if got := len(m.Collectors()); got != 0 {
+		t.Errorf("len(Collectors()) = %d, want 0", got)
+	}
+	if pending := m.Pending(); len(pending) != 1 || pending[0] != "attic" {
+		t.Errorf("Pending() = %v, want [attic]", pending)
+	}
+	want := `
+# HELP dht_sensor_info Model, backend and calibration of the sensor, always 1
+# TYPE dht_sensor_info gauge
+dht_sensor_info{backend="periph",dht_name="attic",gpio="GPIO4",hostname="testhost",humidity_scale="",model="DHT22",temperature_scale=""} 1
+# HELP dht_sensor_initialized Whether the sensor is initialized (1) or its initialization failed and is retried (0), with the error
+# TYPE dht_sensor_initialized gauge
+dht_sensor_initialized{dht_name="attic",error="no such device",gpio="GPIO4",hostname="testhost"} 0
+`
+	if err := testutil.CollectAndCompare(m, strings.NewReader(want)); err != nil {
+		t.Errorf("unexpected metrics before initialization: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.Start(ctx)
+	opener.fix()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("sensor not initialized by the retries")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.Wait()
+
+	if _, temperature, err := s.ReadData(context.Background()); err != nil || temperature != 68 {
+		t.Errorf("ReadData() = (_, %v, %v), want (_, 68, nil)", temperature, err)
+	}
+	if got := len(m.Collectors()); got != 1 {
+		t.Errorf("len(Collectors()) = %d, want 1", got)
+	}
+	if pending := m.Pending(); len(pending) != 0 {
+		t.Errorf("Pending() = %v, want none", pending)
+	}
+	want = `
+# HELP dht_sensor_info Model, backend and calibration of the sensor, always 1
+# TYPE dht_sensor_info gauge
+dht_sensor_info{backend="periph",dht_name="attic",gpio="GPIO4",hostname="testhost",humidity_scale="",model="DHT22",temperature_scale=""} 1
+# HELP dht_sensor_initialized Whether the sensor is initialized (1) or its initialization failed and is retried (0), with the error
+# TYPE dht_sensor_initialized gauge
+dht_sensor_initialized{dht_name="attic",error="",gpio="GPIO4",hostname="testhost"} 1
+`
+	if err := testutil.CollectAndCompare(m, strings.NewReader(want)); err != nil {
+		t.Errorf("unexpected metrics after initialization: %v", err)
+	}
+}
+
+func TestManager_StopRetries(t *testing.T) {
+	m := newTestManager()
+	opener := &flakyOpener{err: errors.New("no such device")}
+	if _, err := m.Add(&config.SensorConfig{Name: "attic", GPIO: "GPIO4"}, opener.open); err == nil {
+		t.Fatal("Add() returned no error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after the context was cancelled")
+	}
+	if m.Pending() == nil {
+		t.Error("Pending() = none, want the sensor")
+	}
+}
+
+func TestInfoLabels_Calibration(t *testing.T) {
+	tests := []struct {
+		name                  string
+		cfg                   config.SensorConfig
+		temperature, humidity string
+	}{
+		{"periph", config.SensorConfig{Name: "attic"}, "", ""},
+		{"modbus", config.SensorConfig{Name: "barn", Backend: "modbus", Modbus: config.ModbusConfig{
+			TemperatureScale: 0.1, HumidityScale: 0.01, HumidityRegister: 1,
+		}}, "0.1", "0.01"},
+		{"modbus without humidity", config.SensorConfig{Name: "tank", Backend: "modbus", Modbus: config.ModbusConfig{
+			TemperatureScale: 0.1, HumidityScale: 1, HumidityRegister: -1,
+		}}, "0.1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := infoLabels(&tt.cfg, prometheus.Labels{"dht_name": tt.cfg.Name})
+			if info["temperature_scale"] != tt.temperature || info["humidity_scale"] != tt.humidity {
+				t.Errorf("temperature_scale, humidity_scale = %q, %q, want %q, %q",
+					info["temperature_scale"], info["humidity_scale"], tt.temperature, tt.humidity)
+			}
+		})
+	}
+}
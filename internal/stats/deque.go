@@ -0,0 +1,63 @@
+package stats
+
+// deque is a double-ended queue on a ring buffer, growing as needed, so that
+// windows sliding over polled readings do not allocate once warmed up.
+type deque[T any] struct {
+	items []T
+	head  int
+	n     int
+}
+
+// Len returns the number of items.
+func (d *deque[T]) Len() int {
+	return d.n
+}
+
+// PushBack appends v.
+func (d *deque[T]) PushBack(v T) {
+	if d.n == len(d.items) {
+		d.grow()
+	}
+	d.items[(d.head+d.n)%len(d.items)] = v
+	d.n++
+}
+
+// Front returns the first item. The deque must not be empty.
+func (d *deque[T]) Front() T {
+	return d.items[d.head]
+}
+
+// Back returns the last item. The deque must not be empty.
+func (d *deque[T]) Back() T {
+	return d.items[(d.head+d.n-1)%len(d.items)]
+}
+
+// At returns the item at index i from the front. i must be below Len.
+func (d *deque[T]) At(i int) T {
+	return d.items[(d.head+i)%len(d.items)]
+}
+
+// PopFront removes the first item. The deque must not be empty.
+func (d *deque[T]) PopFront() {
+	var zero T
+	d.items[d.head] = zero
+	d.head = (d.head + 1) % len(d.items)
+	d.n--
+}
+
+// PopBack removes the last item. The deque must not be empty.
+func (d *deque[T]) PopBack() {
+	var zero T
+	d.items[(d.head+d.n-1)%len(d.items)] = zero
+	d.n--
+}
+
+// grow doubles the capacity, moving the items to the start of the buffer.
+func (d *deque[T]) grow() {
+	items := make([]T, max(2*len(d.items), 8))
+	for i := range d.n {
+		items[i] = d.items[(d.head+i)%len(d.items)]
+	}
+	d.items = items
+	d.head = 0
+}
@@ -0,0 +1,34 @@
+package stats
+
+import "testing"
+
+func TestDeque(t *testing.T) {
+	var d deque[int]
+	// Wrap around the ring buffer and grow it while it does
+	for i := range 6 {
+		d.PushBack(i)
+	}
+	for range 4 {
+		d.PopFront()
+	}
+	for i := 6; i < 20; i++ {
+		d.PushBack(i)
+	}
+	d.PopBack()
+
+	if d.Len() != 15 {
+		t.Fatalf("Len() = %d, want 15", d.Len())
+	}
+	if d.Front() != 4 || d.Back() != 18 {
+		t.Errorf("Front(), Back() = %d, %d, want 4, 18", d.Front(), d.Back())
+	}
+	for want := 4; want <= 18; want++ {
+		if got := d.Front(); got != want {
+			t.Fatalf("Front() = %d, want %d", got, want)
+		}
+		d.PopFront()
+	}
+	if d.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", d.Len())
+	}
+}
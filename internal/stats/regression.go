@@ -0,0 +1,81 @@
+package stats
+
+import "time"
+
+// regression fits a line by least squares to the samples of a quantity
+// polled during the last duration. Its running sums are updated as samples
+// enter and leave the window, so that adding a sample and fitting take
+// constant amortized time.
+type regression struct {
+	duration time.Duration
+	samples  deque[sample]
+	// The sums are those of the times in seconds since origin and of the
+	// values minus shift, limiting the cancellation in the slope. They are
+	// recomputed when the window has slid past origin.
+	origin time.Time
+	shift  float64
+	sumT   float64
+	sumV   float64
+	sumTT  float64
+	sumTV  float64
+}
+
+// add adds the value v polled at t, evicting the samples older than the
+// duration of r at t.
+func (r *regression) add(t time.Time, v float64) {
+	r.evict(t)
+	if r.samples.Len() == 0 {
+		r.origin, r.shift = t, v
+		r.sumT, r.sumV, r.sumTT, r.sumTV = 0, 0, 0, 0
+	}
+	s := sample{time: t, value: v}
+	r.samples.PushBack(s)
+	r.accumulate(s, 1)
+}
+
+// accumulate adds s to the sums, or removes it with sign -1.
+func (r *regression) accumulate(s sample, sign float64) {
+	t := s.time.Sub(r.origin).Seconds()
+	v := s.value - r.shift
+	r.sumT += sign * t
+	r.sumV += sign * v
+	r.sumTT += sign * t * t
+	r.sumTV += sign * t * v
+}
+
+// evict removes the samples polled at least the duration of r before now.
+func (r *regression) evict(now time.Time) {
+	start := now.Add(-r.duration)
+	for r.samples.Len() > 0 && !r.samples.Front().time.After(start) {
+		r.accumulate(r.samples.Front(), -1)
+		r.samples.PopFront()
+	}
+	if r.samples.Len() > 0 && !r.origin.After(start) {
+		r.rebase()
+	}
+}
+
+// rebase recomputes the sums from the first sample of the window, once per
+// duration of r at most.
+func (r *regression) rebase() {
+	front := r.samples.Front()
+	r.origin, r.shift = front.time, front.value
+	r.sumT, r.sumV, r.sumTT, r.sumTV = 0, 0, 0, 0
+	for i := range r.samples.Len() {
+		r.accumulate(r.samples.At(i), 1)
+	}
+}
+
+// fit returns the slope of the fitted line, per second, and its value at
+// now, or false when the window has fewer than two samples at different times.
+func (r *regression) fit(now time.Time) (slope, value float64, ok bool) {
+	n := float64(r.samples.Len())
+	denominator := n*r.sumTT - r.sumT*r.sumT
+	if r.samples.Len() < 2 || denominator <= 0 {
+		return 0, 0, false
+	}
+	slope = (n*r.sumTV - r.sumT*r.sumV) / denominator
+	meanT, meanV := r.sumT/n, r.sumV/n
+	value = r.shift + meanV + slope*(now.Sub(r.origin).Seconds()-meanT)
+	return slope, value, true
+}
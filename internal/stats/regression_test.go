@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// bruteFit fits a line by least squares to the values polled after start
+func bruteFit(samples []sample, start, now time.Time) (slope, value float64, ok bool) {
+	var ts, vs []float64
+	for _, s := range samples {
+		if s.time.After(start) {
+			ts = append(ts, s.time.Sub(start).Seconds())
+			vs = append(vs, s.value)
+		}
+	}
+	if len(ts) < 2 {
+		return 0, 0, false
+	}
+	var meanT, meanV float64
+	for i := range ts {
+		meanT += ts[i] / float64(len(ts))
+		meanV += vs[i] / float64(len(ts))
+	}
+	var cov, varT float64
+	for i := range ts {
+		cov += (ts[i] - meanT) * (vs[i] - meanV)
+		varT += (ts[i] - meanT) * (ts[i] - meanT)
+	}
+	if varT == 0 {
+		return 0, 0, false
+	}
+	slope = cov / varT
+	return slope, meanV + slope*(now.Sub(start).Seconds()-meanT), true
+}
+
+func TestRegression_Line(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	r := &regression{duration: 10 * time.Minute}
+	// 2 degrees per hour from 20
+	for i := range 10 {
+		at := start.Add(time.Duration(i) * 30 * time.Second)
+		r.add(at, 20+2*at.Sub(start).Hours())
+	}
+
+	slope, value, ok := r.fit(start.Add(5 * time.Minute))
+	if !ok {
+		t.Fatal("fit() ok = false, want true")
+	}
+	if math.Abs(slope*3600-2) > 1e-9 {
+		t.Errorf("slope = %v per hour, want 2", slope*3600)
+	}
+	if want := 20 + 2*5.0/60; math.Abs(value-want) > 1e-9 {
+		t.Errorf("value = %v, want %v", value, want)
+	}
+}
+
+func TestRegression_MatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	start := time.Unix(1700000000, 0)
+	r := &regression{duration: 15 * time.Minute}
+
+	var samples []sample
+	now := start
+	// A day of polls, sliding the window past its origin many times
+	for i := range 3000 {
+		now = now.Add(time.Duration(1+rng.Intn(60)) * time.Second)
+		v := 20 + 3*math.Sin(float64(i)/100) + 0.2*rng.NormFloat64()
+		r.add(now, v)
+		samples = append(samples, sample{now, v})
+
+		slope, value, ok := r.fit(now)
+		wantSlope, wantValue, wantOK := bruteFit(samples, now.Add(-r.duration), now)
+		if ok != wantOK {
+			t.Fatalf("sample %d: fit() ok = %v, want %v", i, ok, wantOK)
+		}
+		if math.Abs(slope-wantSlope) > 1e-9 || math.Abs(value-wantValue) > 1e-6 {
+			t.Fatalf("sample %d: fit() = %v, %v, want %v, %v", i, slope, value, wantSlope, wantValue)
+		}
+	}
+}
+
+func TestRegression_TooFewSamples(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	r := &regression{duration: time.Minute}
+	if _, _, ok := r.fit(start); ok {
+		t.Error("fit() ok = true without samples, want false")
+	}
+	r.add(start, 20)
+	r.add(start, 21)
+	if _, _, ok := r.fit(start); ok {
+		t.Error("fit() ok = true with samples at the same time, want false")
+	}
+	r.add(start.Add(30*time.Second), 22)
+	if _, _, ok := r.fit(start.Add(30 * time.Second)); !ok {
+		t.Error("fit() ok = false with samples at different times, want true")
+	}
+	r.evict(start.Add(time.Minute))
+	if _, _, ok := r.fit(start.Add(time.Minute)); ok {
+		t.Error("fit() ok = true with a single sample left, want false")
+	}
+}
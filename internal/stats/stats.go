@@ -0,0 +1,196 @@
+package stats
+
+import (
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// sensorKey identifies the sensor of a polled reading.
+type sensorKey struct {
+	name string
+	gpio string
+}
+
+// statDescs holds the descriptors of the window statistics of a quantity.
+type statDescs struct {
+	min    *prometheus.Desc
+	max    *prometheus.Desc
+	avg    *prometheus.Desc
+	stddev *prometheus.Desc
+}
+
+// newStatDescs returns the descriptors of the window statistics of a
+// quantity, described by what with its unit, named after prefix, e.g.
+// dht_temperature_window_min.
+func newStatDescs(opts collector.Options, prefix, what string, labels prometheus.Labels) statDescs {
+	newDesc := func(stat, help string) *prometheus.Desc {
+		return prometheus.NewDesc(opts.MetricName(prefix+"_"+stat), help+" "+what+" polled during the window", []string{"window"}, labels)
+	}
+	return statDescs{
+		min:    newDesc("min", "Minimum"),
+		max:    newDesc("max", "Maximum"),
+		avg:    newDesc("avg", "Mean"),
+		stddev: newDesc("stddev", "Standard deviation of the"),
+	}
+}
+
+// send sends the statistics s of the window labelled window.
+func (d statDescs) send(ch chan<- prometheus.Metric, s summary, window string) {
+	ch <- prometheus.MustNewConstMetric(d.min, prometheus.GaugeValue, s.min, window)
+	ch <- prometheus.MustNewConstMetric(d.max, prometheus.GaugeValue, s.max, window)
+	ch <- prometheus.MustNewConstMetric(d.avg, prometheus.GaugeValue, s.mean, window)
+	ch <- prometheus.MustNewConstMetric(d.stddev, prometheus.GaugeValue, s.stddev, window)
+}
+
+// sensorWindows holds the windows of the temperature and humidity of a
+// sensor, one per window duration.
+type sensorWindows struct {
+	temperature      []*window
+	humidity         []*window
+	temperatureDescs statDescs
+	humidityDescs    statDescs
+}
+
+// Windows keeps the temperature and humidity polled from every sensor during
+// sliding windows, such as the last 5m and 1h, and exposes their minimum,
+// maximum, mean and standard deviation, in degrees Celsius and percent like
+// dht_temperature_celsius and dht_humidity_percent. Failed reads are left out.
+// It implements sink.Sink so it can be fed by the poller like any other output,
+// and prometheus.Collector to expose the statistics.
+type Windows struct {
+	logger    *log.Logger
+	durations []time.Duration
+
+	mu      sync.Mutex
+	sensors map[sensorKey]*sensorWindows
+	// order is the order of the sensors in the configuration.
+	order []*sensorWindows
+
+	// timeNow is replaced in tests.
+	timeNow func() time.Time
+}
+
+// NewWindows creates Windows of the given durations for the sensors, whose
+// statistics are named and labelled as their other metrics with opts.
+func NewWindows(sensors []config.SensorConfig, durations []time.Duration, opts collector.Options, logger *log.Logger) *Windows {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get hostname, using empty string")
+		hostname = ""
+	}
+
+	w := &Windows{
+		logger:    logger,
+		durations: durations,
+		sensors:   make(map[sensorKey]*sensorWindows),
+		timeNow:   time.Now,
+	}
+	for _, s := range sensors {
+		sensorOpts := opts.WithLabels(s.Labels)
+		labels := sensorOpts.SensorLabels(s.Name, hostname, s.GPIO)
+		sw := &sensorWindows{
+			temperatureDescs: newStatDescs(sensorOpts, "temperature_window", "temperature in degrees Celsius", labels),
+			humidityDescs:    newStatDescs(sensorOpts, "humidity_window", "humidity in percent", labels),
+		}
+		for _, d := range durations {
+			sw.temperature = append(sw.temperature, &window{duration: d})
+			sw.humidity = append(sw.humidity, &window{duration: d})
+		}
+		w.sensors[sensorKey{s.Name, s.GPIO}] = sw
+		w.order = append(w.order, sw)
+	}
+	return w
+}
+
+// Start implements sink.Sink.
+func (w *Windows) Start() error {
+	w.logger.WithFields(log.Fields{
+		"sensors": len(w.order),
+		"windows": len(w.durations),
+	}).Info("Starting window statistics")
+	return nil
+}
+
+// Write adds a successful reading to the windows of its sensor, its
+// temperature converted to Celsius.
+func (w *Windows) Write(r sink.Reading) error {
+	if r.Err != nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sw := w.sensors[sensorKey{r.Sensor, r.GPIO}]
+	if sw == nil {
+		return nil
+	}
+	temperature := sensor.ConvertTemperature(r.Temperature, r.Unit, sensor.CelsiusSymbol)
+	for i := range w.durations {
+		sw.temperature[i].add(r.Time, temperature)
+		if !math.IsNaN(r.Humidity) {
+			sw.humidity[i].add(r.Time, r.Humidity)
+		}
+	}
+	return nil
+}
+
+// Close implements sink.Sink.
+func (w *Windows) Close() error {
+	return nil
+}
+
+// Describe sends the descriptors of the statistics of every sensor.
+func (w *Windows) Describe(ch chan<- *prometheus.Desc) {
+	for _, sw := range w.order {
+		for _, d := range []statDescs{sw.temperatureDescs, sw.humidityDescs} {
+			ch <- d.min
+			ch <- d.max
+			ch <- d.avg
+			ch <- d.stddev
+		}
+	}
+}
+
+// Collect sends the statistics of every window with samples, once the
+// samples that left the window are evicted.
+func (w *Windows) Collect(ch chan<- prometheus.Metric) {
+	now := w.timeNow()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, sw := range w.order {
+		for i, d := range w.durations {
+			label := formatDuration(d)
+			sw.temperature[i].evict(now)
+			if s, ok := sw.temperature[i].summary(); ok {
+				sw.temperatureDescs.send(ch, s, label)
+			}
+			sw.humidity[i].evict(now)
+			if s, ok := sw.humidity[i].summary(); ok {
+				sw.humidityDescs.send(ch, s, label)
+			}
+		}
+	}
+}
+
+// formatDuration formats d as in Prometheus, e.g. 5m or 1h30m rather than
+// 5m0s or 1h30m0s.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package stats
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// getSilentLogger returns a logger that doesn't output anything
+func getSilentLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func reading(sensor, gpio string, temperature, humidity float64, at time.Time) sink.Reading {
+	return sink.Reading{
+		Time:        at,
+		Sensor:      sensor,
+		GPIO:        gpio,
+		Temperature: temperature,
+		Humidity:    humidity,
+		Unit:        "C",
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "30s"},
+		{5 * time.Minute, "5m"},
+		{90 * time.Second, "1m30s"},
+		{time.Hour, "1h"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour + time.Second, "1h0m1s"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestWindows_Collect(t *testing.T) {
+	sensors := []config.SensorConfig{
+		{Name: "attic", GPIO: "GPIO4"},
+		{Name: "probe", GPIO: "GPIO17"},
+	}
+	opts := collector.Options{DropLabels: []string{config.LabelHostname}}
+	w := NewWindows(sensors, []time.Duration{time.Minute, time.Hour}, opts, getSilentLogger())
+	start := time.Unix(1700000000, 0)
+
+	w.Write(reading("attic", "GPIO4", 15, 45, start))
+	w.Write(reading("attic", "GPIO4", 29, 59, start.Add(30*time.Second)))
+	w.Write(reading("attic", "GPIO4", 21, 51, start.Add(50*time.Second)))
+	w.Write(reading("attic", "GPIO4", 23, 53, start.Add(100*time.Second)))
+	// Failed reads, sensors without humidity and unknown sensors are left out
+	w.Write(sink.Reading{Time: start.Add(100 * time.Second), Sensor: "attic", GPIO: "GPIO4", Err: errors.New("timeout")})
+	// Temperatures are converted to Celsius
+	probe := reading("probe", "GPIO17", 41, math.NaN(), start.Add(100*time.Second))
+	probe.Unit = "F"
+	w.Write(probe)
+	w.Write(reading("cellar", "GPIO27", 12, 80, start.Add(100*time.Second)))
+	w.timeNow = func() time.Time { return start.Add(100 * time.Second) }
+
+	want := `
+# HELP dht_humidity_window_avg Mean humidity in percent polled during the window
+# TYPE dht_humidity_window_avg gauge
+dht_humidity_window_avg{dht_name="attic",gpio="GPIO4",window="1h"} 52
+dht_humidity_window_avg{dht_name="attic",gpio="GPIO4",window="1m"} 52
+# HELP dht_humidity_window_max Maximum humidity in percent polled during the window
+# TYPE dht_humidity_window_max gauge
+dht_humidity_window_max{dht_name="attic",gpio="GPIO4",window="1h"} 59
+dht_humidity_window_max{dht_name="attic",gpio="GPIO4",window="1m"} 53
+# HELP dht_humidity_window_min Minimum humidity in percent polled during the window
+# TYPE dht_humidity_window_min gauge
+dht_humidity_window_min{dht_name="attic",gpio="GPIO4",window="1h"} 45
+dht_humidity_window_min{dht_name="attic",gpio="GPIO4",window="1m"} 51
+# HELP dht_humidity_window_stddev Standard deviation of the humidity in percent polled during the window
+# TYPE dht_humidity_window_stddev gauge
+dht_humidity_window_stddev{dht_name="attic",gpio="GPIO4",window="1h"} 5
+dht_humidity_window_stddev{dht_name="attic",gpio="GPIO4",window="1m"} 1
+# HELP dht_temperature_window_avg Mean temperature in degrees Celsius polled during the window
+# TYPE dht_temperature_window_avg gauge
+dht_temperature_window_avg{dht_name="attic",gpio="GPIO4",window="1h"} 22
+dht_temperature_window_avg{dht_name="attic",gpio="GPIO4",window="1m"} 22
+dht_temperature_window_avg{dht_name="probe",gpio="GPIO17",window="1h"} 5
+dht_temperature_window_avg{dht_name="probe",gpio="GPIO17",window="1m"} 5
+# HELP dht_temperature_window_max Maximum temperature in degrees Celsius polled during the window
+# TYPE dht_temperature_window_max gauge
+dht_temperature_window_max{dht_name="attic",gpio="GPIO4",window="1h"} 29
+dht_temperature_window_max{dht_name="attic",gpio="GPIO4",window="1m"} 23
+dht_temperature_window_max{dht_name="probe",gpio="GPIO17",window="1h"} 5
+dht_temperature_window_max{dht_name="probe",gpio="GPIO17",window="1m"} 5
+# HELP dht_temperature_window_min Minimum temperature in degrees Celsius polled during the window
+# TYPE dht_temperature_window_min gauge
+dht_temperature_window_min{dht_name="attic",gpio="GPIO4",window="1h"} 15
+dht_temperature_window_min{dht_name="attic",gpio="GPIO4",window="1m"} 21
+dht_temperature_window_min{dht_name="probe",gpio="GPIO17",window="1h"} 5
+dht_temperature_window_min{dht_name="probe",gpio="GPIO17",window="1m"} 5
+# HELP dht_temperature_window_stddev Standard deviation of the temperature in degrees Celsius polled during the window
+# TYPE dht_temperature_window_stddev gauge
+dht_temperature_window_stddev{dht_name="attic",gpio="GPIO4",window="1h"} 5
+dht_temperature_window_stddev{dht_name="attic",gpio="GPIO4",window="1m"} 1
+dht_temperature_window_stddev{dht_name="probe",gpio="GPIO17",window="1h"} 0
+dht_temperature_window_stddev{dht_name="probe",gpio="GPIO17",window="1m"} 0
+`
+	if err := testutil.CollectAndCompare(w, strings.NewReader(want)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+
+	// Windows whose samples all left them are not exposed
+	w.timeNow = func() time.Time { return start.Add(time.Hour) }
+	if got := testutil.CollectAndCount(w, "dht_temperature_window_avg"); got != 2 {
+		t.Errorf("CollectAndCount(dht_temperature_window_avg) = %d, want 2 (the 1h windows)", got)
+	}
+}
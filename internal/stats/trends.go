@@ -0,0 +1,195 @@
+package stats
+
+import (
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sensor"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// sensorTrends holds the regressions of the temperature and humidity of a
+// sensor, and its alert rules. Temperatures are kept in the unit of the
+// sensor, that of the thresholds of its rules.
+type sensorTrends struct {
+	temperature regression
+	humidity    regression
+	unit        string
+	rules       []config.AlertRuleConfig
+
+	temperatureRateMetric *prometheus.Desc
+	humidityRateMetric    *prometheus.Desc
+	timeToThresholdMetric *prometheus.Desc
+}
+
+// Trends fits a line to the temperature and humidity polled from every
+// sensor during a sliding window, and exposes their rate of change per hour,
+// in degrees Celsius and percent like dht_temperature_celsius and
+// dht_humidity_percent, and, for the alert rules of the sensor, the time
+// until the fitted line crosses their threshold. Failed reads are left out.
+// It implements sink.Sink so it can be fed by the poller like any other output,
+// and prometheus.Collector to expose the trends.
+type Trends struct {
+	logger   *log.Logger
+	duration time.Duration
+
+	mu      sync.Mutex
+	sensors map[sensorKey]*sensorTrends
+	// order is the order of the sensors in the configuration.
+	order []*sensorTrends
+
+	// timeNow is replaced in tests.
+	timeNow func() time.Time
+}
+
+// NewTrends creates Trends over a window of the given duration for the
+// sensors, whose trends are named and labelled as their other metrics with opts.
+func NewTrends(sensors []config.SensorConfig, duration time.Duration, opts collector.Options, logger *log.Logger) *Trends {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get hostname, using empty string")
+		hostname = ""
+	}
+
+	t := &Trends{
+		logger:   logger,
+		duration: duration,
+		sensors:  make(map[sensorKey]*sensorTrends),
+		timeNow:  time.Now,
+	}
+	for _, s := range sensors {
+		sensorOpts := opts.WithLabels(s.Labels)
+		labels := sensorOpts.SensorLabels(s.Name, hostname, s.GPIO)
+		st := &sensorTrends{
+			temperature: regression{duration: duration},
+			humidity:    regression{duration: duration},
+			rules:       s.Alerts,
+			temperatureRateMetric: prometheus.NewDesc(
+				sensorOpts.MetricName("temperature_rate_per_hour"),
+				"Rate of change of the temperature in degrees Celsius per hour, fitted by least squares to the polled readings",
+				nil, labels,
+			),
+			humidityRateMetric: prometheus.NewDesc(
+				sensorOpts.MetricName("humidity_rate_per_hour"),
+				"Rate of change of the humidity in percent per hour, fitted by least squares to the polled readings",
+				nil, labels,
+			),
+			timeToThresholdMetric: prometheus.NewDesc(
+				sensorOpts.MetricName("time_to_threshold_seconds"),
+				"Time until the fitted trend crosses the threshold of the alert rule, 0 once crossed and +Inf when moving away from it",
+				[]string{"rule"}, labels,
+			),
+		}
+		t.sensors[sensorKey{s.Name, s.GPIO}] = st
+		t.order = append(t.order, st)
+	}
+	return t
+}
+
+// Start implements sink.Sink.
+func (t *Trends) Start() error {
+	t.logger.WithFields(log.Fields{
+		"sensors": len(t.order),
+		"window":  t.duration,
+	}).Info("Starting trends")
+	return nil
+}
+
+// Write adds a successful reading to the regressions of its sensor.
+func (t *Trends) Write(r sink.Reading) error {
+	if r.Err != nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.sensors[sensorKey{r.Sensor, r.GPIO}]
+	if st == nil {
+		return nil
+	}
+	st.unit = r.Unit
+	st.temperature.add(r.Time, r.Temperature)
+	if !math.IsNaN(r.Humidity) {
+		st.humidity.add(r.Time, r.Humidity)
+	}
+	return nil
+}
+
+// Close implements sink.Sink.
+func (t *Trends) Close() error {
+	return nil
+}
+
+// Describe sends the descriptors of the trends of every sensor.
+func (t *Trends) Describe(ch chan<- *prometheus.Desc) {
+	for _, st := range t.order {
+		ch <- st.temperatureRateMetric
+		ch <- st.humidityRateMetric
+		ch <- st.timeToThresholdMetric
+	}
+}
+
+// Collect sends the trends of the quantities with at least two readings in
+// the window, once the readings that left the window are evicted.
+func (t *Trends) Collect(ch chan<- prometheus.Metric) {
+	now := t.timeNow()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, st := range t.order {
+		st.temperature.evict(now)
+		st.humidity.evict(now)
+		if slope, _, ok := st.temperature.fit(now); ok {
+			ch <- prometheus.MustNewConstMetric(st.temperatureRateMetric, prometheus.GaugeValue, celsiusPerHour(slope, st.unit))
+		}
+		if slope, _, ok := st.humidity.fit(now); ok {
+			ch <- prometheus.MustNewConstMetric(st.humidityRateMetric, prometheus.GaugeValue, slope*time.Hour.Seconds())
+		}
+		for _, rule := range st.rules {
+			r := &st.temperature
+			if rule.Metric == "humidity" {
+				r = &st.humidity
+			}
+			if slope, value, ok := r.fit(now); ok {
+				ch <- prometheus.MustNewConstMetric(st.timeToThresholdMetric, prometheus.GaugeValue, timeToThreshold(rule, slope, value), rule.Name)
+			}
+		}
+	}
+}
+
+// celsiusPerHour converts a temperature rate per second in the unit of symbol
+// to degrees Celsius per hour. The conversions are affine, so their offset
+// cancels out in the difference.
+func celsiusPerHour(slope float64, symbol string) float64 {
+	perHour := slope * time.Hour.Seconds()
+	return sensor.ConvertTemperature(perHour, symbol, sensor.CelsiusSymbol) - sensor.ConvertTemperature(0, symbol, sensor.CelsiusSymbol)
+}
+
+// timeToThreshold returns the seconds until a trend of slope per second,
+// now at value, crosses the threshold of rule: 0 when it already has, and
+// +Inf when it moves away from the threshold or is flat.
+func timeToThreshold(rule config.AlertRuleConfig, slope, value float64) float64 {
+	remaining := rule.Threshold - value
+	switch rule.Comparison {
+	case ">", ">=":
+		if remaining <= 0 {
+			return 0
+		}
+		if slope <= 0 {
+			return math.Inf(1)
+		}
+	default:
+		if remaining >= 0 {
+			return 0
+		}
+		if slope >= 0 {
+			return math.Inf(1)
+		}
+	}
+	return remaining / slope
+}
@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/collector"
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+	"github.com/guivin/dht-prometheus-exporter/internal/sink"
+)
+
+// gather returns the values of the metrics of c by name and rule label
+func gather(t *testing.T, c prometheus.Collector) map[string]float64 {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("Register() returned unexpected error: %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned unexpected error: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			key := f.GetName()
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "rule" {
+					key += "{" + l.GetValue() + "}"
+				}
+			}
+			values[key] = m.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
+func TestTrends_Collect(t *testing.T) {
+	sensors := []config.SensorConfig{{
+		Name: "attic",
+		GPIO: "GPIO4",
+		Alerts: []config.AlertRuleConfig{
+			{Name: "too-warm", Metric: "temperature", Comparison: ">", Threshold: 26},
+			{Name: "too-cold", Metric: "temperature", Comparison: "<", Threshold: 10},
+			{Name: "too-dry", Metric: "humidity", Comparison: "<=", Threshold: 40},
+		},
+	}}
+	opts := collector.Options{DropLabels: []string{config.LabelHostname}}
+	trends := NewTrends(sensors, 2*time.Hour, opts, getSilentLogger())
+	start := time.Unix(1700000000, 0)
+
+	// 2 degrees and -10% per hour
+	trends.Write(reading("attic", "GPIO4", 20, 60, start))
+	trends.Write(reading("attic", "GPIO4", 21, 55, start.Add(30*time.Minute)))
+	trends.Write(sink.Reading{Time: start.Add(45 * time.Minute), Sensor: "attic", GPIO: "GPIO4", Err: errors.New("timeout")})
+	trends.Write(reading("attic", "GPIO4", 22, 50, start.Add(time.Hour)))
+	trends.timeNow = func() time.Time { return start.Add(time.Hour) }
+
+	got := gather(t, trends)
+	want := map[string]float64{
+		"dht_temperature_rate_per_hour":           2,
+		"dht_humidity_rate_per_hour":              -10,
+		"dht_time_to_threshold_seconds{too-warm}": 2 * 3600,
+		"dht_time_to_threshold_seconds{too-cold}": math.Inf(1),
+		"dht_time_to_threshold_seconds{too-dry}":  3600,
+	}
+	if len(got) != len(want) {
+		t.Errorf("metrics = %v, want %v", got, want)
+	}
+	for name, w := range want {
+		g, ok := got[name]
+		if !ok || (g != w && math.Abs(g-w) > 1e-6) {
+			t.Errorf("%s = %v, want %v", name, g, w)
+		}
+	}
+
+	// Trends need two readings in the window
+	trends.timeNow = func() time.Time { return start.Add(150 * time.Minute) }
+	if got := gather(t, trends); len(got) != 0 {
+		t.Errorf("metrics with a single reading in the window = %v, want none", got)
+	}
+}
+
+func TestCelsiusPerHour(t *testing.T) {
+	tests := []struct {
+		symbol string
+		slope  float64
+		want   float64
+	}{
+		{"C", 1.0 / 3600, 1},
+		{"F", 9.0 / 3600, 5},
+		{"K", -2.0 / 3600, -2},
+	}
+	for _, tt := range tests {
+		if got := celsiusPerHour(tt.slope, tt.symbol); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("celsiusPerHour(%v, %s) = %v, want %v", tt.slope, tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestTimeToThreshold(t *testing.T) {
+	// The threshold is 30
+	tests := []struct {
+		name       string
+		comparison string
+		slope      float64
+		value      float64
+		want       float64
+	}{
+		{"rising towards", ">", 0.01, 20, 1000},
+		{"falling away", ">", -0.01, 20, math.Inf(1)},
+		{"flat", ">=", 0, 20, math.Inf(1)},
+		{"already above", ">=", -0.01, 30, 0},
+		{"falling towards", "<", -0.01, 40, 1000},
+		{"rising away", "<=", 0.01, 40, math.Inf(1)},
+		{"already below", "<", 0.01, 20, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := config.AlertRuleConfig{Comparison: tt.comparison, Threshold: 30}
+			if got := timeToThreshold(rule, tt.slope, tt.value); math.Abs(got-tt.want) > 1e-9 && got != tt.want {
+				t.Errorf("timeToThreshold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
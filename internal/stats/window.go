@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"math"
+	"time"
+)
+
+// sample is a value of a quantity polled at a time.
+type sample struct {
+	time  time.Time
+	value float64
+}
+
+// summary holds the statistics of the samples of a window.
+type summary struct {
+	min, max, mean, stddev float64
+}
+
+// window holds the samples of a quantity polled during the last duration.
+// Its minimum and maximum are the fronts of monotonic deques, and its mean
+// and standard deviation come from running sums, so that adding a sample and
+// summarizing take constant amortized time.
+type window struct {
+	duration time.Duration
+	samples  deque[sample]
+	// mins holds increasing values and maxs decreasing ones: the samples that
+	// may become the minimum or maximum once older samples are evicted.
+	mins deque[sample]
+	maxs deque[sample]
+	// sum and sumSquares are those of the values minus shift, the first value
+	// of the window, limiting the cancellation in the variance.
+	shift      float64
+	sum        float64
+	sumSquares float64
+}
+
+// add adds the value v polled at t, evicting the samples older than the
+// duration of w at t.
+func (w *window) add(t time.Time, v float64) {
+	w.evict(t)
+	if w.samples.Len() == 0 {
+		w.shift = v
+	}
+	s := sample{time: t, value: v}
+	w.samples.PushBack(s)
+	d := v - w.shift
+	w.sum += d
+	w.sumSquares += d * d
+
+	for w.mins.Len() > 0 && w.mins.Back().value >= v {
+		w.mins.PopBack()
+	}
+	w.mins.PushBack(s)
+	for w.maxs.Len() > 0 && w.maxs.Back().value <= v {
+		w.maxs.PopBack()
+	}
+	w.maxs.PushBack(s)
+}
+
+// evict removes the samples polled at least the duration of w before now.
+func (w *window) evict(now time.Time) {
+	start := now.Add(-w.duration)
+	for w.samples.Len() > 0 && !w.samples.Front().time.After(start) {
+		d := w.samples.Front().value - w.shift
+		w.sum -= d
+		w.sumSquares -= d * d
+		w.samples.PopFront()
+	}
+	if w.samples.Len() == 0 {
+		// Drop the rounding errors accumulated by the removals
+		w.sum, w.sumSquares = 0, 0
+	}
+	for w.mins.Len() > 0 && !w.mins.Front().time.After(start) {
+		w.mins.PopFront()
+	}
+	for w.maxs.Len() > 0 && !w.maxs.Front().time.After(start) {
+		w.maxs.PopFront()
+	}
+}
+
+// summary returns the statistics of the samples of w, or false when it has none.
+// The standard deviation is that of the population of the samples.
+func (w *window) summary() (summary, bool) {
+	if w.samples.Len() == 0 {
+		return summary{}, false
+	}
+	n := float64(w.samples.Len())
+	mean := w.sum / n
+	variance := max(w.sumSquares/n-mean*mean, 0)
+	return summary{
+		min:    w.mins.Front().value,
+		max:    w.maxs.Front().value,
+		mean:   w.shift + mean,
+		stddev: math.Sqrt(variance),
+	}, true
+}
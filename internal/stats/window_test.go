@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// bruteSummary computes the summary of the values polled after start
+func bruteSummary(samples []sample, start time.Time) (summary, bool) {
+	var values []float64
+	for _, s := range samples {
+		if s.time.After(start) {
+			values = append(values, s.value)
+		}
+	}
+	if len(values) == 0 {
+		return summary{}, false
+	}
+	s := summary{min: math.Inf(1), max: math.Inf(-1)}
+	for _, v := range values {
+		s.min = math.Min(s.min, v)
+		s.max = math.Max(s.max, v)
+		s.mean += v / float64(len(values))
+	}
+	for _, v := range values {
+		s.stddev += (v - s.mean) * (v - s.mean) / float64(len(values))
+	}
+	s.stddev = math.Sqrt(s.stddev)
+	return s, true
+}
+
+func TestWindow_MatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	start := time.Unix(1700000000, 0)
+	w := &window{duration: 5 * time.Minute}
+
+	var samples []sample
+	now := start
+	for i := range 1000 {
+		// Irregular polls, with gaps longer than the window now and then
+		now = now.Add(time.Duration(rng.Intn(40)) * time.Second)
+		if i%200 == 199 {
+			now = now.Add(10 * time.Minute)
+		}
+		v := 20 + 5*math.Sin(float64(i)/20) + rng.NormFloat64()
+		w.add(now, v)
+		samples = append(samples, sample{now, v})
+
+		got, ok := w.summary()
+		want, wantOK := bruteSummary(samples, now.Add(-w.duration))
+		if ok != wantOK {
+			t.Fatalf("sample %d: summary() ok = %v, want %v", i, ok, wantOK)
+		}
+		if got.min != want.min || got.max != want.max ||
+			math.Abs(got.mean-want.mean) > 1e-9 || math.Abs(got.stddev-want.stddev) > 1e-6 {
+			t.Fatalf("sample %d: summary() = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestWindow_Evict(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	w := &window{duration: time.Minute}
+	w.add(start, 10)
+	w.add(start.Add(30*time.Second), 30)
+
+	if s, _ := w.summary(); s.min != 10 || s.max != 30 || s.mean != 20 || s.stddev != 10 {
+		t.Errorf("summary() = %+v, want min 10, max 30, mean 20, stddev 10", s)
+	}
+
+	// A sample polled exactly the duration before now has left the window
+	w.evict(start.Add(time.Minute))
+	if s, _ := w.summary(); s.min != 30 || s.max != 30 || s.mean != 30 || s.stddev != 0 {
+		t.Errorf("summary() = %+v, want min, max and mean 30, stddev 0", s)
+	}
+
+	w.evict(start.Add(2 * time.Minute))
+	if _, ok := w.summary(); ok {
+		t.Error("summary() ok = true for an empty window, want false")
+	}
+}
@@ -0,0 +1,73 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/guivin/dht-prometheus-exporter/internal/config"
+)
+
+// Build information, set at build time with -ldflags, e.g.
+// -X github.com/guivin/dht-prometheus-exporter/internal/version.Version=1.5.0
+var (
+	// Version is the release of the exporter, from the VERSION file.
+	Version = "dev"
+	// Revision is the VCS revision the exporter was built from. It defaults
+	// to the revision recorded by the Go toolchain.
+	Revision = ""
+	// Branch is the VCS branch the exporter was built from.
+	Branch = ""
+)
+
+func init() {
+	if Revision != "" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				Revision = s.Value
+			}
+		}
+	}
+}
+
+// Collector exposes the build information and start time of the exporter.
+type Collector struct {
+	start          time.Time
+	buildInfo      *prometheus.Desc
+	startTimeGauge *prometheus.Desc
+}
+
+// NewCollector creates a Collector for an exporter started at start, whose
+// metric names are prefixed with namespace.
+func NewCollector(namespace string, start time.Time) *Collector {
+	return &Collector{
+		start: start,
+		buildInfo: prometheus.NewDesc(
+			config.MetricName(namespace, "exporter_build_info"),
+			"Build information of the exporter, always 1",
+			nil, prometheus.Labels{"version": Version, "revision": Revision, "goversion": runtime.Version(), "branch": Branch},
+		),
+		startTimeGauge: prometheus.NewDesc(
+			config.MetricName(namespace, "exporter_start_time_seconds"),
+			"Start time of the exporter since the Unix epoch in seconds",
+			nil, nil,
+		),
+	}
+}
+
+// Describe sends the descriptors of the build information and start time.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.buildInfo
+	ch <- c.startTimeGauge
+}
+
+// Collect sends the build information and start time.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.buildInfo, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(c.startTimeGauge, prometheus.GaugeValue, float64(c.start.UnixNano())/1e9)
+}
@@ -0,0 +1,34 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	Version, Revision, Branch = "1.5.0", "abc123", "main"
+	c := NewCollector("", time.Unix(1700000000, 500000000))
+
+	want := `
+# HELP dht_exporter_build_info Build information of the exporter, always 1
+# TYPE dht_exporter_build_info gauge
+dht_exporter_build_info{branch="main",goversion="` + runtime.Version() + `",revision="abc123",version="1.5.0"} 1
+# HELP dht_exporter_start_time_seconds Start time of the exporter since the Unix epoch in seconds
+# TYPE dht_exporter_start_time_seconds gauge
+dht_exporter_start_time_seconds 1.7000000005e+09
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
+func TestCollector_Namespace(t *testing.T) {
+	c := NewCollector("home", time.Unix(1700000000, 0))
+	if n := testutil.CollectAndCount(c, "home_exporter_build_info", "home_exporter_start_time_seconds"); n != 2 {
+		t.Errorf("CollectAndCount() = %d, want 2", n)
+	}
+}